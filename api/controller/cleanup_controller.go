@@ -44,10 +44,12 @@ func (c *CleanupController) ExecuteCleanup(w http.ResponseWriter, r *http.Reques
 
 	// 返回响应
 	data := map[string]interface{}{
-		"success": result.Success,
-		"failed":  result.Failed,
-		"skipped": result.Skipped,
-		"errors":  result.ErrorMessages,
+		"success":       result.Success,
+		"failed":        result.Failed,
+		"skipped":       result.Skipped,
+		"deletedChunks": result.DeletedChunks,
+		"freedSize":     result.FreedSize,
+		"errors":        result.ErrorMessages,
 	}
 
 	c.writeJSON(w, model.SuccessWithMsg(data, message))
@@ -3,9 +3,16 @@ package controller
 import (
 	"backup-go/entity"
 	"backup-go/model"
+	"backup-go/service/backup"
 	"backup-go/service/config"
+	"backup-go/service/encryption"
+	"backup-go/service/integrity"
+	"backup-go/service/scheduler"
 	"encoding/json"
+	"io"
+	"log"
 	"net/http"
+	"os"
 	"strconv"
 )
 
@@ -110,6 +117,11 @@ func (c *ConfigController) CreateConfig(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if err := validateConfigValue(config.ConfigKey, config.ConfigValue); err != nil {
+		WriteJSONResponse(w, model.FailResponse(err.Error()))
+		return
+	}
+
 	// 检查键是否已存在
 	existingConfig, _ := c.configService.GetConfigByKey(config.ConfigKey)
 	if existingConfig != nil {
@@ -122,6 +134,7 @@ func (c *ConfigController) CreateConfig(w http.ResponseWriter, r *http.Request)
 		WriteJSONResponse(w, model.FailResponse("创建配置失败: "+err.Error()))
 		return
 	}
+	applyConfig(config.ConfigKey)
 
 	WriteJSONResponse(w, model.SuccessResponse(config))
 }
@@ -173,6 +186,11 @@ func (c *ConfigController) UpdateConfig(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	if err := validateConfigValue(updateData.ConfigKey, updateData.ConfigValue); err != nil {
+		WriteJSONResponse(w, model.FailResponse(err.Error()))
+		return
+	}
+
 	// 只更新需要的字段，保留其他原有信息
 	existingConfig.ConfigKey = updateData.ConfigKey
 	existingConfig.ConfigValue = updateData.ConfigValue
@@ -183,6 +201,7 @@ func (c *ConfigController) UpdateConfig(w http.ResponseWriter, r *http.Request)
 		WriteJSONResponse(w, model.FailResponse("更新配置失败: "+err.Error()))
 		return
 	}
+	applyConfig(existingConfig.ConfigKey)
 
 	WriteJSONResponse(w, model.SuccessResponse(existingConfig))
 }
@@ -255,6 +274,52 @@ func (c *ConfigController) TestWebhook(w http.ResponseWriter, r *http.Request) {
 	WriteJSONResponse(w, model.SuccessResponse(nil))
 }
 
+// ImportConfigBackup 导入配置备份
+// @Summary 导入配置备份
+// @Description 请求体为配置备份任务生成的ZIP文件（可以是加密后的原始文件），导入其中的任务、系统配置、备份记录和数据块索引，现有的任务和备份记录会被替换
+// @Tags 配置管理
+// @Accept application/octet-stream
+// @Produce json
+// @Success 200 {object} model.Response
+// @Router /api/configs/import [post]
+func (c *ConfigController) ImportConfigBackup(w http.ResponseWriter, r *http.Request) {
+	// 加密的备份先解密，未加密的原样读取
+	content, err := encryption.Decrypt(r.Body)
+	if err != nil {
+		WriteJSONResponse(w, model.FailResponse("解密配置备份失败: "+err.Error()))
+		return
+	}
+
+	// ZIP需要随机读取，先写入临时文件
+	tempFile, err := os.CreateTemp("", "config_import_*.zip")
+	if err != nil {
+		WriteJSONResponse(w, model.FailResponse("创建临时文件失败: "+err.Error()))
+		return
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	size, err := io.Copy(tempFile, content)
+	if err != nil {
+		WriteJSONResponse(w, model.FailResponse("读取配置备份失败: "+err.Error()))
+		return
+	}
+
+	manifest, err := backup.NewConfigBackupService().Import(tempFile, size)
+	if err != nil {
+		WriteJSONResponse(w, model.FailResponse("导入配置备份失败: "+err.Error()))
+		return
+	}
+
+	// 任务和系统配置已替换，重新加载定时任务
+	if err := scheduler.GetScheduler().Reload(); err != nil {
+		log.Printf("重新加载定时任务失败: %v", err)
+	}
+	applyConfig("system.integrityCheckSchedule")
+
+	WriteJSONResponse(w, model.SuccessResponse(manifest))
+}
+
 // GetSiteInfo 获取站点信息
 // @Summary 获取站点信息
 // @Description 获取站点名称和版本号信息，此接口可匿名访问
@@ -287,3 +352,20 @@ func (c *ConfigController) GetSiteInfo(w http.ResponseWriter, r *http.Request) {
 }
 
 // 通用JSON响应写入函数
+
+// validateConfigValue 检查需要立即生效的配置值
+func validateConfigValue(key, value string) error {
+	if key == "system.integrityCheckSchedule" {
+		return integrity.ValidateSchedule(value)
+	}
+	return nil
+}
+
+// applyConfig 配置保存后使其立即生效
+func applyConfig(key string) {
+	if key == "system.integrityCheckSchedule" {
+		if err := integrity.GetIntegrityService().Reload(); err != nil {
+			log.Printf("更新定时完整性校验失败: %v", err)
+		}
+	}
+}
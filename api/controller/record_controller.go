@@ -4,13 +4,18 @@ import (
 	"backup-go/entity"
 	"backup-go/model"
 	"backup-go/repository"
+	"backup-go/service/archive"
+	"backup-go/service/chunkstore"
 	configService "backup-go/service/config"
+	"backup-go/service/encryption"
+	"backup-go/service/integrity"
 	"backup-go/service/storage"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -20,6 +25,7 @@ import (
 type RecordController struct {
 	recordRepo *repository.BackupRecordRepository
 	taskRepo   *repository.BackupTaskRepository
+	binlogRepo *repository.BinlogFileRepository
 }
 
 // NewRecordController 创建备份记录控制器
@@ -27,6 +33,7 @@ func NewRecordController() *RecordController {
 	return &RecordController{
 		recordRepo: repository.NewBackupRecordRepository(),
 		taskRepo:   repository.NewBackupTaskRepository(),
+		binlogRepo: repository.NewBinlogFileRepository(),
 	}
 }
 
@@ -52,6 +59,7 @@ func (c *RecordController) GetRecord(w http.ResponseWriter, r *http.Request) {
 	task, err := c.taskRepo.FindByID(record.TaskID)
 	if err == nil && task != nil {
 		record.TaskName = task.Name
+		record.TaskType = task.Type
 	} else {
 		record.TaskName = "未知任务"
 	}
@@ -59,6 +67,30 @@ func (c *RecordController) GetRecord(w http.ResponseWriter, r *http.Request) {
 	c.writeJSON(w, model.Success(record))
 }
 
+// GetRecordLog 获取备份记录的运行日志
+func (c *RecordController) GetRecordLog(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		c.writeJSON(w, model.Error(400, "Invalid record ID"))
+		return
+	}
+
+	record, err := c.recordRepo.FindByID(id)
+	if err != nil {
+		c.writeJSON(w, model.Error(500, "Failed to find record: "+err.Error()))
+		return
+	}
+	if record == nil {
+		c.writeJSON(w, model.Error(404, "Record not found"))
+		return
+	}
+
+	c.writeJSON(w, model.Success(map[string]interface{}{
+		"id":  record.ID,
+		"log": record.Log,
+	}))
+}
+
 // GetAllRecords 获取所有备份记录
 func (c *RecordController) GetAllRecords(w http.ResponseWriter, r *http.Request) {
 	page, err := strconv.Atoi(r.URL.Query().Get("page"))
@@ -90,6 +122,7 @@ func (c *RecordController) GetAllRecords(w http.ResponseWriter, r *http.Request)
 		if err == nil && task != nil {
 			// 将任务名称添加到记录中
 			record.TaskName = task.Name
+			record.TaskType = task.Type
 		} else {
 			// 如果查询失败，显示未知任务
 			record.TaskName = "未知任务"
@@ -145,6 +178,7 @@ func (c *RecordController) GetTaskRecords(w http.ResponseWriter, r *http.Request
 		// 为所有记录添加任务名称
 		for _, record := range records {
 			record.TaskName = task.Name
+			record.TaskType = task.Type
 		}
 	} else {
 		// 如果查询任务失败，使用"未知任务"作为任务名称
@@ -166,39 +200,10 @@ func (c *RecordController) GetTaskRecords(w http.ResponseWriter, r *http.Request
 
 // DownloadBackup 下载备份文件
 func (c *RecordController) DownloadBackup(w http.ResponseWriter, r *http.Request) {
-	// 检查是否启用密码保护
-	cs := configService.NewConfigService()
-	passwordConfig, err := cs.GetConfigByKey("system.password")
-
-	// 如果设置了密码，需要验证token
-	if err == nil && passwordConfig.ConfigValue != "" {
-		// 获取token，可以从多个位置获取
-		var token string
-
-		// 1. 从Authorization头获取
-		authHeader := r.Header.Get("Authorization")
-		if strings.HasPrefix(authHeader, "Bearer ") {
-			token = strings.TrimPrefix(authHeader, "Bearer ")
-		}
-
-		// 2. 从URL查询参数获取
-		if token == "" {
-			token = r.URL.Query().Get("token")
-		}
-
-		// 3. 从POST表单获取
-		if token == "" {
-			err := r.ParseForm()
-			if err == nil {
-				token = r.FormValue("token")
-			}
-		}
-
-		// 验证token
-		if token == "" || !IsValidToken(token) {
-			c.writeJSON(w, model.Error(401, "未授权访问，请先登录"))
-			return
-		}
+	// 下载接口不经过认证中间件，需要自行验证token
+	if !c.authorizeDownload(r) {
+		c.writeJSON(w, model.Error(401, "未授权访问，请先登录"))
+		return
 	}
 
 	// 直接通过文件路径下载
@@ -218,6 +223,11 @@ func (c *RecordController) DownloadBackup(w http.ResponseWriter, r *http.Request
 			storageType = entity.S3Storage
 		}
 
+		// SFTP、WebDAV、FTP等存储的路径没有前缀，通过storage参数指定
+		if value := r.URL.Query().Get("storage"); value != "" {
+			storageType = entity.StorageType(value)
+		}
+
 		// 获取对应的存储服务
 		storageService, err := storage.NewStorageService(storageType)
 		if err != nil {
@@ -236,12 +246,23 @@ func (c *RecordController) DownloadBackup(w http.ResponseWriter, r *http.Request
 		// 从文件路径中提取文件名
 		filename := filepath.Base(path)
 
+		// 默认解密后下载，raw=1时下载原始的加密文件
+		var content io.Reader = file
+		if r.URL.Query().Get("raw") != "1" {
+			content, err = encryption.Decrypt(file)
+			if err != nil {
+				c.writeJSON(w, model.Error(500, "Failed to decrypt backup file: "+err.Error()))
+				return
+			}
+			filename = encryption.PlainName(filename)
+		}
+
 		// 设置响应头
 		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", strconv.Quote(filename)))
 		w.Header().Set("Content-Type", "application/octet-stream")
 
 		// 发送文件内容
-		_, err = io.Copy(w, file)
+		_, err = io.Copy(w, content)
 		if err != nil {
 			c.writeJSON(w, model.Error(500, "Failed to send file: "+err.Error()))
 			return
@@ -279,32 +300,26 @@ func (c *RecordController) DownloadBackup(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// 获取存储服务
-	// 优先使用记录中存储的存储类型
-	storageType := record.StorageType
+	// 仓库格式的备份没有单独的归档文件，打包快照中的所有文件为ZIP下载，raw=1时下载快照本身
+	if chunkstore.IsSnapshot(record.FilePath) && r.URL.Query().Get("raw") != "1" {
+		arc, err := archive.Open(record)
+		if err != nil {
+			c.writeJSON(w, model.Error(500, "Failed to open snapshot: "+err.Error()))
+			return
+		}
+		defer arc.Close()
 
-	// 如果记录中没有存储类型（旧数据兼容处理），使用系统配置
-	if storageType == "" {
-		// 从系统配置表读取存储类型
-		cs := configService.NewConfigService()
-		storageTypeStr, err := cs.GetConfigValue("storage.type")
-		if err == nil && storageTypeStr != "" {
-			storageType = entity.StorageType(storageTypeStr)
-		} else {
-			// 配置表中也没有，尝试从文件路径判断
-			filePath := record.FilePath
-			if strings.HasPrefix(filePath, "s3://") || strings.HasPrefix(filePath, "backups/") {
-				// S3存储或特定格式
-				storageType = entity.S3Storage
-			} else if filepath.IsAbs(filePath) || strings.HasPrefix(filePath, "./") || strings.HasPrefix(filePath, "../") {
-				// 绝对路径或相对路径，视为本地存储
-				storageType = entity.LocalStorage
-			}
+		filename := fmt.Sprintf("files_%s.zip", record.BackupVersion)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", strconv.Quote(filename)))
+		w.Header().Set("Content-Type", "application/zip")
+		if err := arc.WriteZip(w, ""); err != nil {
+			log.Printf("打包快照失败: %s, 错误: %v", record.FilePath, err)
 		}
+		return
 	}
 
-	// 获取对应的存储服务
-	storageService, err := storage.NewStorageService(storageType)
+	// 获取记录对应的存储服务
+	storageService, err := storage.NewStorageServiceForRecord(record)
 	if err != nil {
 		c.writeJSON(w, model.Error(500, "Failed to create storage service: "+err.Error()))
 		return
@@ -321,18 +336,215 @@ func (c *RecordController) DownloadBackup(w http.ResponseWriter, r *http.Request
 	// 从文件路径中提取文件名
 	filename := filepath.Base(record.FilePath)
 
+	// 默认解密后下载，raw=1时下载原始的加密文件
+	var content io.Reader = file
+	if r.URL.Query().Get("raw") != "1" {
+		content, err = encryption.DecryptWithKey(file, record.EncryptionKeyID)
+		if err != nil {
+			c.writeJSON(w, model.Error(500, "Failed to decrypt backup file: "+err.Error()))
+			return
+		}
+		filename = encryption.PlainName(filename)
+	}
+
 	// 设置响应头
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", strconv.Quote(filename)))
 	w.Header().Set("Content-Type", "application/octet-stream")
 
 	// 发送文件内容
-	_, err = io.Copy(w, file)
+	_, err = io.Copy(w, content)
 	if err != nil {
 		c.writeJSON(w, model.Error(500, "Failed to send file: "+err.Error()))
 		return
 	}
 }
 
+// ListEntries 列出文件备份归档中的条目
+func (c *RecordController) ListEntries(w http.ResponseWriter, r *http.Request) {
+	arc, errResp := c.openArchive(r)
+	if errResp != nil {
+		c.writeJSON(w, errResp)
+		return
+	}
+	defer arc.Close()
+
+	// 可选的目录前缀过滤
+	prefix := strings.Trim(r.URL.Query().Get("prefix"), "/")
+
+	entries := []archive.Entry{}
+	for _, entry := range arc.Entries() {
+		if prefix == "" || entry.Path == prefix || strings.HasPrefix(entry.Path, prefix+"/") {
+			entries = append(entries, entry)
+		}
+	}
+
+	result := map[string]interface{}{
+		"entries": entries,
+		"total":   len(entries),
+	}
+
+	c.writeJSON(w, model.Success(result))
+}
+
+// ExtractEntry 从文件备份归档中提取单个文件，目录会打包为ZIP返回
+func (c *RecordController) ExtractEntry(w http.ResponseWriter, r *http.Request) {
+	// 下载接口不经过认证中间件，需要自行验证token
+	if !c.authorizeDownload(r) {
+		c.writeJSON(w, model.Error(401, "未授权访问，请先登录"))
+		return
+	}
+
+	name, ok := archive.CleanPath(strings.TrimSuffix(r.URL.Query().Get("path"), "/"))
+	if !ok {
+		c.writeJSON(w, model.Error(400, "Invalid entry path"))
+		return
+	}
+
+	arc, errResp := c.openArchive(r)
+	if errResp != nil {
+		c.writeJSON(w, errResp)
+		return
+	}
+	defer arc.Close()
+
+	entry, ok := arc.Stat(name)
+	if !ok {
+		c.writeJSON(w, model.Error(404, "Entry not found"))
+		return
+	}
+
+	// 目录打包为ZIP，边压缩边发送
+	if entry.IsDir {
+		filename := path.Base(entry.Path) + ".zip"
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", strconv.Quote(filename)))
+		w.Header().Set("Content-Type", "application/zip")
+		if err := arc.WriteZip(w, entry.Path); err != nil {
+			log.Printf("发送归档目录失败: %s, 错误: %v", entry.Path, err)
+		}
+		return
+	}
+
+	if entry.Linkname != "" {
+		c.writeJSON(w, model.Error(400, "Entry is a symbolic link to "+entry.Linkname))
+		return
+	}
+
+	file, err := arc.OpenFile(entry.Path)
+	if err != nil {
+		c.writeJSON(w, model.Error(500, "Failed to open entry: "+err.Error()))
+		return
+	}
+	defer file.Close()
+
+	filename := path.Base(entry.Path)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", strconv.Quote(filename)))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(entry.Size, 10))
+
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("发送归档文件失败: %s, 错误: %v", entry.Path, err)
+	}
+}
+
+// openArchive 根据请求中的记录ID打开文件备份归档
+func (c *RecordController) openArchive(r *http.Request) (*archive.Archive, *model.Response) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		return nil, model.Error(400, "Invalid record ID")
+	}
+
+	record, err := c.recordRepo.FindByID(id)
+	if err != nil {
+		return nil, model.Error(500, "Failed to find record: "+err.Error())
+	}
+	if record == nil {
+		return nil, model.Error(404, "Record not found")
+	}
+	if record.Status == entity.StatusCleaned || record.FilePath == "" {
+		return nil, model.Error(404, "Backup file not available")
+	}
+
+	arc, err := archive.Open(record)
+	if err != nil {
+		return nil, model.Error(500, "Failed to open archive: "+err.Error())
+	}
+	return arc, nil
+}
+
+// authorizeDownload 验证下载请求的token
+// 浏览器直接下载时无法设置请求头，因此也支持从查询参数和表单中获取token
+func (c *RecordController) authorizeDownload(r *http.Request) bool {
+	// 检查是否启用密码保护
+	cs := configService.NewConfigService()
+	passwordConfig, err := cs.GetConfigByKey("system.password")
+
+	// 如果设置了密码，需要验证token
+	if err == nil && passwordConfig.ConfigValue != "" {
+		// 获取token，可以从多个位置获取
+		var token string
+
+		// 1. 从Authorization头获取
+		authHeader := r.Header.Get("Authorization")
+		if strings.HasPrefix(authHeader, "Bearer ") {
+			token = strings.TrimPrefix(authHeader, "Bearer ")
+		}
+
+		// 2. 从URL查询参数获取
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+
+		// 3. 从POST表单获取
+		if token == "" {
+			err := r.ParseForm()
+			if err == nil {
+				token = r.FormValue("token")
+			}
+		}
+
+		// 验证token
+		if token == "" || !IsValidToken(token) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// VerifyRecord 重新读取备份文件并与记录的校验和比较
+func (c *RecordController) VerifyRecord(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.writeJSON(w, model.Error(400, "无效的请求数据: "+err.Error()))
+		return
+	}
+	if req.ID <= 0 {
+		c.writeJSON(w, model.Error(400, "无效的记录ID"))
+		return
+	}
+
+	result, err := integrity.GetIntegrityService().VerifyRecord(req.ID)
+	if err != nil {
+		c.writeJSON(w, model.Error(500, "完整性校验失败: "+err.Error()))
+		return
+	}
+
+	c.writeJSON(w, model.Success(result))
+}
+
+// VerifyAllRecords 校验所有备份记录的文件完整性
+func (c *RecordController) VerifyAllRecords(w http.ResponseWriter, r *http.Request) {
+	result, err := integrity.GetIntegrityService().VerifyAll()
+	if err != nil {
+		c.writeJSON(w, model.Error(500, "完整性校验失败: "+err.Error()))
+		return
+	}
+
+	c.writeJSON(w, model.Success(result))
+}
+
 // DeleteRecord 删除备份记录
 func (c *RecordController) DeleteRecord(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
@@ -352,6 +564,17 @@ func (c *RecordController) DeleteRecord(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// 被增量或差异备份依赖的记录不能删除，否则后续备份无法恢复
+	dependents, err := c.recordRepo.CountDependents(id)
+	if err != nil {
+		c.writeJSON(w, model.Error(500, "查找依赖记录失败: "+err.Error()))
+		return
+	}
+	if dependents > 0 {
+		c.writeJSON(w, model.Error(400, fmt.Sprintf("该备份被%d个增量或差异备份依赖，请先删除依赖它的备份", dependents)))
+		return
+	}
+
 	// 如果有备份文件路径，尝试删除文件
 	if record.FilePath != "" {
 		// 获取存储服务
@@ -381,6 +604,13 @@ func (c *RecordController) DeleteRecord(w http.ResponseWriter, r *http.Request)
 			// 仅记录日志，不中断流程
 			log.Printf("删除文件失败: %s, 错误: %v", record.FilePath, err)
 		}
+
+		// 删除清单文件
+		if record.ManifestPath != "" {
+			if err := storageService.Delete(record.ManifestPath); err != nil {
+				log.Printf("删除清单文件失败: %s, 错误: %v", record.ManifestPath, err)
+			}
+		}
 	}
 
 	// 删除数据库记录
@@ -390,6 +620,11 @@ func (c *RecordController) DeleteRecord(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// binlog归档记录还需删除其中文件的索引
+	if err := c.binlogRepo.DeleteByRecordID(id); err != nil {
+		log.Printf("删除备份记录 %d 的binlog文件记录失败: %v", id, err)
+	}
+
 	c.writeJSON(w, model.Success(nil))
 }
 
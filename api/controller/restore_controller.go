@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"backup-go/model"
+	"backup-go/repository"
+	"backup-go/service/restore"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// RestoreController 恢复控制器
+type RestoreController struct {
+	restoreRepo    *repository.RestoreRecordRepository
+	taskRepo       *repository.BackupTaskRepository
+	restoreService *restore.RestoreService
+}
+
+// NewRestoreController 创建恢复控制器
+func NewRestoreController() *RestoreController {
+	return &RestoreController{
+		restoreRepo:    repository.NewRestoreRecordRepository(),
+		taskRepo:       repository.NewBackupTaskRepository(),
+		restoreService: restore.NewRestoreService(),
+	}
+}
+
+// RestoreDatabase 将数据库备份恢复到原数据库或指定的目标数据库
+func (c *RestoreController) RestoreDatabase(w http.ResponseWriter, r *http.Request) {
+	var req restore.DatabaseRestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.writeJSON(w, model.Error(400, "Invalid request: "+err.Error()))
+		return
+	}
+	if req.BackupRecordID <= 0 {
+		c.writeJSON(w, model.Error(400, "Invalid record ID"))
+		return
+	}
+
+	restoreRecord, err := c.restoreService.RestoreDatabase(&req)
+	if err != nil {
+		c.writeJSON(w, model.Error(500, "Failed to start restore: "+err.Error()))
+		return
+	}
+
+	c.writeJSON(w, model.Success(restoreRecord))
+}
+
+// RestoreToTime 将MySQL数据库恢复到指定时间：导入之前最近的完整备份并重放归档的binlog
+func (c *RestoreController) RestoreToTime(w http.ResponseWriter, r *http.Request) {
+	var req restore.PointInTimeRestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.writeJSON(w, model.Error(400, "Invalid request: "+err.Error()))
+		return
+	}
+	if req.TaskID <= 0 {
+		c.writeJSON(w, model.Error(400, "Invalid task ID"))
+		return
+	}
+
+	restoreRecord, err := c.restoreService.RestoreToTime(&req)
+	if err != nil {
+		c.writeJSON(w, model.Error(500, "Failed to start restore: "+err.Error()))
+		return
+	}
+
+	c.writeJSON(w, model.Success(restoreRecord))
+}
+
+// RestoreFiles 将文件备份解压到原始路径或沙箱目录，返回每个文件的恢复结果
+func (c *RestoreController) RestoreFiles(w http.ResponseWriter, r *http.Request) {
+	var req restore.FileRestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.writeJSON(w, model.Error(400, "Invalid request: "+err.Error()))
+		return
+	}
+	if req.BackupRecordID <= 0 {
+		c.writeJSON(w, model.Error(400, "Invalid record ID"))
+		return
+	}
+
+	response, err := c.restoreService.RestoreFiles(&req)
+	if err != nil {
+		c.writeJSON(w, model.Error(500, "Failed to restore files: "+err.Error()))
+		return
+	}
+
+	c.writeJSON(w, model.Success(response))
+}
+
+// VerifyBackup 将数据库备份导入临时数据库校验能否恢复，校验在后台执行
+func (c *RestoreController) VerifyBackup(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.writeJSON(w, model.Error(400, "Invalid request: "+err.Error()))
+		return
+	}
+	if req.ID <= 0 {
+		c.writeJSON(w, model.Error(400, "Invalid record ID"))
+		return
+	}
+
+	record, err := c.restoreService.StartVerify(req.ID)
+	if err != nil {
+		c.writeJSON(w, model.Error(500, "Failed to start verification: "+err.Error()))
+		return
+	}
+
+	c.writeJSON(w, model.Success(record))
+}
+
+// GetRestoreRecord 获取恢复记录
+func (c *RestoreController) GetRestoreRecord(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		c.writeJSON(w, model.Error(400, "Invalid restore record ID"))
+		return
+	}
+
+	record, err := c.restoreRepo.FindByID(id)
+	if err != nil {
+		c.writeJSON(w, model.Error(500, "Failed to find restore record: "+err.Error()))
+		return
+	}
+	if record == nil {
+		c.writeJSON(w, model.Error(404, "Restore record not found"))
+		return
+	}
+
+	// 添加任务名称
+	task, err := c.taskRepo.FindByID(record.TaskID)
+	if err == nil && task != nil {
+		record.TaskName = task.Name
+	} else {
+		record.TaskName = "未知任务"
+	}
+
+	c.writeJSON(w, model.Success(record))
+}
+
+// GetAllRestoreRecords 分页获取所有恢复记录
+func (c *RestoreController) GetAllRestoreRecords(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+
+	records, err := c.restoreRepo.FindAll(page, pageSize)
+	if err != nil {
+		c.writeJSON(w, model.Error(500, "Failed to find restore records: "+err.Error()))
+		return
+	}
+
+	count, err := c.restoreRepo.CountAll()
+	if err != nil {
+		c.writeJSON(w, model.Error(500, "Failed to count restore records: "+err.Error()))
+		return
+	}
+
+	// 为每条记录添加任务名称
+	for _, record := range records {
+		task, err := c.taskRepo.FindByID(record.TaskID)
+		if err == nil && task != nil {
+			record.TaskName = task.Name
+		} else {
+			record.TaskName = "未知任务"
+		}
+	}
+
+	result := map[string]interface{}{
+		"records":  records,
+		"total":    count,
+		"page":     page,
+		"pageSize": pageSize,
+	}
+
+	c.writeJSON(w, model.Success(result))
+}
+
+// 写入JSON响应
+func (c *RestoreController) writeJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
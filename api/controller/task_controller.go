@@ -122,6 +122,13 @@ func (c *TaskController) DeleteTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 删除binlog归档任务已归档文件的索引
+	if err := tx.Where("task_id = ?", id).Delete(&entity.BinlogFile{}).Error; err != nil {
+		tx.Rollback()
+		c.writeJSON(w, model.Error(500, "删除任务关联的binlog文件记录失败: "+err.Error()))
+		return
+	}
+
 	// 删除任务
 	if err := tx.Delete(&entity.BackupTask{}, id).Error; err != nil {
 		tx.Rollback()
@@ -13,7 +13,7 @@ import (
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// 跳过登录接口的身份验证
-		if r.URL.Path == "/api/auth/login" || r.URL.Path == "/api/auth/check" || r.URL.Path == "/api/records/download" {
+		if r.URL.Path == "/api/auth/login" || r.URL.Path == "/api/auth/check" || r.URL.Path == "/api/records/download" || r.URL.Path == "/api/records/extract" {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -13,6 +13,7 @@ func SetupRouter() http.Handler {
 	configController := controller.NewConfigController()
 	authController := controller.NewAuthController()
 	cleanupController := controller.NewCleanupController()
+	restoreController := controller.NewRestoreController()
 
 	// 创建路由复用器
 	mux := http.NewServeMux()
@@ -126,6 +127,14 @@ func SetupRouter() http.Handler {
 		}
 	})
 
+	apiRoutes.HandleFunc("/api/records/log", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			recordController.GetRecordLog(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
 	apiRoutes.HandleFunc("/api/records/task", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
 			recordController.GetTaskRecords(w, r)
@@ -150,6 +159,87 @@ func SetupRouter() http.Handler {
 		}
 	})
 
+	apiRoutes.HandleFunc("/api/records/entries", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			recordController.ListEntries(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	apiRoutes.HandleFunc("/api/records/extract", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			recordController.ExtractEntry(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	apiRoutes.HandleFunc("/api/records/restore", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			restoreController.RestoreDatabase(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	apiRoutes.HandleFunc("/api/records/restoreToTime", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			restoreController.RestoreToTime(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	apiRoutes.HandleFunc("/api/records/restoreFiles", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			restoreController.RestoreFiles(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	apiRoutes.HandleFunc("/api/records/testRestore", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			restoreController.VerifyBackup(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	apiRoutes.HandleFunc("/api/records/verify", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			recordController.VerifyRecord(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	apiRoutes.HandleFunc("/api/records/verifyAll", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			recordController.VerifyAllRecords(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// 恢复记录相关路由
+	apiRoutes.HandleFunc("/api/restores", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			restoreController.GetAllRestoreRecords(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	apiRoutes.HandleFunc("/api/restores/get", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			restoreController.GetRestoreRecord(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
 	// 配置相关路由
 	apiRoutes.HandleFunc("/api/configs", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
@@ -202,6 +292,14 @@ func SetupRouter() http.Handler {
 		}
 	})
 
+	apiRoutes.HandleFunc("/api/configs/import", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			configController.ImportConfigBackup(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
 	// 清理功能路由
 	apiRoutes.HandleFunc("/api/cleanup/execute", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
@@ -129,9 +129,19 @@ func MigrateDB() error {
 		&entity.BackupTask{},
 		&entity.BackupRecord{},
 		&entity.SystemConfig{},
+		&entity.RestoreRecord{},
+		&entity.Chunk{},
+		&entity.BinlogFile{},
 	)
 	if err != nil {
 		return fmt.Errorf("数据表迁移失败: %w", err)
 	}
+
+	// 数据块的唯一索引增加了加密密钥ID，删除旧的索引，否则不同密钥无法保存相同的数据块
+	if db.Migrator().HasIndex(&entity.Chunk{}, "idx_chunk_hash_storage") {
+		if err := db.Migrator().DropIndex(&entity.Chunk{}, "idx_chunk_hash_storage"); err != nil {
+			return fmt.Errorf("数据表迁移失败: %w", err)
+		}
+	}
 	return nil
 }
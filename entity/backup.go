@@ -11,6 +11,9 @@ const (
 	DatabaseBackup BackupType = "database" // 数据库备份
 	FileBackup     BackupType = "file"     // 文件备份
 	ConfigBackup   BackupType = "config"   // 配置文件备份
+	RedisBackup    BackupType = "redis"    // Redis快照备份
+	SQLiteBackup   BackupType = "sqlite"   // SQLite在线备份
+	BinlogBackup   BackupType = "binlog"   // MySQL binlog归档，用于时间点恢复
 )
 
 // BackupStatus 备份状态
@@ -23,14 +26,38 @@ const (
 	StatusFailed    BackupStatus = "failed"    // 失败
 	StatusCancelled BackupStatus = "cancelled" // 已取消
 	StatusCleaned   BackupStatus = "cleaned"   // 已清理
+	StatusCorrupted BackupStatus = "corrupted" // 完整性校验失败，存储中的文件缺失或与校验和不一致
+)
+
+// VerifyStatus 恢复校验状态
+type VerifyStatus string
+
+const (
+	VerifyRunning VerifyStatus = "verifying"     // 校验中
+	VerifyPassed  VerifyStatus = "verified"      // 校验通过
+	VerifyFailed  VerifyStatus = "verify_failed" // 校验失败
+)
+
+// BackupMode 文件备份方式
+type BackupMode string
+
+const (
+	BackupModeFull         BackupMode = "full"         // 完整备份
+	BackupModeIncremental  BackupMode = "incremental"  // 增量备份，只备份自上一次备份以来变化的文件
+	BackupModeDifferential BackupMode = "differential" // 差异备份，只备份自上一次完整备份以来变化的文件
 )
 
 // StorageType 存储类型
 type StorageType string
 
 const (
-	LocalStorage StorageType = "local" // 本地存储
-	S3Storage    StorageType = "s3"    // S3协议存储
+	LocalStorage  StorageType = "local"  // 本地存储
+	S3Storage     StorageType = "s3"     // S3协议存储
+	SFTPStorage   StorageType = "sftp"   // SFTP存储
+	WebDAVStorage StorageType = "webdav" // WebDAV存储，如Nextcloud、ownCloud
+	FTPStorage    StorageType = "ftp"    // FTP/FTPS存储
+	AzureStorage  StorageType = "azure"  // Azure Blob存储
+	GCSStorage    StorageType = "gcs"    // Google Cloud Storage
 )
 
 // SystemConfig 系统配置
@@ -68,37 +95,178 @@ func (BackupTask) TableName() string {
 
 // DatabaseSourceInfo 数据库源信息
 type DatabaseSourceInfo struct {
-	Type     string `json:"type"`     // 数据库类型
-	Host     string `json:"host"`     // 主机
-	Port     int    `json:"port"`     // 端口
-	User     string `json:"user"`     // 用户名
-	Password string `json:"password"` // 密码
-	Database string `json:"database"` // 数据库名，为空或"all"时表示备份所有数据库
+	Type             string         `json:"type"`                       // 数据库类型：mysql、postgres、mongodb
+	Host             string         `json:"host"`                       // 主机
+	Port             int            `json:"port"`                       // 端口
+	User             string         `json:"user"`                       // 用户名
+	Password         string         `json:"password"`                   // 密码
+	Database         string         `json:"database"`                   // 数据库名，为空或"all"时表示备份所有数据库
+	Schema           string         `json:"schema,omitempty"`           // PostgreSQL模式名，多个用逗号分隔，为空时备份所有模式
+	Format           string         `json:"format,omitempty"`           // PostgreSQL导出格式：plain或custom，默认plain
+	URI              string         `json:"uri,omitempty"`              // MongoDB连接字符串，设置后优先于主机、端口等信息，可用于副本集
+	AuthSource       string         `json:"authSource,omitempty"`       // MongoDB认证数据库，未使用连接字符串时有效
+	Compression      string         `json:"compression,omitempty"`      // 导出文件的压缩方式：none、gzip、zstd，默认none；MongoDB归档始终使用gzip
+	CompressionLevel int            `json:"compressionLevel,omitempty"` // 压缩级别，gzip为1-9，zstd为1-22，0表示默认级别
+	Verify           *VerifyOptions `json:"verify,omitempty"`           // 恢复校验配置
+	MySQL            *MySQLOptions  `json:"mysql,omitempty"`            // mysqldump导出选项
+}
+
+// MySQLOptions mysqldump导出选项
+type MySQLOptions struct {
+	Flavor            string   `json:"flavor,omitempty"`            // 导出工具：mysql（mysqldump）或mariadb（mariadb-dump），为空时自动检测
+	SingleTransaction bool     `json:"singleTransaction,omitempty"` // 使用--single-transaction在一致性快照中导出InnoDB表，不锁表
+	Routines          bool     `json:"routines,omitempty"`          // 导出存储过程和函数
+	SkipTriggers      bool     `json:"skipTriggers,omitempty"`      // 不导出触发器，mysqldump默认导出
+	Events            bool     `json:"events,omitempty"`            // 导出事件
+	Tables            []string `json:"tables,omitempty"`            // 只导出指定的表，仅单个数据库备份有效，导出文件中不包含建库语句
+	ExcludeTables     []string `json:"excludeTables,omitempty"`     // 排除的表，格式为"库名.表名"，单个数据库备份时可以只写表名
+	Where             string   `json:"where,omitempty"`             // 导出行的过滤条件，对所有表生效，如"created_at > '2024-01-01'"
+	SSLMode           string   `json:"sslMode,omitempty"`           // TLS模式：DISABLED、PREFERRED、REQUIRED、VERIFY_CA、VERIFY_IDENTITY，默认DISABLED
+	SSLCA             string   `json:"sslCa,omitempty"`             // CA证书路径
+	SSLCert           string   `json:"sslCert,omitempty"`           // 客户端证书路径
+	SSLKey            string   `json:"sslKey,omitempty"`            // 客户端私钥路径
+	BinlogPosition    bool     `json:"binlogPosition,omitempty"`    // 在导出文件中记录binlog位置（--source-data=2），用于binlog归档和时间点恢复
+}
+
+// VerifyOptions 数据库备份的恢复校验配置
+// 校验时将备份导入临时数据库，执行检查后删除该数据库
+type VerifyOptions struct {
+	Enabled   bool                `json:"enabled"`             // 是否启用
+	Schedule  string              `json:"schedule,omitempty"`  // Cron表达式，定时校验最近一次成功的备份；为空时每次备份完成后立即校验
+	Target    *DatabaseSourceInfo `json:"target,omitempty"`    // 临时数据库，非空字段覆盖原任务配置，数据库名默认为"原库名_verify"
+	Queries   []string            `json:"queries,omitempty"`   // 检查语句，执行出错或结果为空时校验失败；MongoDB为mongosh表达式
+	MinTables int                 `json:"minTables,omitempty"` // 恢复后至少应有的表（集合）数量，默认1
+}
+
+// RedisSourceInfo Redis源信息
+type RedisSourceInfo struct {
+	Host     string `json:"host"`              // 主机
+	Port     int    `json:"port"`              // 端口，默认6379
+	Username string `json:"username"`          // ACL用户名，为空时仅使用密码认证
+	Password string `json:"password"`          // 密码
+	Mode     string `json:"mode"`              // 快照方式：sync（通过复制协议获取RDB）或bgsave，默认sync
+	RDBPath  string `json:"rdbPath,omitempty"` // bgsave方式下RDB文件的本地路径，为空时通过CONFIG GET获取
+}
+
+// BinlogSourceInfo MySQL binlog归档源信息，连接信息使用关联的数据库备份任务的配置
+type BinlogSourceInfo struct {
+	DatabaseTaskID   int64  `json:"databaseTaskId"`             // 关联的MySQL数据库备份任务ID，该任务需开启记录binlog位置
+	FlushLogs        bool   `json:"flushLogs,omitempty"`        // 归档前执行FLUSH BINARY LOGS，使正在写入的binlog也能归档，需要RELOAD权限
+	Compression      string `json:"compression,omitempty"`      // 压缩方式：none、gzip、zstd，默认none
+	CompressionLevel int    `json:"compressionLevel,omitempty"` // 压缩级别，gzip为1-9，zstd为1-22，0表示默认级别
+}
+
+// SQLiteSourceInfo SQLite源信息
+type SQLiteSourceInfo struct {
+	Path string `json:"path"` // 数据库文件路径
 }
 
 // FileSourceInfo 文件源信息
 type FileSourceInfo struct {
-	Paths []string `json:"paths"` // 文件或目录路径
+	Paths            []string   `json:"paths"`                      // 文件或目录路径
+	Format           string     `json:"format,omitempty"`           // 归档格式：zip、tar或repository，默认zip；tar格式保留权限、属主和符号链接，repository格式按内容分块去重存储
+	Compression      string     `json:"compression,omitempty"`      // 压缩方式：none、gzip、zstd，默认gzip；zip格式不支持zstd
+	CompressionLevel int        `json:"compressionLevel,omitempty"` // 压缩级别，gzip为1-9，zstd为1-22，0表示默认级别
+	Include          []string   `json:"include,omitempty"`          // 包含规则，不为空时只备份匹配的文件，支持"*"、"?"和"**"
+	Exclude          []string   `json:"exclude,omitempty"`          // 排除规则，匹配的文件和目录不备份，如node_modules/、*.log、**/cache/**
+	MaxFileSize      int64      `json:"maxFileSize,omitempty"`      // 单个文件的最大大小，单位字节，超过时跳过，0表示不限制
+	SkipHidden       bool       `json:"skipHidden,omitempty"`       // 是否跳过以"."开头的隐藏文件和目录
+	Mode             BackupMode `json:"mode,omitempty"`             // 备份方式：full、incremental、differential，默认full
+	FullInterval     int        `json:"fullInterval,omitempty"`     // 增量或差异备份时，距上一次完整备份达到该次数后执行完整备份，0表示不自动执行
 }
 
 // BackupRecord 备份记录
 type BackupRecord struct {
-	ID            int64        `json:"id" gorm:"primaryKey;autoIncrement"`
-	TaskID        int64        `json:"taskId" gorm:"not null;index"`                                        // 任务ID
-	TaskName      string       `json:"taskName" gorm:"-"`                                                   // 任务名称（不映射到数据库）
-	Status        BackupStatus `json:"status" gorm:"type:varchar(20);not null"`                             // 状态
-	StartTime     time.Time    `json:"startTime" gorm:"type:datetime;not null"`                             // 开始时间
-	EndTime       time.Time    `json:"endTime" gorm:"type:datetime;not null;default:'1970-01-01 00:00:00'"` // 结束时间
-	FileSize      int64        `json:"fileSize" gorm:"not null;default:0"`                                  // 备份文件大小，单位字节
-	FilePath      string       `json:"filePath" gorm:"type:varchar(255);not null;default:''"`               // 文件路径
-	StorageType   StorageType  `json:"storageType" gorm:"type:varchar(20);not null;default:'local'"`        // 存储类型
-	ErrorMessage  string       `json:"errorMessage" gorm:"type:text;not null"`                              // 错误信息
-	BackupVersion string       `json:"backupVersion" gorm:"type:varchar(50);not null;default:''"`           // 备份版本
-	CreatedAt     time.Time    `json:"createdAt" gorm:"type:datetime;not null;default:CURRENT_TIMESTAMP"`   // 创建时间
-	UpdatedAt     time.Time    `json:"updatedAt" gorm:"type:datetime;not null"`                             // 更新时间
+	ID              int64        `json:"id" gorm:"primaryKey;autoIncrement"`
+	TaskID          int64        `json:"taskId" gorm:"not null;index"`                                        // 任务ID
+	TaskName        string       `json:"taskName" gorm:"-"`                                                   // 任务名称（不映射到数据库）
+	TaskType        BackupType   `json:"taskType" gorm:"-"`                                                   // 任务类型（不映射到数据库）
+	Status          BackupStatus `json:"status" gorm:"type:varchar(20);not null"`                             // 状态
+	StartTime       time.Time    `json:"startTime" gorm:"type:datetime;not null"`                             // 开始时间
+	EndTime         time.Time    `json:"endTime" gorm:"type:datetime;not null;default:'1970-01-01 00:00:00'"` // 结束时间
+	FileSize        int64        `json:"fileSize" gorm:"not null;default:0"`                                  // 备份文件大小，单位字节
+	OriginalSize    int64        `json:"originalSize" gorm:"not null;default:0"`                              // 压缩前的数据大小，单位字节
+	CompressedSize  int64        `json:"compressedSize" gorm:"not null;default:0"`                            // 压缩后的数据大小（加密前），单位字节
+	Compression     string       `json:"compression" gorm:"type:varchar(20);not null;default:''"`             // 压缩方式，为空表示未记录
+	FilePath        string       `json:"filePath" gorm:"type:varchar(255);not null;default:''"`               // 文件路径
+	StorageType     StorageType  `json:"storageType" gorm:"type:varchar(20);not null;default:'local'"`        // 存储类型
+	ErrorMessage    string       `json:"errorMessage" gorm:"type:text;not null"`                              // 错误信息
+	BackupVersion   string       `json:"backupVersion" gorm:"type:varchar(50);not null;default:''"`           // 备份版本
+	Summary         string       `json:"summary" gorm:"type:text"`                                            // 备份摘要，如文件备份中归档和跳过的条目
+	BackupMode      BackupMode   `json:"backupMode" gorm:"type:varchar(20);not null;default:''"`              // 文件备份方式，为空表示未记录
+	ParentID        int64        `json:"parentId" gorm:"not null;default:0;index"`                            // 增量或差异备份所依赖的备份记录ID
+	ManifestPath    string       `json:"manifestPath" gorm:"type:varchar(255);not null;default:''"`           // 文件清单路径，增量和差异备份依据清单判断文件是否变化
+	VerifyStatus    VerifyStatus `json:"verifyStatus" gorm:"type:varchar(20);not null;default:''"`            // 恢复校验状态，为空表示未校验
+	VerifyMessage   string       `json:"verifyMessage" gorm:"type:text"`                                      // 恢复校验结果
+	VerifiedAt      *time.Time   `json:"verifiedAt" gorm:"type:datetime"`                                     // 最近一次校验时间
+	EncryptionKeyID string       `json:"encryptionKeyId" gorm:"type:varchar(255);not null;default:''"`        // 加密使用的密钥ID，为空表示未加密
+	Checksum        string       `json:"checksum" gorm:"type:varchar(64);not null;default:''"`                // 存储中备份文件（加密后）的SHA-256，为空表示未记录
+	CheckedAt       *time.Time   `json:"checkedAt" gorm:"type:datetime"`                                      // 最近一次完整性校验时间
+	BinlogFile      string       `json:"binlogFile" gorm:"type:varchar(255);not null;default:''"`             // MySQL完整备份开始时的binlog文件，为空表示未记录
+	BinlogPosition  int64        `json:"binlogPosition" gorm:"not null;default:0"`                            // MySQL完整备份开始时的binlog位置
+	Log             string       `json:"-" gorm:"type:text"`                                                  // 运行日志，包含执行的命令和命令输出，通过单独的接口查询
+	CreatedAt       time.Time    `json:"createdAt" gorm:"type:datetime;not null;default:CURRENT_TIMESTAMP"`   // 创建时间
+	UpdatedAt       time.Time    `json:"updatedAt" gorm:"type:datetime;not null"`                             // 更新时间
 }
 
 // TableName 指定表名
 func (BackupRecord) TableName() string {
 	return "backup_records"
 }
+
+// RestoreRecord 恢复记录
+type RestoreRecord struct {
+	ID             int64        `json:"id" gorm:"primaryKey;autoIncrement"`
+	BackupRecordID int64        `json:"backupRecordId" gorm:"not null;index"`                                // 备份记录ID
+	TaskID         int64        `json:"taskId" gorm:"not null;index"`                                        // 任务ID
+	TaskName       string       `json:"taskName" gorm:"-"`                                                   // 任务名称（不映射到数据库）
+	Target         string       `json:"target" gorm:"type:varchar(255);not null;default:''"`                 // 恢复目标描述，不包含密码
+	Status         BackupStatus `json:"status" gorm:"type:varchar(20);not null"`                             // 状态
+	StartTime      time.Time    `json:"startTime" gorm:"type:datetime;not null"`                             // 开始时间
+	EndTime        time.Time    `json:"endTime" gorm:"type:datetime;not null;default:'1970-01-01 00:00:00'"` // 结束时间
+	Duration       int64        `json:"duration" gorm:"not null;default:0"`                                  // 耗时，单位毫秒
+	ErrorMessage   string       `json:"errorMessage" gorm:"type:text;not null"`                              // 错误信息
+	Output         string       `json:"output" gorm:"type:text;not null"`                                    // 恢复命令的输出（截取末尾部分）
+	CreatedAt      time.Time    `json:"createdAt" gorm:"type:datetime;not null;default:CURRENT_TIMESTAMP"`   // 创建时间
+	UpdatedAt      time.Time    `json:"updatedAt" gorm:"type:datetime;not null"`                             // 更新时间
+}
+
+// TableName 指定表名
+func (RestoreRecord) TableName() string {
+	return "restore_records"
+}
+
+// Chunk 仓库格式的文件备份中按内容寻址存储的数据块，同一存储中使用同一密钥的相同内容只保存一次
+type Chunk struct {
+	ID              int64       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Hash            string      `json:"hash" gorm:"type:varchar(64);not null;uniqueIndex:idx_chunk_hash_storage_key"`                        // 数据块内容的SHA-256，十六进制
+	StorageType     StorageType `json:"storageType" gorm:"type:varchar(20);not null;uniqueIndex:idx_chunk_hash_storage_key"`                 // 存储类型
+	EncryptionKeyID string      `json:"encryptionKeyId" gorm:"type:varchar(255);not null;default:'';uniqueIndex:idx_chunk_hash_storage_key"` // 加密使用的密钥ID，为空表示未加密，不同密钥加密的相同数据块分别保存
+	Path            string      `json:"path" gorm:"type:varchar(255);not null"`                                                              // 存储中的路径
+	Size            int64       `json:"size" gorm:"not null;default:0"`                                                                      // 原始大小，单位字节
+	StoredSize      int64       `json:"storedSize" gorm:"not null;default:0"`                                                                // 压缩和加密后的大小，单位字节
+	Compression     string      `json:"compression" gorm:"type:varchar(20);not null;default:''"`                                             // 压缩方式
+	Checksum        string      `json:"checksum" gorm:"type:varchar(64);not null;default:''"`                                                // 存储中文件（压缩和加密后）的SHA-256，为空表示未记录
+	CreatedAt       time.Time   `json:"createdAt" gorm:"type:datetime;not null;default:CURRENT_TIMESTAMP"`                                   // 创建时间
+}
+
+// TableName 指定表名
+func (Chunk) TableName() string {
+	return "chunks"
+}
+
+// BinlogFile binlog归档任务已归档的binlog文件，一次归档的多个文件保存在同一个备份记录的归档中
+type BinlogFile struct {
+	ID           int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	TaskID       int64     `json:"taskId" gorm:"not null;index"`                                             // binlog归档任务ID
+	RecordID     int64     `json:"recordId" gorm:"not null;index"`                                           // 包含该文件的备份记录ID
+	Name         string    `json:"name" gorm:"type:varchar(255);not null"`                                   // binlog文件名，如binlog.000012
+	Size         int64     `json:"size" gorm:"not null;default:0"`                                           // 文件大小，单位字节
+	CoveredUntil time.Time `json:"coveredUntil" gorm:"type:datetime;not null;default:'1970-01-01 00:00:00'"` // 文件中最后一个事件（切换文件时的ROTATE事件）的时间，此前的事件都已归档
+	CreatedAt    time.Time `json:"createdAt" gorm:"type:datetime;not null;default:CURRENT_TIMESTAMP"`        // 创建时间
+}
+
+// TableName 指定表名
+func (BinlogFile) TableName() string {
+	return "binlog_files"
+}
@@ -6,11 +6,15 @@ import (
 	backupService "backup-go/service/backup"
 	"backup-go/service/cleanup"
 	configService "backup-go/service/config"
+	"backup-go/service/integrity"
+	"backup-go/service/redact"
+	"backup-go/service/restore"
 	"backup-go/service/scheduler"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 )
 
 func main() {
@@ -18,6 +22,9 @@ func main() {
 	configPath := flag.String("config", "config.yaml", "配置文件路径")
 	flag.Parse()
 
+	// 日志输出前隐藏任务配置中的密码等敏感信息
+	log.SetOutput(redact.NewWriter(os.Stderr))
+
 	// 加载配置
 	if err := config.LoadConfig(*configPath); err != nil {
 		log.Fatalf("加载配置文件失败: %v", err)
@@ -44,6 +51,11 @@ func main() {
 		log.Printf("处理异常备份记录失败: %v", err)
 	}
 
+	// 处理异常状态的恢复记录
+	if err := restore.InitRestoreRecords(); err != nil {
+		log.Printf("处理异常恢复记录失败: %v", err)
+	}
+
 	// 启动调度器
 	backupScheduler := scheduler.GetScheduler()
 	backupScheduler.Start()
@@ -52,6 +64,10 @@ func main() {
 	cleanupSvc := cleanup.GetCleanupService()
 	cleanupSvc.Start()
 
+	// 启动完整性校验服务
+	integritySvc := integrity.GetIntegrityService()
+	integritySvc.Start()
+
 	// 设置路由
 	r := router.SetupRouter()
 
@@ -63,6 +79,8 @@ func main() {
 		backupScheduler.Stop()
 		// 停止清理服务
 		cleanupSvc.Stop()
+		// 停止完整性校验服务
+		integritySvc.Stop()
 		log.Fatalf("服务启动失败: %v", err)
 	}
 }
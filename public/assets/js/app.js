@@ -44,6 +44,9 @@ document.addEventListener('DOMContentLoaded', function() {
     // 清理按钮
     bindManualCleanupButton();
 
+    // 完整性校验按钮
+    bindIntegrityCheckButton();
+
     // 根据URL显示对应面板
     showInitialPanel();
 });
@@ -218,6 +221,9 @@ function initializeApp() {
                 
                 // 绑定手动清理按钮事件
                 bindManualCleanupButton();
+
+                // 绑定完整性校验按钮事件
+                bindIntegrityCheckButton();
                 
                 // 显示初始面板
                 showInitialPanel();
@@ -254,6 +260,9 @@ function bindEventListeners() {
     // Webhook 启用状态改变时控制表单字段
     document.getElementById('webhook-enabled').addEventListener('change', updateWebhookFormFields);
 
+    // 生成加密密钥
+    document.getElementById('btn-generate-key').addEventListener('click', generateEncryptionKey);
+
     // 测试 Webhook 事件
     document.getElementById('btn-test-webhook').addEventListener('click', testWebhook);
 
@@ -273,6 +282,18 @@ function bindEventListeners() {
     // 根据任务类型切换配置面板
     document.getElementById('task-type').addEventListener('change', toggleConfigPanels);
 
+    // 根据数据库类型切换默认端口和特有配置
+    document.getElementById('db-type').addEventListener('change', () => {
+        const dbType = document.getElementById('db-type').value;
+        const defaultPorts = { 'mysql': 3306, 'postgres': 5432, 'mongodb': 27017 };
+        document.getElementById('db-port').value = defaultPorts[dbType] || '';
+        toggleConfigPanels();
+    });
+
+    // 启用恢复校验时显示校验配置
+    document.getElementById('verify-enabled').addEventListener('change', toggleConfigPanels);
+    document.getElementById('mysql-ssl-mode').addEventListener('change', toggleConfigPanels);
+
     // Cron 表达式示例按钮
     document.querySelectorAll('.cron-example').forEach(button => {
         button.addEventListener('click', (e) => {
@@ -428,13 +449,13 @@ function showPanel(panel) {
 function toggleStorageConfigPanels() {
     const type = storageType.value;
 
-    if (type === 'local') {
-        localStorageConfig.style.display = 'block';
-        s3StorageConfig.style.display = 'none';
-    } else if (type === 's3') {
-        localStorageConfig.style.display = 'none';
-        s3StorageConfig.style.display = 'block';
-    }
+    localStorageConfig.style.display = type === 'local' ? 'block' : 'none';
+    s3StorageConfig.style.display = type === 's3' ? 'block' : 'none';
+    document.getElementById('sftp-storage-config').style.display = type === 'sftp' ? 'block' : 'none';
+    document.getElementById('webdav-storage-config').style.display = type === 'webdav' ? 'block' : 'none';
+    document.getElementById('ftp-storage-config').style.display = type === 'ftp' ? 'block' : 'none';
+    document.getElementById('azure-storage-config').style.display = type === 'azure' ? 'block' : 'none';
+    document.getElementById('gcs-storage-config').style.display = type === 'gcs' ? 'block' : 'none';
 }
 
 // 加载系统配置
@@ -474,6 +495,96 @@ function loadSystemConfig() {
                             case 'storage.s3Bucket':
                                 document.getElementById('s3-bucket').value = config.configValue;
                                 break;
+                            case 'storage.sftpHost':
+                                document.getElementById('sftp-host').value = config.configValue;
+                                break;
+                            case 'storage.sftpPort':
+                                document.getElementById('sftp-port').value = config.configValue;
+                                break;
+                            case 'storage.sftpUser':
+                                document.getElementById('sftp-user').value = config.configValue;
+                                break;
+                            case 'storage.sftpPassword':
+                                document.getElementById('sftp-password').value = config.configValue;
+                                break;
+                            case 'storage.sftpKeyPath':
+                                document.getElementById('sftp-key-path').value = config.configValue;
+                                break;
+                            case 'storage.sftpKnownHosts':
+                                document.getElementById('sftp-known-hosts').value = config.configValue;
+                                break;
+                            case 'storage.sftpBasePath':
+                                document.getElementById('sftp-base-path').value = config.configValue;
+                                break;
+                            case 'storage.webdavUrl':
+                                document.getElementById('webdav-url').value = config.configValue;
+                                break;
+                            case 'storage.webdavUser':
+                                document.getElementById('webdav-user').value = config.configValue;
+                                break;
+                            case 'storage.webdavPassword':
+                                document.getElementById('webdav-password').value = config.configValue;
+                                break;
+                            case 'storage.webdavToken':
+                                document.getElementById('webdav-token').value = config.configValue;
+                                break;
+                            case 'storage.webdavChunkSize':
+                                document.getElementById('webdav-chunk-size').value = config.configValue;
+                                break;
+                            case 'storage.ftpHost':
+                                document.getElementById('ftp-host').value = config.configValue;
+                                break;
+                            case 'storage.ftpPort':
+                                document.getElementById('ftp-port').value = config.configValue;
+                                break;
+                            case 'storage.ftpUser':
+                                document.getElementById('ftp-user').value = config.configValue;
+                                break;
+                            case 'storage.ftpPassword':
+                                document.getElementById('ftp-password').value = config.configValue;
+                                break;
+                            case 'storage.ftpTLS':
+                                document.getElementById('ftp-tls').value = config.configValue || 'none';
+                                break;
+                            case 'storage.ftpInsecureSkipVerify':
+                                document.getElementById('ftp-insecure').checked = config.configValue === 'true';
+                                break;
+                            case 'storage.ftpBasePath':
+                                document.getElementById('ftp-base-path').value = config.configValue;
+                                break;
+                            case 'storage.azureAccount':
+                                document.getElementById('azure-account').value = config.configValue;
+                                break;
+                            case 'storage.azureAccountKey':
+                                document.getElementById('azure-account-key').value = config.configValue;
+                                break;
+                            case 'storage.azureSasToken':
+                                document.getElementById('azure-sas-token').value = config.configValue;
+                                break;
+                            case 'storage.azureContainer':
+                                document.getElementById('azure-container').value = config.configValue;
+                                break;
+                            case 'storage.azureEndpoint':
+                                document.getElementById('azure-endpoint').value = config.configValue;
+                                break;
+                            case 'storage.azureBlockSize':
+                                document.getElementById('azure-block-size').value = config.configValue;
+                                break;
+                            case 'storage.azureAccessTier':
+                                document.getElementById('azure-access-tier').value = config.configValue;
+                                break;
+                            case 'storage.gcsBucket':
+                                document.getElementById('gcs-bucket').value = config.configValue;
+                                break;
+                            case 'storage.gcsCredentials':
+                                document.getElementById('gcs-credentials').value = config.configValue;
+                                break;
+                            case 'storage.gcsEndpoint':
+                                document.getElementById('gcs-endpoint').value = config.configValue;
+                                break;
+                            case 'storage.gcsStorageClass':
+                                document.getElementById('gcs-storage-class').value = config.configValue;
+                                break;
                             case 'webhook.enabled':
                                 document.getElementById('webhook-enabled').checked = config.configValue === 'true';
                                 updateWebhookFormFields();
@@ -490,6 +601,18 @@ function loadSystemConfig() {
                             case 'system.autoCleanupDays':
                                 document.getElementById('auto-cleanup-days').value = config.configValue;
                                 break;
+                            case 'system.integrityCheckSchedule':
+                                document.getElementById('integrity-check-schedule').value = config.configValue;
+                                break;
+                            case 'encryption.enabled':
+                                document.getElementById('encryption-enabled').checked = config.configValue === 'true';
+                                break;
+                            case 'encryption.keyId':
+                                document.getElementById('encryption-key-id').value = config.configValue;
+                                break;
+                            case 'encryption.keys':
+                                document.getElementById('encryption-keys').value = config.configValue;
+                                break;
                             case 'system.siteName':
                                 document.getElementById('site-name').value = config.configValue;
                                 break;
@@ -525,6 +648,34 @@ function loadSystemConfig() {
         });
 }
 
+// 生成随机AES-256密钥并加入密钥环
+function generateEncryptionKey() {
+    const keysField = document.getElementById('encryption-keys');
+    let keyring = {};
+    try {
+        keyring = JSON.parse(keysField.value.trim() || '{}');
+    } catch (error) {
+        showToast('密钥环不是有效的JSON', 'warning');
+        return;
+    }
+
+    const bytes = new Uint8Array(32);
+    crypto.getRandomValues(bytes);
+    const key = Array.from(bytes).map(b => b.toString(16).padStart(2, '0')).join('');
+
+    // 以日期作为密钥ID，重复时追加序号
+    const date = new Date().toISOString().slice(0, 10).replace(/-/g, '');
+    let keyId = `key-${date}`;
+    for (let i = 2; keyring[keyId]; i++) {
+        keyId = `key-${date}-${i}`;
+    }
+
+    keyring[keyId] = { type: 'aes', key: key };
+    keysField.value = JSON.stringify(keyring, null, 2);
+    document.getElementById('encryption-key-id').value = keyId;
+    showToast('已生成新密钥，保存配置后生效', 'success');
+}
+
 // 更新Webhook表单字段禁用状态
 function updateWebhookFormFields() {
     const webhookEnabled = document.getElementById('webhook-enabled').checked;
@@ -558,6 +709,36 @@ function saveSystemConfig() {
     const s3AccessKey = document.getElementById('s3-access-key').value;
     const s3SecretKey = document.getElementById('s3-secret-key').value;
     const s3Bucket = document.getElementById('s3-bucket').value;
+    const sftpHost = document.getElementById('sftp-host').value.trim();
+    const sftpPort = document.getElementById('sftp-port').value.trim();
+    const sftpUser = document.getElementById('sftp-user').value.trim();
+    const sftpPassword = document.getElementById('sftp-password').value;
+    const sftpKeyPath = document.getElementById('sftp-key-path').value.trim();
+    const sftpKnownHosts = document.getElementById('sftp-known-hosts').value.trim();
+    const sftpBasePath = document.getElementById('sftp-base-path').value.trim();
+    const webdavUrl = document.getElementById('webdav-url').value.trim();
+    const webdavUser = document.getElementById('webdav-user').value.trim();
+    const webdavPassword = document.getElementById('webdav-password').value;
+    const webdavToken = document.getElementById('webdav-token').value.trim();
+    const webdavChunkSize = document.getElementById('webdav-chunk-size').value.trim();
+    const ftpHost = document.getElementById('ftp-host').value.trim();
+    const ftpPort = document.getElementById('ftp-port').value.trim();
+    const ftpUser = document.getElementById('ftp-user').value.trim();
+    const ftpPassword = document.getElementById('ftp-password').value;
+    const ftpTLS = document.getElementById('ftp-tls').value;
+    const ftpInsecure = document.getElementById('ftp-insecure').checked;
+    const ftpBasePath = document.getElementById('ftp-base-path').value.trim();
+    const azureAccount = document.getElementById('azure-account').value.trim();
+    const azureAccountKey = document.getElementById('azure-account-key').value.trim();
+    const azureSasToken = document.getElementById('azure-sas-token').value.trim();
+    const azureContainer = document.getElementById('azure-container').value.trim();
+    const azureEndpoint = document.getElementById('azure-endpoint').value.trim();
+    const azureBlockSize = document.getElementById('azure-block-size').value.trim();
+    const azureAccessTier = document.getElementById('azure-access-tier').value;
+    const gcsBucket = document.getElementById('gcs-bucket').value.trim();
+    const gcsCredentials = document.getElementById('gcs-credentials').value.trim();
+    const gcsEndpoint = document.getElementById('gcs-endpoint').value.trim();
+    const gcsStorageClass = document.getElementById('gcs-storage-class').value;
     const webhookEnabled = document.getElementById('webhook-enabled').checked;
     const webhookUrl = document.getElementById('webhook-url').value;
     const webhookHeaders = document.getElementById('webhook-headers').value;
@@ -565,7 +746,54 @@ function saveSystemConfig() {
     const password = document.getElementById('system-password').value;
     const confirmPassword = document.getElementById('confirm-password').value;
     const autoCleanupDays = document.getElementById('auto-cleanup-days').value;
+    const integrityCheckSchedule = document.getElementById('integrity-check-schedule').value.trim();
     const siteName = document.getElementById('site-name').value;
+    const encryptionEnabled = document.getElementById('encryption-enabled').checked;
+    const encryptionKeyId = document.getElementById('encryption-key-id').value.trim();
+    const encryptionKeys = document.getElementById('encryption-keys').value.trim() || '{}';
+
+    // 检查加密配置
+    let keyring;
+    try {
+        keyring = JSON.parse(encryptionKeys);
+    } catch (error) {
+        showToast('密钥环不是有效的JSON', 'warning');
+        return;
+    }
+    if (encryptionEnabled && (!encryptionKeyId || !keyring[encryptionKeyId])) {
+        showToast('启用加密时，当前密钥ID必须存在于密钥环中', 'warning');
+        return;
+    }
+
+    // 检查SFTP配置
+    if (storageType === 'sftp' && (!sftpHost || !sftpUser || (!sftpPassword && !sftpKeyPath))) {
+        showToast('请填写SFTP服务器地址、用户名，以及密码或私钥文件路径', 'warning');
+        return;
+    }
+
+    // 检查WebDAV配置
+    if (storageType === 'webdav' && !webdavUrl) {
+        showToast('请填写WebDAV地址', 'warning');
+        return;
+    }
+
+    // 检查FTP配置
+    if (storageType === 'ftp' && !ftpHost) {
+        showToast('请填写FTP服务器地址', 'warning');
+        return;
+    }
+
+    // 检查Azure配置
+    if (storageType === 'azure' && ((!azureAccount && !azureEndpoint) || (!azureAccountKey && !azureSasToken))) {
+        showToast('请填写Azure存储账户（或服务端点），以及共享密钥或SAS令牌', 'warning');
+        return;
+    }
+
+    // 检查GCS配置，使用模拟器时可以不填写密钥
+    if (storageType === 'gcs' && (!gcsBucket || (!gcsCredentials && !gcsEndpoint))) {
+        showToast('请填写GCS存储桶和服务账号密钥', 'warning');
+        return;
+    }
 
     // 检查密码是否匹配
     if (password !== confirmPassword) {
@@ -616,6 +844,156 @@ function saveSystemConfig() {
             configValue: s3Bucket,
             description: 'S3存储桶'
         },
+        {
+            configKey: 'storage.sftpHost',
+            configValue: sftpHost,
+            description: 'SFTP服务器地址'
+        },
+        {
+            configKey: 'storage.sftpPort',
+            configValue: sftpPort || '22',
+            description: 'SFTP端口'
+        },
+        {
+            configKey: 'storage.sftpUser',
+            configValue: sftpUser,
+            description: 'SFTP用户名'
+        },
+        {
+            configKey: 'storage.sftpPassword',
+            configValue: sftpPassword,
+            description: 'SFTP密码，使用私钥时为私钥口令'
+        },
+        {
+            configKey: 'storage.sftpKeyPath',
+            configValue: sftpKeyPath,
+            description: 'SFTP私钥文件路径'
+        },
+        {
+            configKey: 'storage.sftpKnownHosts',
+            configValue: sftpKnownHosts,
+            description: 'known_hosts文件路径，为空时使用~/.ssh/known_hosts'
+        },
+        {
+            configKey: 'storage.sftpBasePath',
+            configValue: sftpBasePath,
+            description: 'SFTP服务器上的备份目录'
+        },
+        {
+            configKey: 'storage.webdavUrl',
+            configValue: webdavUrl,
+            description: 'WebDAV地址'
+        },
+        {
+            configKey: 'storage.webdavUser',
+            configValue: webdavUser,
+            description: 'WebDAV用户名'
+        },
+        {
+            configKey: 'storage.webdavPassword',
+            configValue: webdavPassword,
+            description: 'WebDAV密码或应用密码'
+        },
+        {
+            configKey: 'storage.webdavToken',
+            configValue: webdavToken,
+            description: 'WebDAV Bearer令牌，设置后不使用用户名密码'
+        },
+        {
+            configKey: 'storage.webdavChunkSize',
+            configValue: webdavChunkSize || '0',
+            description: '分块上传的分块大小（MB），0表示不分块，仅Nextcloud/ownCloud支持'
+        },
+        {
+            configKey: 'storage.ftpHost',
+            configValue: ftpHost,
+            description: 'FTP服务器地址'
+        },
+        {
+            configKey: 'storage.ftpPort',
+            configValue: ftpPort,
+            description: 'FTP端口，为空时普通FTP和显式TLS使用21，隐式TLS使用990'
+        },
+        {
+            configKey: 'storage.ftpUser',
+            configValue: ftpUser,
+            description: 'FTP用户名，为空时匿名登录'
+        },
+        {
+            configKey: 'storage.ftpPassword',
+            configValue: ftpPassword,
+            description: 'FTP密码'
+        },
+        {
+            configKey: 'storage.ftpTLS',
+            configValue: ftpTLS,
+            description: 'FTP加密方式：none、explicit（显式TLS）、implicit（隐式TLS）'
+        },
+        {
+            configKey: 'storage.ftpInsecureSkipVerify',
+            configValue: ftpInsecure ? 'true' : 'false',
+            description: '是否跳过FTPS服务器证书校验'
+        },
+        {
+            configKey: 'storage.ftpBasePath',
+            configValue: ftpBasePath,
+            description: 'FTP服务器上的备份目录'
+        },
+        {
+            configKey: 'storage.azureAccount',
+            configValue: azureAccount,
+            description: 'Azure存储账户名'
+        },
+        {
+            configKey: 'storage.azureAccountKey',
+            configValue: azureAccountKey,
+            description: 'Azure存储账户共享密钥'
+        },
+        {
+            configKey: 'storage.azureSasToken',
+            configValue: azureSasToken,
+            description: 'Azure SAS令牌，未配置共享密钥时使用'
+        },
+        {
+            configKey: 'storage.azureContainer',
+            configValue: azureContainer,
+            description: 'Azure容器名称'
+        },
+        {
+            configKey: 'storage.azureEndpoint',
+            configValue: azureEndpoint,
+            description: 'Azure Blob服务端点，为空时使用https://<账户名>.blob.core.windows.net'
+        },
+        {
+            configKey: 'storage.azureBlockSize',
+            configValue: azureBlockSize || '8',
+            description: 'Azure块上传的块大小（MB）'
+        },
+        {
+            configKey: 'storage.azureAccessTier',
+            configValue: azureAccessTier,
+            description: 'Azure访问层：Hot、Cool、Cold、Archive，为空时使用账户默认值'
+        },
+        {
+            configKey: 'storage.gcsBucket',
+            configValue: gcsBucket,
+            description: 'GCS存储桶名称'
+        },
+        {
+            configKey: 'storage.gcsCredentials',
+            configValue: gcsCredentials,
+            description: 'GCS服务账号密钥，JSON内容或密钥文件路径'
+        },
+        {
+            configKey: 'storage.gcsEndpoint',
+            configValue: gcsEndpoint,
+            description: 'GCS服务端点，为空时使用https://storage.googleapis.com'
+        },
+        {
+            configKey: 'storage.gcsStorageClass',
+            configValue: gcsStorageClass,
+            description: 'GCS存储类别：STANDARD、NEARLINE、COLDLINE、ARCHIVE，为空时使用存储桶默认值'
+        },
         {
             configKey: 'webhook.enabled',
             configValue: webhookEnabled ? 'true' : 'false',
@@ -641,10 +1019,30 @@ function saveSystemConfig() {
             configValue: autoCleanupDays,
             description: '自动清理时间（天）'
         },
+        {
+            configKey: 'system.integrityCheckSchedule',
+            configValue: integrityCheckSchedule,
+            description: '完整性校验的Cron表达式，为空表示不定时校验'
+        },
         {
             configKey: 'system.siteName',
             configValue: siteName,
             description: '站点名称'
+        },
+        {
+            configKey: 'encryption.enabled',
+            configValue: encryptionEnabled ? 'true' : 'false',
+            description: '是否加密备份文件'
+        },
+        {
+            configKey: 'encryption.keyId',
+            configValue: encryptionKeyId,
+            description: '新备份使用的加密密钥ID'
+        },
+        {
+            configKey: 'encryption.keys',
+            configValue: encryptionKeys,
+            description: '加密密钥环，JSON格式，保留历史密钥用于解密'
         }
     ];
 
@@ -851,6 +1249,7 @@ function renderTaskList(tasks) {
                 <button class="btn btn-sm btn-primary btn-icon btn-edit" data-id="${task.id}">编辑</button>
                     <button class="btn btn-sm btn-success btn-icon btn-execute" data-id="${task.id}" ${!task.enabled ? 'disabled' : ''}>执行</button>
                 <button class="btn btn-sm btn-info btn-icon btn-records" data-id="${task.id}">记录</button>
+                ${isMySQLTask(task) ? `<button class="btn btn-sm btn-outline-warning btn-icon btn-restore-time" data-id="${task.id}">时间点恢复</button>` : ''}
                 <button class="btn btn-sm btn-danger btn-icon btn-delete" data-id="${task.id}">删除</button>
                 </div>
             </td>
@@ -884,6 +1283,13 @@ function renderTaskList(tasks) {
         });
     });
 
+    document.querySelectorAll('.btn-restore-time').forEach(btn => {
+        btn.addEventListener('click', function () {
+            const id = parseInt(this.dataset.id);
+            restoreTaskToTime(id);
+        });
+    });
+
     document.querySelectorAll('.btn-delete').forEach(btn => {
         btn.addEventListener('click', function () {
             const id = parseInt(this.dataset.id);
@@ -1174,12 +1580,17 @@ function renderRecords(records) {
                 <td>${formatDateTime(record.startTime)}</td>
                     <td>${(!record.endTime || new Date(record.endTime).getFullYear() <= 1970 || record.status === 'running') ? '<span class="text-muted">执行中</span>' : formatDateTime(record.endTime)}</td>
                 <td>${executionTime}</td>
-                <td><span class="badge ${statusClass}">${status}</span></td>
+                <td><span class="badge ${statusClass}">${status}</span>${getVerifyBadge(record.verifyStatus)}</td>
                 <td>${record.fileSize ? formatFileSize(record.fileSize) : '-'}</td>
                 <td>
                     <div class="record-buttons-container">
                         <button class="btn btn-sm btn-primary btn-icon btn-view-record" data-id="${record.id}">查看</button>
                         ${record.filePath && record.status !== 'cleaned' ? `<button class="btn btn-sm btn-success btn-icon btn-download" data-id="${record.id}">下载</button>` : ''}
+                        ${record.filePath && record.status === 'success' && record.taskType === 'file' ? `<button class="btn btn-sm btn-info btn-icon btn-browse-record" data-id="${record.id}">浏览</button>` : ''}
+                        ${record.filePath && record.status === 'success' && (record.taskType === 'database' || record.taskType === 'file') ? `<button class="btn btn-sm btn-warning btn-icon btn-restore-record" data-id="${record.id}" data-type="${record.taskType}">恢复</button>` : ''}
+                        ${record.filePath && record.status === 'success' && record.taskType === 'database' && record.verifyStatus !== 'verifying' ? `<button class="btn btn-sm btn-secondary btn-icon btn-verify-record" data-id="${record.id}">校验</button>` : ''}
+                        ${record.filePath && record.checksum && (record.status === 'success' || record.status === 'corrupted') ? `<button class="btn btn-sm btn-outline-secondary btn-icon btn-check-record" data-id="${record.id}">完整性</button>` : ''}
+                        ${(record.taskType === 'database' || record.taskType === 'binlog') && record.status !== 'running' && record.status !== 'pending' ? `<button class="btn btn-sm btn-outline-dark btn-icon btn-record-log" data-id="${record.id}">日志</button>` : ''}
                         <button class="btn btn-sm btn-danger btn-icon btn-delete-record" data-id="${record.id}">删除</button>
                     </div>
                 </td>
@@ -1212,6 +1623,45 @@ function renderRecords(records) {
             deleteRecord(id);
         });
     });
+
+    document.querySelectorAll('.btn-browse-record').forEach(btn => {
+        btn.addEventListener('click', function () {
+            const id = parseInt(this.dataset.id);
+            browseRecordEntries(id);
+        });
+    });
+
+    document.querySelectorAll('.btn-verify-record').forEach(btn => {
+        btn.addEventListener('click', function () {
+            const id = parseInt(this.dataset.id);
+            verifyRecord(id);
+        });
+    });
+
+    document.querySelectorAll('.btn-check-record').forEach(btn => {
+        btn.addEventListener('click', function () {
+            const id = parseInt(this.dataset.id);
+            checkRecordIntegrity(id);
+        });
+    });
+
+    document.querySelectorAll('.btn-record-log').forEach(btn => {
+        btn.addEventListener('click', function () {
+            const id = parseInt(this.dataset.id);
+            showRecordLog(id);
+        });
+    });
+
+    document.querySelectorAll('.btn-restore-record').forEach(btn => {
+        btn.addEventListener('click', function () {
+            const id = parseInt(this.dataset.id);
+            if (this.dataset.type === 'file') {
+                restoreFileRecord(id);
+            } else {
+                restoreDatabaseRecord(id);
+            }
+        });
+    });
 }
 
 // 渲染分页
@@ -1351,8 +1801,60 @@ function editTask(id) {
         document.getElementById('db-user').value = sourceInfo.user || 'root';
         document.getElementById('db-password').value = sourceInfo.password || '';
         document.getElementById('db-name').value = sourceInfo.database || '';
+        document.getElementById('db-schema').value = sourceInfo.schema || '';
+        document.getElementById('db-format').value = sourceInfo.format || 'plain';
+        document.getElementById('db-uri').value = sourceInfo.uri || '';
+        document.getElementById('db-auth-source').value = sourceInfo.authSource || '';
+        document.getElementById('db-compression').value = sourceInfo.compression || 'none';
+        document.getElementById('db-compression-level').value = sourceInfo.compressionLevel || '';
+
+        const mysql = sourceInfo.mysql || {};
+        document.getElementById('mysql-flavor').value = mysql.flavor || '';
+        document.getElementById('mysql-ssl-mode').value = mysql.sslMode || 'DISABLED';
+        document.getElementById('mysql-ssl-ca').value = mysql.sslCa || '';
+        document.getElementById('mysql-ssl-cert').value = mysql.sslCert || '';
+        document.getElementById('mysql-ssl-key').value = mysql.sslKey || '';
+        document.getElementById('mysql-single-transaction').checked = !!mysql.singleTransaction;
+        document.getElementById('mysql-routines').checked = !!mysql.routines;
+        document.getElementById('mysql-triggers').checked = !mysql.skipTriggers;
+        document.getElementById('mysql-events').checked = !!mysql.events;
+        document.getElementById('mysql-binlog-position').checked = !!mysql.binlogPosition;
+        document.getElementById('mysql-tables').value = mysql.tables ? mysql.tables.join('\n') : '';
+        document.getElementById('mysql-exclude-tables').value = mysql.excludeTables ? mysql.excludeTables.join('\n') : '';
+        document.getElementById('mysql-where').value = mysql.where || '';
+
+        const verify = sourceInfo.verify || {};
+        document.getElementById('verify-enabled').checked = !!verify.enabled;
+        document.getElementById('verify-database').value = verify.target && verify.target.database ? verify.target.database : '';
+        document.getElementById('verify-schedule').value = verify.schedule || '';
+        document.getElementById('verify-min-tables').value = verify.minTables || 1;
+        document.getElementById('verify-queries').value = verify.queries ? verify.queries.join('\n') : '';
     } else if (task.type === 'file') {
         document.getElementById('file-paths').value = sourceInfo.paths ? sourceInfo.paths.join('\n') : '';
+        document.getElementById('file-format').value = sourceInfo.format || 'zip';
+        document.getElementById('file-compression').value = sourceInfo.compression || 'gzip';
+        document.getElementById('file-compression-level').value = sourceInfo.compressionLevel || '';
+        document.getElementById('file-include').value = sourceInfo.include ? sourceInfo.include.join('\n') : '';
+        document.getElementById('file-exclude').value = sourceInfo.exclude ? sourceInfo.exclude.join('\n') : '';
+        document.getElementById('file-max-size').value = sourceInfo.maxFileSize ? +(sourceInfo.maxFileSize / 1024 / 1024).toFixed(2) : '';
+        document.getElementById('file-skip-hidden').checked = !!sourceInfo.skipHidden;
+        document.getElementById('file-backup-mode').value = sourceInfo.mode || 'full';
+        document.getElementById('file-full-interval').value = sourceInfo.fullInterval || '';
+    } else if (task.type === 'redis') {
+        document.getElementById('redis-host').value = sourceInfo.host || 'localhost';
+        document.getElementById('redis-port').value = sourceInfo.port || 6379;
+        document.getElementById('redis-username').value = sourceInfo.username || '';
+        document.getElementById('redis-password').value = sourceInfo.password || '';
+        document.getElementById('redis-mode').value = sourceInfo.mode || 'sync';
+        document.getElementById('redis-rdb-path').value = sourceInfo.rdbPath || '';
+    } else if (task.type === 'sqlite') {
+        document.getElementById('sqlite-path').value = sourceInfo.path || '';
+    } else if (task.type === 'binlog') {
+        fillBinlogTaskOptions();
+        document.getElementById('binlog-database-task').value = sourceInfo.databaseTaskId || '';
+        document.getElementById('binlog-flush-logs').checked = !!sourceInfo.flushLogs;
+        document.getElementById('binlog-compression').value = sourceInfo.compression || 'none';
+        document.getElementById('binlog-compression-level').value = sourceInfo.compressionLevel || '';
     }
 
     // 切换配置面板
@@ -1521,8 +2023,17 @@ function showRecordDetails(id) {
                             <p><strong>开始时间:</strong> ${formatDateTime(record.startTime)}</p>
                             <p><strong>结束时间:</strong> ${(!record.endTime || new Date(record.endTime).getFullYear() <= 1970 || record.status === 'running') ? '执行中' : formatDateTime(record.endTime)}</p>
                             <p><strong>文件大小:</strong> ${record.fileSize ? formatFileSize(record.fileSize) : '无文件'}</p>
+                            ${record.compression ? `<p><strong>压缩方式:</strong> ${escapeHtml(record.compression)}</p>` : ''}
+                            ${record.backupMode ? `<p><strong>备份模式:</strong> ${{ full: '完整备份', incremental: '增量备份', differential: '差异备份' }[record.backupMode] || escapeHtml(record.backupMode)}${record.parentId ? `（基于记录 #${record.parentId}）` : ''}</p>` : ''}
+                            ${record.originalSize ? `<p><strong>压缩前/后大小:</strong> ${formatFileSize(record.originalSize)} / ${formatFileSize(record.compressedSize)}（${(record.compressedSize / record.originalSize * 100).toFixed(1)}%）</p>` : ''}
                             <p><strong>文件路径:</strong> ${record.filePath || '无文件'}</p>
+                            ${record.encryptionKeyId ? `<p><strong>加密密钥:</strong> ${escapeHtml(record.encryptionKeyId)}</p>` : ''}
+                            ${record.checksum ? `<p><strong>SHA-256:</strong> <code class="small">${escapeHtml(record.checksum)}</code></p>` : ''}
+                            ${record.checkedAt ? `<p><strong>完整性校验:</strong> ${record.status === 'corrupted' ? '<span class="badge bg-danger">失败</span>' : '<span class="badge bg-success">通过</span>'} ${formatDateTime(record.checkedAt)}</p>` : ''}
                             <p><strong>错误信息:</strong> ${record.errorMessage || '无错误'}</p>
+                            ${record.summary ? `<p><strong>备份摘要:</strong></p><pre class="small bg-light p-2" style="white-space: pre-wrap; max-height: 200px;">${escapeHtml(record.summary)}</pre>` : ''}
+                            ${record.verifyStatus ? `<p><strong>恢复校验:</strong> ${getVerifyBadge(record.verifyStatus)} ${record.verifiedAt ? formatDateTime(record.verifiedAt) : ''}</p>` : ''}
+                            ${record.verifyMessage ? `<pre class="small bg-light p-2" style="white-space: pre-wrap;">${escapeHtml(record.verifyMessage)}</pre>` : ''}
                         </div>
                     `,
                     icon: 'info',
@@ -1560,6 +2071,60 @@ function saveTask() {
                 password: document.getElementById('db-password').value,
                 database: document.getElementById('db-name').value
             };
+
+            // 压缩配置，MongoDB归档自带gzip压缩
+            if (sourceInfo.type !== 'mongodb') {
+                sourceInfo.compression = document.getElementById('db-compression').value;
+                sourceInfo.compressionLevel = parseInt(document.getElementById('db-compression-level').value) || 0;
+            }
+
+            // MySQL特有的配置
+            if (sourceInfo.type === 'mysql') {
+                sourceInfo.mysql = {
+                    flavor: document.getElementById('mysql-flavor').value,
+                    sslMode: document.getElementById('mysql-ssl-mode').value,
+                    sslCa: document.getElementById('mysql-ssl-ca').value.trim(),
+                    sslCert: document.getElementById('mysql-ssl-cert').value.trim(),
+                    sslKey: document.getElementById('mysql-ssl-key').value.trim(),
+                    singleTransaction: document.getElementById('mysql-single-transaction').checked,
+                    routines: document.getElementById('mysql-routines').checked,
+                    skipTriggers: !document.getElementById('mysql-triggers').checked,
+                    events: document.getElementById('mysql-events').checked,
+                    binlogPosition: document.getElementById('mysql-binlog-position').checked,
+                    tables: splitLines(document.getElementById('mysql-tables').value),
+                    excludeTables: splitLines(document.getElementById('mysql-exclude-tables').value),
+                    where: document.getElementById('mysql-where').value.trim()
+                };
+            }
+
+            // PostgreSQL特有的配置
+            if (sourceInfo.type === 'postgres') {
+                sourceInfo.schema = document.getElementById('db-schema').value.trim();
+                sourceInfo.format = document.getElementById('db-format').value;
+            }
+
+            // MongoDB特有的配置
+            if (sourceInfo.type === 'mongodb') {
+                sourceInfo.uri = document.getElementById('db-uri').value.trim();
+                sourceInfo.authSource = document.getElementById('db-auth-source').value.trim();
+            }
+
+            // 恢复校验配置
+            if (document.getElementById('verify-enabled').checked) {
+                const verifyDatabase = document.getElementById('verify-database').value.trim();
+                sourceInfo.verify = {
+                    enabled: true,
+                    schedule: document.getElementById('verify-schedule').value.trim(),
+                    minTables: parseInt(document.getElementById('verify-min-tables').value) || 1,
+                    queries: document.getElementById('verify-queries').value
+                        .split('\n')
+                        .map(q => q.trim())
+                        .filter(q => q)
+                };
+                if (verifyDatabase) {
+                    sourceInfo.verify.target = { database: verifyDatabase };
+                }
+            }
         } else if (type === 'file') {
             const paths = document.getElementById('file-paths').value
                 .split('\n')
@@ -1567,7 +2132,41 @@ function saveTask() {
                 .filter(p => p);
 
             sourceInfo = {
-                paths: paths
+                paths: paths,
+                format: document.getElementById('file-format').value,
+                compression: document.getElementById('file-compression').value,
+                compressionLevel: parseInt(document.getElementById('file-compression-level').value) || 0,
+                include: splitLines(document.getElementById('file-include').value),
+                exclude: splitLines(document.getElementById('file-exclude').value),
+                maxFileSize: Math.round((parseFloat(document.getElementById('file-max-size').value) || 0) * 1024 * 1024),
+                skipHidden: document.getElementById('file-skip-hidden').checked,
+                mode: document.getElementById('file-backup-mode').value,
+                fullInterval: parseInt(document.getElementById('file-full-interval').value) || 0
+            };
+
+            if (sourceInfo.format === 'zip' && sourceInfo.compression === 'zstd') {
+                showToast('ZIP格式不支持zstd压缩，请选择tar格式', 'warning');
+                return;
+            }
+        } else if (type === 'redis') {
+            sourceInfo = {
+                host: document.getElementById('redis-host').value,
+                port: parseInt(document.getElementById('redis-port').value) || 6379,
+                username: document.getElementById('redis-username').value,
+                password: document.getElementById('redis-password').value,
+                mode: document.getElementById('redis-mode').value,
+                rdbPath: document.getElementById('redis-rdb-path').value.trim()
+            };
+        } else if (type === 'sqlite') {
+            sourceInfo = {
+                path: document.getElementById('sqlite-path').value.trim()
+            };
+        } else if (type === 'binlog') {
+            sourceInfo = {
+                databaseTaskId: parseInt(document.getElementById('binlog-database-task').value) || 0,
+                flushLogs: document.getElementById('binlog-flush-logs').checked,
+                compression: document.getElementById('binlog-compression').value,
+                compressionLevel: parseInt(document.getElementById('binlog-compression-level').value) || 0
             };
         }
 
@@ -1590,7 +2189,9 @@ function saveTask() {
         }
 
         if (type === 'database') {
-            if (!sourceInfo.host || !sourceInfo.user) {
+            // MongoDB使用连接字符串时无需填写主机和用户名
+            const hasURI = sourceInfo.type === 'mongodb' && sourceInfo.uri;
+            if (!hasURI && (!sourceInfo.host || (!sourceInfo.user && sourceInfo.type !== 'mongodb'))) {
                 showToast('请完整填写数据库配置', 'warning');
                 return;
             }
@@ -1599,6 +2200,21 @@ function saveTask() {
                 showToast('请输入至少一个文件路径', 'warning');
                 return;
             }
+        } else if (type === 'redis') {
+            if (!sourceInfo.host) {
+                showToast('请输入Redis主机', 'warning');
+                return;
+            }
+        } else if (type === 'sqlite') {
+            if (!sourceInfo.path) {
+                showToast('请输入数据库文件路径', 'warning');
+                return;
+            }
+        } else if (type === 'binlog') {
+            if (!sourceInfo.databaseTaskId) {
+                showToast('请选择MySQL备份任务', 'warning');
+                return;
+            }
         }
 
         // 构建任务对象
@@ -1695,24 +2311,90 @@ function resetTaskForm() {
     toggleConfigPanels();
 }
 
+// 按行拆分文本，去掉空行
+function splitLines(text) {
+    return text
+        .split('\n')
+        .map(line => line.trim())
+        .filter(line => line);
+}
+
 // 切换配置面板
 function toggleConfigPanels() {
     const type = document.getElementById('task-type').value;
 
+    document.getElementById('redis-config').style.display = type === 'redis' ? 'block' : 'none';
+    document.getElementById('sqlite-config').style.display = type === 'sqlite' ? 'block' : 'none';
+    document.getElementById('binlog-config').style.display = type === 'binlog' ? 'block' : 'none';
+    if (type === 'binlog') {
+        fillBinlogTaskOptions();
+    }
+    document.getElementById('config-backup-config').style.display = type === 'config' ? 'block' : 'none';
+
     if (type === 'database') {
         document.getElementById('database-config').style.display = 'block';
         document.getElementById('file-config').style.display = 'none';
+
+        const dbType = document.getElementById('db-type').value;
+        document.getElementById('mysql-options').style.display = dbType === 'mysql' ? 'block' : 'none';
+        document.getElementById('mysql-ssl-files').style.display = document.getElementById('mysql-ssl-mode').value === 'DISABLED' ? 'none' : 'flex';
+        document.getElementById('postgres-options').style.display = dbType === 'postgres' ? 'block' : 'none';
+        document.getElementById('mongodb-options').style.display = dbType === 'mongodb' ? 'block' : 'none';
+        document.getElementById('db-compression-options').style.display = dbType === 'mongodb' ? 'none' : 'flex';
+        document.getElementById('verify-options').style.display = document.getElementById('verify-enabled').checked ? 'block' : 'none';
     } else if (type === 'file') {
         document.getElementById('database-config').style.display = 'none';
         document.getElementById('file-config').style.display = 'block';
+    } else {
+        document.getElementById('database-config').style.display = 'none';
+        document.getElementById('file-config').style.display = 'none';
+    }
+}
+
+// 是否为MySQL数据库备份任务
+function isMySQLTask(task) {
+    if (task.type !== 'database') {
+        return false;
+    }
+    try {
+        return (JSON.parse(task.sourceInfo).type || 'mysql') === 'mysql';
+    } catch (error) {
+        return false;
     }
 }
 
+// 填充binlog归档任务可选的MySQL备份任务，保留当前选择
+function fillBinlogTaskOptions() {
+    const select = document.getElementById('binlog-database-task');
+    const selected = select.value;
+    select.innerHTML = tasksList
+        .filter(isMySQLTask)
+        .map(task => `<option value="${task.id}">${escapeHtml(task.name)} (#${task.id})</option>`)
+        .join('');
+    if (selected) {
+        select.value = selected;
+    }
+}
+
+// 恢复校验状态标记
+function getVerifyBadge(verifyStatus) {
+    const badges = {
+        'verifying': '<span class="badge bg-info ms-1">校验中</span>',
+        'verified': '<span class="badge bg-success ms-1">已校验</span>',
+        'verify_failed': '<span class="badge bg-danger ms-1">校验失败</span>'
+    };
+    return badges[verifyStatus] || '';
+}
+
 // 辅助函数
 function getBackupTypeName(type) {
     const types = {
         'database': '数据库备份',
-        'file': '文件备份'
+        'file': '文件备份',
+        'redis': 'Redis快照',
+        'sqlite': 'SQLite在线备份',
+        'binlog': 'MySQL binlog归档',
+        'config': '系统配置备份'
     };
     return types[type] || type;
 }
@@ -1724,7 +2406,8 @@ function getStatusName(status) {
         'success': '成功',
         'failed': '失败',
         'cancelled': '已取消',
-        'cleaned': '已清理'
+        'cleaned': '已清理',
+        'corrupted': '已损坏'
     };
     return statuses[status] || status;
 }
@@ -2218,6 +2901,371 @@ function downloadRecord(id) {
         });
 }
 
+// 校验数据库备份能否恢复
+function verifyRecord(id) {
+    Swal.fire({
+        title: '校验备份',
+        text: '将备份导入临时数据库并执行检查，校验完成后临时数据库会被删除。是否继续？',
+        icon: 'question',
+        showCancelButton: true,
+        confirmButtonText: '开始校验',
+        cancelButtonText: '取消'
+    }).then((result) => {
+        if (!result.isConfirmed) {
+            return;
+        }
+
+        apiRequest('/api/records/testRestore', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ id: id })
+        })
+            .then(result => {
+                if (result.code === 200) {
+                    showToast('校验已开始，完成后可在记录详情中查看结果', 'success');
+                    loadRecords(currentPage, currentPageSize, currentTaskId, false);
+                } else {
+                    showToast(`校验失败: ${result.msg}`, 'danger');
+                }
+            })
+            .catch(error => {
+                console.error('Error:', error);
+                showToast(`校验失败: ${error.message}`, 'danger');
+            });
+    });
+}
+
+// 重新读取备份文件校验SHA-256
+function checkRecordIntegrity(id) {
+    showLoading('正在校验备份文件，请稍候...');
+
+    apiRequest('/api/records/verify', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ id: id })
+    })
+        .then(result => {
+            hideLoading();
+            if (result.code !== 200) {
+                showToast(`完整性校验失败: ${result.msg}`, 'danger');
+                return;
+            }
+
+            const check = result.data;
+            if (check.result === 'passed') {
+                showToast('完整性校验通过', 'success');
+            } else if (check.result === 'corrupted') {
+                Swal.fire({
+                    title: '备份文件已损坏',
+                    text: check.message,
+                    icon: 'error',
+                    confirmButtonText: '确定'
+                });
+            } else {
+                showToast(`已跳过: ${check.message}`, 'warning');
+            }
+            loadRecords(currentPage, currentPageSize, currentTaskId, false);
+        })
+        .catch(error => {
+            hideLoading();
+            console.error('Error:', error);
+            showToast(`完整性校验失败: ${error.message}`, 'danger');
+        });
+}
+
+// 查看备份记录的运行日志
+function showRecordLog(id) {
+    apiRequest(`/api/records/log?id=${id}`, {}, false, '')
+        .then(result => {
+            if (!result) return;
+
+            if (result.code !== 200) {
+                showToast('加载运行日志失败: ' + result.msg, 'danger');
+                return;
+            }
+
+            const content = result.data.log;
+            Swal.fire({
+                title: `运行日志 #${id}`,
+                html: content
+                    ? `<pre class="small bg-light p-2 text-start" style="white-space: pre-wrap; max-height: 60vh; overflow: auto;">${escapeHtml(content)}</pre>`
+                    : '<p class="text-muted">该备份没有运行日志</p>',
+                width: '60rem',
+                confirmButtonText: '关闭'
+            });
+        })
+        .catch(error => {
+            console.error('Error:', error);
+            showToast('加载运行日志失败: ' + error.message, 'danger');
+        });
+}
+
+// 恢复数据库备份
+function restoreDatabaseRecord(id) {
+    Swal.fire({
+        title: '恢复数据库备份',
+        html: `
+            <div class="text-start">
+                <p class="text-muted small">留空的字段将使用原任务的数据库配置</p>
+                <input id="restore-host" class="form-control mb-2" placeholder="目标主机">
+                <input id="restore-port" type="number" class="form-control mb-2" placeholder="目标端口">
+                <input id="restore-user" class="form-control mb-2" placeholder="用户名">
+                <input id="restore-password" type="password" class="form-control mb-2" placeholder="密码">
+                <input id="restore-database" class="form-control mb-2" placeholder="目标数据库名">
+                <div class="form-check">
+                    <input class="form-check-input" type="checkbox" id="restore-clean">
+                    <label class="form-check-label" for="restore-clean">恢复前删除已存在的对象</label>
+                </div>
+            </div>
+        `,
+        icon: 'warning',
+        showCancelButton: true,
+        confirmButtonText: '开始恢复',
+        cancelButtonText: '取消',
+        confirmButtonColor: '#d33',
+        preConfirm: () => {
+            return {
+                id: id,
+                target: {
+                    host: document.getElementById('restore-host').value.trim(),
+                    port: parseInt(document.getElementById('restore-port').value) || 0,
+                    user: document.getElementById('restore-user').value.trim(),
+                    password: document.getElementById('restore-password').value,
+                    database: document.getElementById('restore-database').value.trim()
+                },
+                clean: document.getElementById('restore-clean').checked
+            };
+        }
+    }).then((result) => {
+        if (!result.isConfirmed) {
+            return;
+        }
+
+        apiRequest('/api/records/restore', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify(result.value)
+        })
+            .then(result => {
+                if (result.code === 200) {
+                    showToast(`恢复已开始，恢复记录ID: ${result.data.id}`, 'success');
+                } else {
+                    showToast(`恢复失败: ${result.msg}`, 'danger');
+                }
+            })
+            .catch(error => {
+                console.error('Error:', error);
+                showToast(`恢复失败: ${error.message}`, 'danger');
+            });
+    });
+}
+
+// 将MySQL任务恢复到指定时间点
+function restoreTaskToTime(id) {
+    Swal.fire({
+        title: '时间点恢复',
+        html: `
+            <div class="text-start">
+                <p class="text-muted small">导入该时间之前最近的完整备份，再重放归档的binlog到该时间（服务器时区）。留空的连接字段将使用原任务的配置，数据库名不能修改</p>
+                <input id="restore-time" type="datetime-local" step="1" class="form-control mb-2">
+                <input id="restore-host" class="form-control mb-2" placeholder="目标主机">
+                <input id="restore-port" type="number" class="form-control mb-2" placeholder="目标端口">
+                <input id="restore-user" class="form-control mb-2" placeholder="用户名">
+                <input id="restore-password" type="password" class="form-control mb-2" placeholder="密码">
+            </div>
+        `,
+        icon: 'warning',
+        showCancelButton: true,
+        confirmButtonText: '开始恢复',
+        cancelButtonText: '取消',
+        confirmButtonColor: '#d33',
+        preConfirm: () => {
+            const time = document.getElementById('restore-time').value;
+            if (!time) {
+                Swal.showValidationMessage('请选择恢复时间');
+                return false;
+            }
+            return {
+                taskId: id,
+                time: time,
+                target: {
+                    host: document.getElementById('restore-host').value.trim(),
+                    port: parseInt(document.getElementById('restore-port').value) || 0,
+                    user: document.getElementById('restore-user').value.trim(),
+                    password: document.getElementById('restore-password').value
+                }
+            };
+        }
+    }).then((result) => {
+        if (!result.isConfirmed) {
+            return;
+        }
+
+        apiRequest('/api/records/restoreToTime', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify(result.value)
+        })
+            .then(result => {
+                if (result.code === 200) {
+                    showToast(`恢复已开始，恢复记录ID: ${result.data.id}`, 'success');
+                } else {
+                    showToast(`恢复失败: ${result.msg}`, 'danger');
+                }
+            })
+            .catch(error => {
+                console.error('Error:', error);
+                showToast(`恢复失败: ${error.message}`, 'danger');
+            });
+    });
+}
+
+// 浏览文件备份中的条目
+function browseRecordEntries(id) {
+    Swal.fire({
+        title: '正在读取备份内容...',
+        text: '大文件需要先从存储下载，请稍候',
+        allowOutsideClick: false,
+        didOpen: () => {
+            Swal.showLoading();
+        }
+    });
+
+    apiRequest(`/api/records/entries?id=${id}`, {}, false)
+        .then(result => {
+            if (result.code !== 200) {
+                Swal.fire({ title: '读取失败', text: result.msg, icon: 'error' });
+                return;
+            }
+
+            const token = localStorage.getItem('backupSystemAuth') || '';
+            const rows = result.data.entries.map(entry => {
+                const url = `/api/records/extract?id=${id}&path=${encodeURIComponent(entry.path)}&token=${encodeURIComponent(token)}`;
+                // 符号链接显示链接目标，不提供下载
+                if (entry.linkname) {
+                    return `
+                        <tr>
+                            <td class="text-break">🔗 ${escapeHtml(entry.path)} → ${escapeHtml(entry.linkname)}</td>
+                            <td class="text-nowrap">-</td>
+                            <td class="text-nowrap">${entry.modTime && !entry.modTime.startsWith('0001') ? formatDateTime(entry.modTime) : '-'}</td>
+                            <td></td>
+                        </tr>
+                    `;
+                }
+                return `
+                    <tr>
+                        <td class="text-break">${entry.isDir ? '📁' : '📄'} ${escapeHtml(entry.path)}</td>
+                        <td class="text-nowrap">${entry.isDir ? '-' : formatFileSize(entry.size)}</td>
+                        <td class="text-nowrap">${entry.modTime && !entry.modTime.startsWith('0001') ? formatDateTime(entry.modTime) : '-'}</td>
+                        <td><a href="${url}" class="btn btn-sm btn-success">${entry.isDir ? '打包下载' : '下载'}</a></td>
+                    </tr>
+                `;
+            }).join('');
+
+            Swal.fire({
+                title: `备份内容（${result.data.total} 项）`,
+                width: '80%',
+                html: `
+                    <div class="table-responsive text-start" style="max-height: 60vh;">
+                        <table class="table table-sm table-hover">
+                            <thead><tr><th>路径</th><th>大小</th><th>修改时间</th><th>操作</th></tr></thead>
+                            <tbody>${rows}</tbody>
+                        </table>
+                    </div>
+                `,
+                confirmButtonText: '关闭'
+            });
+        })
+        .catch(error => {
+            console.error('Error:', error);
+            Swal.fire({ title: '读取失败', text: error.message, icon: 'error' });
+        });
+}
+
+// 恢复文件备份
+function restoreFileRecord(id) {
+    Swal.fire({
+        title: '恢复文件备份',
+        html: `
+            <div class="text-start">
+                <div class="form-check mb-2">
+                    <input class="form-check-input" type="checkbox" id="restore-original">
+                    <label class="form-check-label" for="restore-original">恢复到原始路径</label>
+                </div>
+                <input id="restore-target-dir" class="form-control mb-2" placeholder="目标目录，如 /tmp/restore">
+                <select id="restore-conflict-mode" class="form-select mb-2">
+                    <option value="skip">跳过已存在的文件</option>
+                    <option value="overwrite">覆盖已存在的文件</option>
+                    <option value="keepBoth">保留两者（重命名恢复的文件）</option>
+                </select>
+                <textarea id="restore-paths" class="form-control" rows="3" placeholder="只恢复指定的文件或目录，每行一个，留空恢复全部"></textarea>
+            </div>
+        `,
+        icon: 'warning',
+        showCancelButton: true,
+        confirmButtonText: '开始恢复',
+        cancelButtonText: '取消',
+        confirmButtonColor: '#d33',
+        preConfirm: () => {
+            const original = document.getElementById('restore-original').checked;
+            const targetDir = document.getElementById('restore-target-dir').value.trim();
+            if (!original && !targetDir) {
+                Swal.showValidationMessage('请填写目标目录或选择恢复到原始路径');
+                return false;
+            }
+            return {
+                id: id,
+                original: original,
+                targetDir: targetDir,
+                conflictMode: document.getElementById('restore-conflict-mode').value,
+                paths: document.getElementById('restore-paths').value.split('\n').map(p => p.trim()).filter(p => p)
+            };
+        }
+    }).then((result) => {
+        if (!result.isConfirmed) {
+            return;
+        }
+
+        showToast('正在恢复文件，请稍候...', 'info');
+        apiRequest('/api/records/restoreFiles', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify(result.value)
+        })
+            .then(result => {
+                if (result.code !== 200) {
+                    showToast(`恢复失败: ${result.msg}`, 'danger');
+                    return;
+                }
+
+                const data = result.data;
+                const failed = data.results.filter(r => r.action === 'failed');
+                const failedHtml = failed.length > 0
+                    ? `<ul class="text-start small">${failed.slice(0, 20).map(r => `<li>${r.path}: ${r.error}</li>`).join('')}</ul>`
+                    : '';
+                Swal.fire({
+                    title: failed.length > 0 ? '部分文件恢复失败' : '恢复完成',
+                    html: `<p>恢复 ${data.restored} 个，跳过 ${data.skipped} 个，失败 ${data.failed} 个</p>${failedHtml}`,
+                    icon: failed.length > 0 ? 'warning' : 'success'
+                });
+            })
+            .catch(error => {
+                console.error('Error:', error);
+                showToast(`恢复失败: ${error.message}`, 'danger');
+            });
+    });
+}
+
 // 删除备份记录
 function deleteRecord(id) {
     // 确认对话框
@@ -2638,6 +3686,70 @@ function executeManualCleanup() {
     });
 }
 
+// 绑定完整性校验按钮事件
+function bindIntegrityCheckButton() {
+    const btnIntegrityCheck = document.getElementById('btn-integrity-check');
+    if (btnIntegrityCheck) {
+        btnIntegrityCheck.addEventListener('click', executeIntegrityCheck);
+    }
+}
+
+// 立即校验所有备份文件的完整性
+function executeIntegrityCheck() {
+    Swal.fire({
+        title: '确认执行完整性校验?',
+        text: '将重新读取所有备份文件并校验SHA-256，备份较多时可能需要较长时间',
+        icon: 'question',
+        showCancelButton: true,
+        confirmButtonText: '开始校验',
+        cancelButtonText: '取消'
+    }).then((result) => {
+        if (!result.isConfirmed) {
+            return;
+        }
+
+        showLoading('正在校验备份文件，请稍候...');
+        apiRequest('/api/records/verifyAll', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            }
+        })
+            .then(result => {
+                hideLoading();
+                if (result && result.code === 200) {
+                    const data = result.data;
+                    const errors = data.errorMessages && data.errorMessages.length > 0
+                        ? `<pre class="small bg-light p-2 text-start" style="white-space: pre-wrap; max-height: 200px;">${escapeHtml(data.errorMessages.join('\n'))}</pre>`
+                        : '';
+                    Swal.fire({
+                        title: '完整性校验完成',
+                        html: `<p>通过: ${data.passed}，损坏: ${data.corrupted}，跳过: ${data.skipped}</p>${errors}`,
+                        icon: data.corrupted > 0 ? 'warning' : 'success',
+                        confirmButtonText: '确定'
+                    });
+                } else {
+                    Swal.fire({
+                        title: '完整性校验失败',
+                        text: (result && result.msg) ? result.msg : '执行校验过程中发生错误',
+                        icon: 'error',
+                        confirmButtonText: '确定'
+                    });
+                }
+            })
+            .catch(error => {
+                hideLoading();
+                console.error('完整性校验失败:', error);
+                Swal.fire({
+                    title: '完整性校验失败',
+                    text: `执行过程中发生错误: ${error.message}`,
+                    icon: 'error',
+                    confirmButtonText: '确定'
+                });
+            });
+    });
+}
+
 // 在浏览器后退按钮被点击时重新加载页面状态
 window.addEventListener('popstate', function(event) {
     showInitialPanel();
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // BackupRecordRepository 备份记录仓库
@@ -67,6 +69,49 @@ func (r *BackupRecordRepository) Update(record *entity.BackupRecord) error {
 	return tx.Commit().Error
 }
 
+// UpdateIntegrity 更新备份记录的完整性校验结果，校验通过时会清空错误信息
+func (r *BackupRecordRepository) UpdateIntegrity(record *entity.BackupRecord) error {
+	// 更新UpdatedAt字段
+	record.UpdatedAt = time.Now()
+
+	// 开始事务
+	tx := GetDB().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	// Updates会忽略零值，显式指定需要更新的字段
+	if err := tx.Model(record).Select("status", "error_message", "checked_at", "updated_at").Updates(record).Error; err != nil {
+		tx.Rollback() // 发生错误时回滚
+		return err
+	}
+
+	// 提交事务
+	return tx.Commit().Error
+}
+
+// UpdateVerify 更新备份记录的恢复校验结果，只写校验相关的字段
+// 校验耗时较长，期间完整性校验、清理等可能修改了同一记录的其他字段，不能用整条记录覆盖
+func (r *BackupRecordRepository) UpdateVerify(record *entity.BackupRecord) error {
+	// 更新UpdatedAt字段
+	record.UpdatedAt = time.Now()
+
+	// 开始事务
+	tx := GetDB().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	// Updates会忽略零值，显式指定需要更新的字段
+	if err := tx.Model(record).Select("verify_status", "verify_message", "verified_at", "updated_at").Updates(record).Error; err != nil {
+		tx.Rollback() // 发生错误时回滚
+		return err
+	}
+
+	// 提交事务
+	return tx.Commit().Error
+}
+
 // FindByID 根据ID查找备份记录
 func (r *BackupRecordRepository) FindByID(id int64) (*entity.BackupRecord, error) {
 	var record entity.BackupRecord
@@ -109,6 +154,89 @@ func (r *BackupRecordRepository) FindLatestByTaskID(taskID int64) (*entity.Backu
 	return &record, nil
 }
 
+// FindLatestSuccessByTaskID 获取任务最新的成功备份记录，不存在时返回nil
+func (r *BackupRecordRepository) FindLatestSuccessByTaskID(taskID int64) (*entity.BackupRecord, error) {
+	var record entity.BackupRecord
+
+	result := GetDB().Where("task_id = ? AND status = ?", taskID, entity.StatusSuccess).Order("start_time desc").First(&record)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return &record, nil
+}
+
+// FindLatestWithManifest 获取任务最新的带文件清单的成功备份记录，mode不为空时只查找该备份方式的记录，不存在时返回nil
+func (r *BackupRecordRepository) FindLatestWithManifest(taskID int64, mode entity.BackupMode) (*entity.BackupRecord, error) {
+	var record entity.BackupRecord
+
+	query := GetDB().Where("task_id = ? AND status = ? AND manifest_path != ?", taskID, entity.StatusSuccess, "")
+	if mode != "" {
+		query = query.Where("backup_mode = ?", mode)
+	}
+
+	result := query.Order("start_time desc").First(&record)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return &record, nil
+}
+
+// FindLatestWithBinlogPosition 获取任务在指定时间及之前开始的、记录了binlog位置的最新成功备份，不存在时返回nil
+func (r *BackupRecordRepository) FindLatestWithBinlogPosition(taskID int64, before time.Time) (*entity.BackupRecord, error) {
+	var record entity.BackupRecord
+
+	result := GetDB().Where("task_id = ? AND status = ? AND binlog_file != ? AND start_time <= ?", taskID, entity.StatusSuccess, "", before).
+		Order("start_time desc").
+		First(&record)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return &record, nil
+}
+
+// CountSuccessSince 统计任务在指定时间之后的成功备份记录数量
+func (r *BackupRecordRepository) CountSuccessSince(taskID int64, since time.Time) (int64, error) {
+	var count int64
+
+	result := GetDB().Model(&entity.BackupRecord{}).
+		Where("task_id = ? AND status = ? AND start_time > ?", taskID, entity.StatusSuccess, since).
+		Count(&count)
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return count, nil
+}
+
+// CountDependents 统计依赖指定备份记录的成功或校验失败的备份记录数量
+// 校验失败的记录可能在文件修复后重新通过校验，同样需要保留其依赖
+func (r *BackupRecordRepository) CountDependents(id int64) (int64, error) {
+	var count int64
+
+	result := GetDB().Model(&entity.BackupRecord{}).
+		Where("parent_id = ? AND status IN ?", id, []entity.BackupStatus{entity.StatusSuccess, entity.StatusCorrupted}).
+		Count(&count)
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return count, nil
+}
+
 // FindAll 查询所有备份记录，支持分页
 func (r *BackupRecordRepository) FindAll(page, pageSize int) ([]*entity.BackupRecord, error) {
 	var records []*entity.BackupRecord
@@ -123,6 +251,18 @@ func (r *BackupRecordRepository) FindAll(page, pageSize int) ([]*entity.BackupRe
 	return records, nil
 }
 
+// ListAll 查询所有备份记录，不分页
+func (r *BackupRecordRepository) ListAll() ([]*entity.BackupRecord, error) {
+	var records []*entity.BackupRecord
+
+	result := GetDB().Order("id asc").Find(&records)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return records, nil
+}
+
 // CountAll 统计所有备份记录数量
 func (r *BackupRecordRepository) CountAll() (int64, error) {
 	var count int64
@@ -227,6 +367,7 @@ func (r *BackupRecordRepository) FindOlderThan(date time.Time) ([]*entity.Backup
 	result := GetDB().Where("start_time <= ?", date).
 		Where("file_path != ?", "").                // 只查找有文件路径的记录
 		Where("status != ?", entity.StatusCleaned). // 排除已清理的记录
+		Order("start_time desc").                   // 先清理较新的记录，使其依赖的记录随后可以清理
 		Find(&records)
 
 	if result.Error != nil {
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"backup-go/config"
+	"backup-go/entity"
+	"os"
+	"testing"
+	"time"
+)
+
+// setupDB 在临时目录中创建SQLite数据库
+func setupDB(t *testing.T) {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+
+	if err := config.LoadConfig("config.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.InitDB(); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.MigrateDB(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateVerifyKeepsOtherFields(t *testing.T) {
+	setupDB(t)
+	repo := NewBackupRecordRepository()
+
+	record := &entity.BackupRecord{TaskID: 1, Status: entity.StatusSuccess, StartTime: time.Now()}
+	if err := repo.Create(record); err != nil {
+		t.Fatal(err)
+	}
+	// 校验开始时读取的记录
+	stale, err := repo.FindByID(record.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 校验期间完整性校验将记录标记为损坏
+	current, err := repo.FindByID(record.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current.Status = entity.StatusCorrupted
+	current.ErrorMessage = "checksum mismatch"
+	if err := repo.UpdateIntegrity(current); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	stale.VerifyStatus = entity.VerifyPassed
+	stale.VerifyMessage = "ok"
+	stale.VerifiedAt = &now
+	if err := repo.UpdateVerify(stale); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := repo.FindByID(record.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != entity.StatusCorrupted || saved.ErrorMessage != "checksum mismatch" {
+		t.Errorf("status = %s, error = %q; integrity result was overwritten", saved.Status, saved.ErrorMessage)
+	}
+	if saved.VerifyStatus != entity.VerifyPassed || saved.VerifyMessage != "ok" || saved.VerifiedAt == nil {
+		t.Errorf("verify result not saved: %+v", saved)
+	}
+}
@@ -165,6 +165,51 @@ func (r *BackupTaskRepository) ParseFileSourceInfo(task *entity.BackupTask) (*en
 	return &info, nil
 }
 
+// ParseRedisSourceInfo 解析Redis源信息
+func (r *BackupTaskRepository) ParseRedisSourceInfo(task *entity.BackupTask) (*entity.RedisSourceInfo, error) {
+	if task.Type != entity.RedisBackup {
+		return nil, errors.New("task is not a redis backup")
+	}
+
+	var info entity.RedisSourceInfo
+	err := json.Unmarshal([]byte(task.SourceInfo), &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// ParseSQLiteSourceInfo 解析SQLite源信息
+func (r *BackupTaskRepository) ParseSQLiteSourceInfo(task *entity.BackupTask) (*entity.SQLiteSourceInfo, error) {
+	if task.Type != entity.SQLiteBackup {
+		return nil, errors.New("task is not a sqlite backup")
+	}
+
+	var info entity.SQLiteSourceInfo
+	err := json.Unmarshal([]byte(task.SourceInfo), &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// ParseBinlogSourceInfo 解析binlog归档源信息
+func (r *BackupTaskRepository) ParseBinlogSourceInfo(task *entity.BackupTask) (*entity.BinlogSourceInfo, error) {
+	if task.Type != entity.BinlogBackup {
+		return nil, errors.New("task is not a binlog backup")
+	}
+
+	var info entity.BinlogSourceInfo
+	err := json.Unmarshal([]byte(task.SourceInfo), &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
 // FindAllPaginated 分页查询所有备份任务
 func (r *BackupTaskRepository) FindAllPaginated(page, pageSize int) ([]*entity.BackupTask, error) {
 	var tasks []*entity.BackupTask
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"backup-go/entity"
+	"time"
+)
+
+// BinlogFileRepository 已归档binlog文件仓库
+type BinlogFileRepository struct {
+	db interface{} // 使用空接口类型
+}
+
+// NewBinlogFileRepository 创建已归档binlog文件仓库
+func NewBinlogFileRepository() *BinlogFileRepository {
+	return &BinlogFileRepository{
+		db: GetDB(),
+	}
+}
+
+// CreateBatch 保存一次归档的所有binlog文件
+func (r *BinlogFileRepository) CreateBatch(files []*entity.BinlogFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for _, file := range files {
+		if file.CreatedAt.IsZero() {
+			file.CreatedAt = now
+		}
+	}
+
+	// 开始事务
+	tx := GetDB().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	// 在事务中执行创建操作
+	if err := tx.Create(files).Error; err != nil {
+		tx.Rollback() // 发生错误时回滚
+		return err
+	}
+
+	// 提交事务
+	return tx.Commit().Error
+}
+
+// FindByTaskID 按归档顺序查询binlog归档任务已归档的文件
+func (r *BinlogFileRepository) FindByTaskID(taskID int64) ([]*entity.BinlogFile, error) {
+	var files []*entity.BinlogFile
+
+	result := GetDB().Where("task_id = ?", taskID).Order("id asc").Find(&files)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return files, nil
+}
+
+// ListAll 查询所有已归档的binlog文件
+func (r *BinlogFileRepository) ListAll() ([]*entity.BinlogFile, error) {
+	var files []*entity.BinlogFile
+
+	result := GetDB().Order("id asc").Find(&files)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return files, nil
+}
+
+// DeleteByRecordID 删除备份记录包含的binlog文件，备份记录删除或清理后调用
+func (r *BinlogFileRepository) DeleteByRecordID(recordID int64) error {
+	// 开始事务
+	tx := GetDB().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	// 在事务中执行删除操作
+	result := tx.Where("record_id = ?", recordID).Delete(&entity.BinlogFile{})
+	if result.Error != nil {
+		tx.Rollback() // 发生错误时回滚
+		return result.Error
+	}
+
+	// 提交事务
+	return tx.Commit().Error
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"backup-go/entity"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChunkRepository 数据块仓库
+type ChunkRepository struct {
+	db interface{} // 使用空接口类型
+}
+
+// NewChunkRepository 创建数据块仓库
+func NewChunkRepository() *ChunkRepository {
+	return &ChunkRepository{
+		db: GetDB(),
+	}
+}
+
+// Create 创建数据块记录
+func (r *ChunkRepository) Create(chunk *entity.Chunk) error {
+	if chunk.CreatedAt.IsZero() {
+		chunk.CreatedAt = time.Now()
+	}
+
+	// 开始事务
+	tx := GetDB().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	// 在事务中执行创建操作
+	if err := tx.Create(chunk).Error; err != nil {
+		tx.Rollback() // 发生错误时回滚
+		return err
+	}
+
+	// 提交事务
+	return tx.Commit().Error
+}
+
+// FindByHash 根据内容哈希查找指定存储中使用指定密钥加密的数据块，keyID为空表示未加密，不存在时返回nil
+func (r *ChunkRepository) FindByHash(hash string, storageType entity.StorageType, keyID string) (*entity.Chunk, error) {
+	var chunk entity.Chunk
+	result := GetDB().Where("hash = ? AND storage_type = ? AND encryption_key_id = ?", hash, storageType, keyID).First(&chunk)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return &chunk, nil
+}
+
+// FindForRead 根据内容哈希查找指定存储中可读取的数据块，优先返回使用指定密钥的记录，不存在时返回nil
+// 内容相同的数据块可以任选一个读取，早期的快照可能引用了使用其他密钥保存的数据块
+func (r *ChunkRepository) FindForRead(hash string, storageType entity.StorageType, keyID string) (*entity.Chunk, error) {
+	var chunk entity.Chunk
+	result := GetDB().Where("hash = ? AND storage_type = ?", hash, storageType).
+		Clauses(clause.OrderBy{Expression: clause.Expr{SQL: "CASE WHEN encryption_key_id = ? THEN 0 ELSE 1 END, id", Vars: []interface{}{keyID}}}).
+		Take(&chunk)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return &chunk, nil
+}
+
+// ListAll 查询所有数据块
+func (r *ChunkRepository) ListAll() ([]*entity.Chunk, error) {
+	var chunks []*entity.Chunk
+
+	result := GetDB().Order("id asc").Find(&chunks)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return chunks, nil
+}
+
+// Delete 删除数据块记录
+func (r *ChunkRepository) Delete(id int64) error {
+	// 开始事务
+	tx := GetDB().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	// 在事务中执行删除操作
+	result := tx.Delete(&entity.Chunk{}, id)
+	if result.Error != nil {
+		tx.Rollback() // 发生错误时回滚
+		return result.Error
+	}
+
+	// 提交事务
+	return tx.Commit().Error
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"backup-go/entity"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RestoreRecordRepository 恢复记录仓库
+type RestoreRecordRepository struct {
+	db interface{} // 使用空接口类型
+}
+
+// NewRestoreRecordRepository 创建恢复记录仓库
+func NewRestoreRecordRepository() *RestoreRecordRepository {
+	return &RestoreRecordRepository{
+		db: GetDB(),
+	}
+}
+
+// Create 创建恢复记录
+func (r *RestoreRecordRepository) Create(record *entity.RestoreRecord) error {
+	// 确保时间戳字段正确设置
+	now := time.Now()
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = now
+	}
+	if record.UpdatedAt.IsZero() {
+		record.UpdatedAt = now
+	}
+
+	// 开始事务
+	tx := GetDB().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	// 在事务中执行创建操作
+	if err := tx.Create(record).Error; err != nil {
+		tx.Rollback() // 发生错误时回滚
+		return err
+	}
+
+	// 提交事务
+	return tx.Commit().Error
+}
+
+// Update 更新恢复记录
+func (r *RestoreRecordRepository) Update(record *entity.RestoreRecord) error {
+	// 更新UpdatedAt字段
+	record.UpdatedAt = time.Now()
+
+	// 开始事务
+	tx := GetDB().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	// 在事务中执行更新操作
+	if err := tx.Model(record).Updates(record).Error; err != nil {
+		tx.Rollback() // 发生错误时回滚
+		return err
+	}
+
+	// 提交事务
+	return tx.Commit().Error
+}
+
+// FindByID 根据ID查找恢复记录
+func (r *RestoreRecordRepository) FindByID(id int64) (*entity.RestoreRecord, error) {
+	var record entity.RestoreRecord
+	result := GetDB().First(&record, id)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return &record, nil
+}
+
+// FindAll 查询所有恢复记录，支持分页
+func (r *RestoreRecordRepository) FindAll(page, pageSize int) ([]*entity.RestoreRecord, error) {
+	var records []*entity.RestoreRecord
+
+	offset := (page - 1) * pageSize
+
+	result := GetDB().Order("start_time desc").Offset(offset).Limit(pageSize).Find(&records)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return records, nil
+}
+
+// CountAll 统计所有恢复记录数量
+func (r *RestoreRecordRepository) CountAll() (int64, error) {
+	var count int64
+
+	result := GetDB().Model(&entity.RestoreRecord{}).Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return count, nil
+}
+
+// FindByStatus 根据状态查找恢复记录
+func (r *RestoreRecordRepository) FindByStatus(status entity.BackupStatus) ([]*entity.RestoreRecord, error) {
+	var records []*entity.RestoreRecord
+
+	result := GetDB().Where("status = ?", status).Find(&records)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return records, nil
+}
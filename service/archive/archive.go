@@ -0,0 +1,558 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"backup-go/entity"
+	"backup-go/service/chunkstore"
+	"backup-go/service/compression"
+	"backup-go/service/encryption"
+	"backup-go/service/storage"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ZIP文件头中表示Unix系统创建的版本号高位
+const creatorUnix = 3
+
+// 用于提前结束遍历
+var errStop = fmt.Errorf("stop walking")
+
+// Entry 归档中的条目
+type Entry struct {
+	Path     string      `json:"path"`               // 条目路径，使用"/"分隔，目录不带结尾的"/"
+	Size     int64       `json:"size"`               // 文件大小，单位字节
+	ModTime  time.Time   `json:"modTime"`            // 修改时间
+	IsDir    bool        `json:"isDir"`              // 是否为目录
+	Mode     os.FileMode `json:"mode"`               // 文件权限
+	Linkname string      `json:"linkname,omitempty"` // 符号链接的目标，非空表示该条目为符号链接
+	Owner    *Owner      `json:"owner,omitempty"`    // 属主，仅tar归档记录
+}
+
+// Owner 条目的属主
+type Owner struct {
+	Uid   int    `json:"uid"`   // 用户ID
+	Gid   int    `json:"gid"`   // 组ID
+	Uname string `json:"uname"` // 用户名
+	Gname string `json:"gname"` // 组名
+}
+
+// Archive 从存储中读取的备份归档
+type Archive struct {
+	zipReader   *zip.ReadCloser      // ZIP归档
+	tarPath     string               // tar归档的文件路径
+	compression string               // tar归档的压缩方式
+	snapshot    *chunkstore.Snapshot // 仓库格式的快照
+	storageType entity.StorageType   // 快照引用的数据块所在的存储
+	keyID       string               // 快照使用的加密密钥ID，优先读取使用该密钥保存的数据块
+	download    *download            // 下载到临时目录的文件，直接读取本地文件时为nil
+}
+
+// Open 读取备份记录对应的ZIP或tar归档，或仓库格式的快照
+// ZIP格式需要随机读取，tar格式需要多次遍历：未加密的本地文件直接读取，其他存储或加密的文件先下载（解密）到临时目录，
+// 下载的文件会缓存一段时间，连续浏览和提取同一个备份时不必重复下载
+// 快照只读取条目列表，文件内容在打开时从数据块读取
+func Open(record *entity.BackupRecord) (*Archive, error) {
+	if record.FilePath == "" {
+		return nil, fmt.Errorf("backup record %d has no file", record.ID)
+	}
+	if chunkstore.IsSnapshot(record.FilePath) {
+		snapshot, err := chunkstore.LoadSnapshot(record)
+		if err != nil {
+			return nil, err
+		}
+		return &Archive{snapshot: snapshot, storageType: record.StorageType, keyID: record.EncryptionKeyID}, nil
+	}
+	plainName := encryption.PlainName(filepath.Base(record.FilePath))
+	isZip := strings.HasSuffix(plainName, ".zip")
+	if !isZip && !strings.HasSuffix(compression.TrimExtension(plainName), ".tar") {
+		return nil, fmt.Errorf("backup file is not a zip or tar archive: %s", record.FilePath)
+	}
+
+	storageService, err := storage.NewStorageServiceForRecord(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage service: %w", err)
+	}
+
+	a := &Archive{}
+	var filePath string
+	if local, ok := storageService.(*storage.LocalStorageService); ok && record.EncryptionKeyID == "" && plainName == filepath.Base(record.FilePath) {
+		filePath = local.LocalPath(record.FilePath)
+	} else {
+		key := downloadKey(record.ID, string(storageService.GetStorageType()), record.FilePath)
+		a.download, err = downloads.acquire(key, func() (string, string, error) {
+			return fetch(record, plainName)
+		})
+		if err != nil {
+			return nil, err
+		}
+		filePath = a.download.path
+	}
+
+	if !isZip {
+		a.tarPath = filePath
+		a.compression = compression.Detect(plainName)
+		return a, nil
+	}
+
+	a.zipReader, err = zip.OpenReader(filePath)
+	if err != nil {
+		a.Close()
+		return nil, fmt.Errorf("failed to open zip archive: %w", err)
+	}
+	return a, nil
+}
+
+// fetch 将备份文件下载（解密）到新建的临时目录，返回临时目录和文件路径
+func fetch(record *entity.BackupRecord, plainName string) (string, string, error) {
+	file, err := encryption.OpenRecordFile(record)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	tempDir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	tempFilePath := filepath.Join(tempDir, plainName)
+	tempFile, err := os.Create(tempFilePath)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return "", "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	_, err = io.Copy(tempFile, file)
+	tempFile.Close()
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return "", "", fmt.Errorf("failed to download backup file: %w", err)
+	}
+	return tempDir, tempFilePath, nil
+}
+
+// Close 关闭归档，下载的临时文件由缓存在过期后删除
+func (a *Archive) Close() error {
+	var err error
+	if a.zipReader != nil {
+		err = a.zipReader.Close()
+	}
+	if a.download != nil {
+		downloads.release(a.download)
+		a.download = nil
+	}
+	return err
+}
+
+// Walk 按归档中的顺序遍历所有条目，open用于读取文件内容
+// tar归档只能顺序读取，open返回的读取器只在回调中有效
+func (a *Archive) Walk(fn func(entry Entry, open func() (io.ReadCloser, error)) error) error {
+	if a.snapshot != nil {
+		return a.walkSnapshot(fn)
+	}
+	if a.zipReader == nil {
+		return a.walkTar(fn)
+	}
+
+	for _, f := range a.zipReader.File {
+		entry, ok := zipEntry(f)
+		if !ok {
+			continue
+		}
+		if err := fn(entry, f.Open); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkTar 遍历tar归档中的条目
+func (a *Archive) walkTar(fn func(entry Entry, open func() (io.ReadCloser, error)) error) error {
+	tarReader, closer, err := a.openTar()
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	open := func() (io.ReadCloser, error) {
+		return io.NopCloser(tarReader), nil
+	}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+
+		entry, ok := tarEntry(header)
+		if !ok {
+			continue
+		}
+		if err := fn(entry, open); err != nil {
+			return err
+		}
+	}
+}
+
+// walkSnapshot 遍历快照中的条目
+func (a *Archive) walkSnapshot(fn func(entry Entry, open func() (io.ReadCloser, error)) error) error {
+	for _, node := range a.snapshot.Nodes {
+		entry, ok := snapshotEntry(node)
+		if !ok {
+			continue
+		}
+		chunks := node.Chunks
+		open := func() (io.ReadCloser, error) {
+			return chunkstore.OpenChunks(a.storageType, a.keyID, chunks)
+		}
+		if err := fn(entry, open); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotEntry 将快照中的条目转换为归档条目
+func snapshotEntry(node *chunkstore.Node) (Entry, bool) {
+	name, ok := CleanPath(node.Path)
+	if !ok {
+		return Entry{}, false
+	}
+
+	entry := Entry{
+		Path:    name,
+		ModTime: node.ModTime,
+		Mode:    node.Mode.Perm(),
+		Owner: &Owner{
+			Uid:   node.Uid,
+			Gid:   node.Gid,
+			Uname: node.Uname,
+			Gname: node.Gname,
+		},
+	}
+
+	switch node.Type {
+	case chunkstore.NodeFile:
+		entry.Size = node.Size
+	case chunkstore.NodeDir:
+		entry.IsDir = true
+	case chunkstore.NodeSymlink:
+		entry.Linkname = node.Linkname
+	default:
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// openTar 打开临时文件并解压，返回tar读取器和用于关闭的对象
+func (a *Archive) openTar() (*tar.Reader, io.Closer, error) {
+	file, err := os.Open(a.tarPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open tar archive: %w", err)
+	}
+
+	reader, err := compression.NewReader(file, a.compression)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return tar.NewReader(reader), &tarCloser{reader: reader, file: file}, nil
+}
+
+// tarCloser 依次关闭解压读取器和文件
+type tarCloser struct {
+	reader io.Closer
+	file   *os.File
+}
+
+// Close 关闭解压读取器和文件
+func (c *tarCloser) Close() error {
+	c.reader.Close()
+	return c.file.Close()
+}
+
+// tarEntry 将tar文件头转换为条目，只保留普通文件、目录和符号链接
+func tarEntry(header *tar.Header) (Entry, bool) {
+	name, ok := CleanPath(header.Name)
+	if !ok {
+		return Entry{}, false
+	}
+
+	entry := Entry{
+		Path:    name,
+		ModTime: header.ModTime,
+		Mode:    os.FileMode(header.Mode).Perm(),
+		Owner: &Owner{
+			Uid:   header.Uid,
+			Gid:   header.Gid,
+			Uname: header.Uname,
+			Gname: header.Gname,
+		},
+	}
+
+	switch header.Typeflag {
+	case tar.TypeReg:
+		entry.Size = header.Size
+	case tar.TypeDir:
+		entry.IsDir = true
+	case tar.TypeSymlink:
+		entry.Linkname = header.Linkname
+	default:
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// Entries 列出归档中的所有条目
+func (a *Archive) Entries() []Entry {
+	var entries []Entry
+	_ = a.Walk(func(entry Entry, _ func() (io.ReadCloser, error)) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries
+}
+
+// zipEntry 将ZIP文件头转换为条目，名称不安全的条目会被忽略
+func zipEntry(f *zip.File) (Entry, bool) {
+	name, ok := CleanPath(f.Name)
+	if !ok {
+		return Entry{}, false
+	}
+
+	isDir := strings.HasSuffix(f.Name, "/") || f.FileInfo().IsDir()
+	mode := f.Mode().Perm()
+	if f.CreatorVersion>>8 != creatorUnix || mode == 0 {
+		// 旧版本创建的归档没有记录Unix权限
+		if isDir {
+			mode = 0755
+		} else {
+			mode = 0644
+		}
+	}
+
+	// 没有记录修改时间的条目，DOS日期为零值
+	modTime := f.Modified
+	if modTime.Year() < 1980 {
+		modTime = time.Time{}
+	}
+
+	return Entry{
+		Path:    name,
+		Size:    int64(f.UncompressedSize64),
+		ModTime: modTime,
+		IsDir:   isDir,
+		Mode:    mode,
+	}, true
+}
+
+// CleanPath 规范化归档中的条目路径，拒绝绝对路径和跳出根目录的路径（zip-slip）
+func CleanPath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", false
+	}
+
+	cleaned := path.Clean(name)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", false
+	}
+	return cleaned, true
+}
+
+// SafeJoin 将条目路径拼接到根目录下，并确认结果没有跳出根目录
+// 除路径本身外还会解析已存在的上级目录中的符号链接，经由链接指向根目录之外时同样拒绝
+// 应在创建文件前调用，期间新建的符号链接可能改变解析结果
+func SafeJoin(root, name string) (string, error) {
+	cleaned, ok := CleanPath(name)
+	if !ok {
+		return "", fmt.Errorf("unsafe path in archive: %s", name)
+	}
+
+	target := filepath.Join(root, filepath.FromSlash(cleaned))
+	if !isWithin(root, target) {
+		return "", fmt.Errorf("unsafe path in archive: %s", name)
+	}
+	if err := checkParentSymlinks(root, filepath.Dir(target)); err != nil {
+		return "", fmt.Errorf("unsafe path in archive: %s: %w", name, err)
+	}
+	return target, nil
+}
+
+// checkParentSymlinks 解析dir中已存在部分的符号链接，确认实际位置仍在根目录之下
+func checkParentSymlinks(root, dir string) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// 根目录还不存在，其下也没有已存在的链接
+			return nil
+		}
+		return err
+	}
+
+	// 从最深一级已存在的目录开始解析，不存在的部分稍后才会创建
+	for {
+		realDir, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			if !isWithin(realRoot, realDir) {
+				return fmt.Errorf("%s resolves to %s outside %s", dir, realDir, root)
+			}
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// isWithin 判断target是否为root或位于root之下
+func isWithin(root, target string) bool {
+	rel, err := filepath.Rel(root, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Stat 查找指定路径的条目，归档中没有单独目录条目的目录也能识别
+func (a *Archive) Stat(name string) (Entry, bool) {
+	var found Entry
+	var ok bool
+	_ = a.Walk(func(entry Entry, _ func() (io.ReadCloser, error)) error {
+		if entry.Path == name {
+			found, ok = entry, true
+			return errStop
+		}
+		if !ok && strings.HasPrefix(entry.Path, name+"/") {
+			found, ok = Entry{Path: name, IsDir: true, Mode: os.ModeDir | 0755}, true
+		}
+		return nil
+	})
+	return found, ok
+}
+
+// OpenFile 读取指定路径的文件内容
+func (a *Archive) OpenFile(name string) (io.ReadCloser, error) {
+	if a.zipReader == nil && a.snapshot == nil {
+		return a.openTarFile(name)
+	}
+
+	var reader io.ReadCloser
+	err := a.Walk(func(entry Entry, open func() (io.ReadCloser, error)) error {
+		if entry.Path != name || entry.IsDir || entry.Linkname != "" {
+			return nil
+		}
+		var err error
+		reader, err = open()
+		if err != nil {
+			return err
+		}
+		return errStop
+	})
+	if err != nil && err != errStop {
+		return nil, err
+	}
+	if reader == nil {
+		return nil, fmt.Errorf("file not found in archive: %s", name)
+	}
+	return reader, nil
+}
+
+// openTarFile 读取tar归档中指定路径的文件内容
+func (a *Archive) openTarFile(name string) (io.ReadCloser, error) {
+	tarReader, closer, err := a.openTar()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			closer.Close()
+			return nil, fmt.Errorf("file not found in archive: %s", name)
+		}
+		if err != nil {
+			closer.Close()
+			return nil, fmt.Errorf("failed to read tar archive: %w", err)
+		}
+
+		entry, ok := tarEntry(header)
+		if ok && entry.Path == name && !entry.IsDir && entry.Linkname == "" {
+			return &readCloser{Reader: tarReader, Closer: closer}, nil
+		}
+	}
+}
+
+// readCloser 组合tar读取器和底层文件
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// WriteZip 将指定目录及其下的所有条目写入新的ZIP，条目路径相对于该目录的上级目录
+// dir为空时写入所有条目
+func (a *Archive) WriteZip(w io.Writer, dir string) error {
+	zipWriter := zip.NewWriter(w)
+	parent := path.Dir(dir)
+
+	err := a.Walk(func(entry Entry, open func() (io.ReadCloser, error)) error {
+		if dir != "" && entry.Path != dir && !strings.HasPrefix(entry.Path, dir+"/") {
+			return nil
+		}
+
+		name := entry.Path
+		if parent != "." {
+			name = strings.TrimPrefix(name, parent+"/")
+		}
+
+		header := &zip.FileHeader{
+			Name:     name,
+			Modified: entry.ModTime,
+			Method:   zip.Deflate,
+		}
+		switch {
+		case entry.IsDir:
+			header.Name += "/"
+			header.Method = zip.Store
+			header.SetMode(os.ModeDir | entry.Mode)
+		case entry.Linkname != "":
+			// ZIP中的符号链接以链接目标作为文件内容
+			header.Method = zip.Store
+			header.SetMode(os.ModeSymlink | 0777)
+		default:
+			header.SetMode(entry.Mode)
+		}
+
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil || entry.IsDir {
+			return err
+		}
+		if entry.Linkname != "" {
+			_, err = io.WriteString(writer, entry.Linkname)
+			return err
+		}
+
+		reader, err := open()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		_, err = io.Copy(writer, reader)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zipWriter.Close()
+}
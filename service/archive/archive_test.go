@@ -0,0 +1,77 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafeJoin(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "data", "inner"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// 指向根目录之外和之内的符号链接
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "data", "inner"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"file", "data/file.txt", "data/file.txt", false},
+		{"missing directories", "new/dir/file.txt", "new/dir/file.txt", false},
+		{"link inside root", "link/file.txt", "link/file.txt", false},
+		{"entry is the link itself", "escape", "escape", false},
+		{"parent traversal", "../file.txt", "", true},
+		{"nested traversal", "data/../../file.txt", "", true},
+		{"absolute path", "/etc/passwd", "", true},
+		{"through link outside root", "escape/file.txt", "", true},
+		{"missing directory under link outside root", "escape/new/file.txt", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SafeJoin(root, tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("SafeJoin(%q) = %q, want error", tt.path, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(root, filepath.FromSlash(tt.want)); got != want {
+				t.Errorf("SafeJoin(%q) = %q, want %q", tt.path, got, want)
+			}
+		})
+	}
+}
+
+func TestSafeJoinSymlinkedRoot(t *testing.T) {
+	// 根目录本身是符号链接时，解析后位于其实际位置之下的路径是安全的
+	realDir := t.TempDir()
+	root := filepath.Join(t.TempDir(), "root")
+	if err := os.Symlink(realDir, root); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(realDir, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := SafeJoin(root, "dir/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, root) {
+		t.Errorf("SafeJoin = %q, want path under %q", got, root)
+	}
+}
@@ -0,0 +1,118 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 下载缓存：浏览备份时会连续列出条目、提取文件，短时间内复用已下载的归档，不必每次都从存储完整下载
+const (
+	downloadCacheTTL  = 10 * time.Minute // 未使用的文件保留时间
+	downloadCacheSize = 4                // 最多保留的未使用文件数
+)
+
+// download 下载到临时目录的备份文件
+type download struct {
+	key      string
+	path     string        // 临时文件路径
+	dir      string        // 临时目录，删除时整个目录删除
+	ready    chan struct{} // 下载结束后关闭
+	err      error         // 下载失败的原因
+	refs     int           // 正在使用的归档数
+	lastUsed time.Time
+}
+
+// downloadCache 按备份记录缓存下载的文件
+type downloadCache struct {
+	mutex sync.Mutex
+	items map[string]*download
+}
+
+var downloads = &downloadCache{items: make(map[string]*download)}
+
+// acquire 返回key对应的已下载文件，没有时调用fetch下载，同一个文件同时只下载一次
+// fetch返回临时目录和其中的文件路径，使用完后需要调用release
+func (c *downloadCache) acquire(key string, fetch func() (string, string, error)) (*download, error) {
+	c.mutex.Lock()
+	d, ok := c.items[key]
+	if !ok {
+		d = &download{key: key, ready: make(chan struct{})}
+		c.items[key] = d
+	}
+	d.refs++
+	c.mutex.Unlock()
+
+	if !ok {
+		d.dir, d.path, d.err = fetch()
+		close(d.ready)
+	}
+	<-d.ready
+
+	if d.err != nil {
+		err := d.err
+		c.release(d)
+		return nil, err
+	}
+	return d, nil
+}
+
+// release 结束使用文件，清理过期和超出数量的文件
+func (c *downloadCache) release(d *download) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	d.refs--
+	d.lastUsed = time.Now()
+	if d.err != nil {
+		// 下载失败的不缓存，下次重新下载
+		if c.items[d.key] == d {
+			delete(c.items, d.key)
+		}
+		if d.refs == 0 && d.dir != "" {
+			os.RemoveAll(d.dir)
+		}
+		return
+	}
+
+	c.evict()
+	if d.refs == 0 {
+		// 到期后没有再使用时删除
+		time.AfterFunc(downloadCacheTTL, func() {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+			c.evict()
+		})
+	}
+}
+
+// evict 删除过期的和超出数量的未使用文件，调用时需持有锁
+func (c *downloadCache) evict() {
+	var idle []*download
+	for key, d := range c.items {
+		if d.refs > 0 {
+			continue
+		}
+		if time.Since(d.lastUsed) >= downloadCacheTTL {
+			delete(c.items, key)
+			os.RemoveAll(d.dir)
+			continue
+		}
+		idle = append(idle, d)
+	}
+
+	// 超出数量时先删除最久未使用的
+	sort.Slice(idle, func(i, j int) bool { return idle[i].lastUsed.Before(idle[j].lastUsed) })
+	for len(idle) > downloadCacheSize {
+		delete(c.items, idle[0].key)
+		os.RemoveAll(idle[0].dir)
+		idle = idle[1:]
+	}
+}
+
+// downloadKey 生成备份文件的缓存键
+func downloadKey(recordID int64, storageType, filePath string) string {
+	return fmt.Sprintf("%d:%s:%s", recordID, storageType, filePath)
+}
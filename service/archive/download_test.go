@@ -0,0 +1,107 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tempFetch 返回在临时目录中创建文件的fetch，并统计调用次数
+func tempFetch(t *testing.T, calls *int) func() (string, string, error) {
+	return func() (string, string, error) {
+		*calls++
+		dir, err := os.MkdirTemp("", "archive-test")
+		if err != nil {
+			return "", "", err
+		}
+		path := filepath.Join(dir, "backup.zip")
+		return dir, path, os.WriteFile(path, []byte("data"), 0644)
+	}
+}
+
+func TestDownloadCacheReuse(t *testing.T) {
+	cache := &downloadCache{items: make(map[string]*download)}
+	calls := 0
+
+	first, err := cache.acquire("1", tempFetch(t, &calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := cache.acquire("1", tempFetch(t, &calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.release(first)
+	cache.release(second)
+
+	third, err := cache.acquire("1", tempFetch(t, &calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.release(third)
+	if calls != 1 {
+		t.Errorf("fetch called %d times, want 1", calls)
+	}
+	if third.path != first.path {
+		t.Errorf("path = %s, want cached %s", third.path, first.path)
+	}
+}
+
+func TestDownloadCacheFailureNotCached(t *testing.T) {
+	cache := &downloadCache{items: make(map[string]*download)}
+	calls := 0
+	failed := errors.New("storage unavailable")
+
+	_, err := cache.acquire("1", func() (string, string, error) {
+		calls++
+		return "", "", failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("error = %v, want %v", err, failed)
+	}
+
+	d, err := cache.acquire("1", tempFetch(t, &calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.release(d)
+	if calls != 2 {
+		t.Errorf("fetch called %d times, want 2", calls)
+	}
+	os.RemoveAll(d.dir)
+}
+
+func TestDownloadCacheEviction(t *testing.T) {
+	cache := &downloadCache{items: make(map[string]*download)}
+	calls := 0
+
+	var dirs []string
+	for i := 0; i < downloadCacheSize+2; i++ {
+		d, err := cache.acquire(fmt.Sprint(i), tempFetch(t, &calls))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, d.dir)
+		cache.release(d)
+	}
+
+	// 最早使用的两个文件被删除，其余保留
+	for i, dir := range dirs {
+		_, err := os.Stat(dir)
+		if i < 2 {
+			if !os.IsNotExist(err) {
+				t.Errorf("download %d not removed", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("download %d removed: %v", i, err)
+		}
+		os.RemoveAll(dir)
+	}
+	if len(cache.items) != downloadCacheSize {
+		t.Errorf("cached %d downloads, want %d", len(cache.items), downloadCacheSize)
+	}
+}
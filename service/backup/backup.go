@@ -2,6 +2,13 @@ package backup
 
 import (
 	"backup-go/entity"
+	"backup-go/service/encryption"
+	"backup-go/service/storage"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 )
 
 // BackupService 备份服务接口
@@ -20,7 +27,111 @@ func NewBackupService(backupType entity.BackupType) (BackupService, error) {
 		return NewDatabaseBackupService(), nil
 	case entity.FileBackup:
 		return NewFileBackupService(), nil
+	case entity.RedisBackup:
+		return NewRedisBackupService(), nil
+	case entity.SQLiteBackup:
+		return NewSQLiteBackupService(), nil
+	case entity.ConfigBackup:
+		return NewConfigBackupService(), nil
+	case entity.BinlogBackup:
+		return NewBinlogBackupService(), nil
 	default:
-		return nil, nil
+		return nil, fmt.Errorf("unsupported backup type: %s", backupType)
 	}
 }
+
+// savedFile 已保存到存储的备份文件
+type savedFile struct {
+	path     string // 存储中的路径
+	keyID    string // 加密使用的密钥ID，未加密时为空
+	checksum string // 写入存储的内容（加密后）的SHA-256，十六进制
+	size     int64  // 写入存储的内容（加密后）的大小，单位字节
+}
+
+// saveBackupFile 按系统配置加密后保存备份文件，边上传边计算写入内容的SHA-256和大小
+func saveBackupFile(storageService storage.StorageService, filename string, content io.Reader) (*savedFile, error) {
+	encryptor, err := encryption.NewEncryptor()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encryption key: %w", err)
+	}
+
+	// 启用加密时上传加密后的内容
+	saved := &savedFile{}
+	if encryptor != nil {
+		reader := encryptor.EncryptReader(content)
+		defer reader.Close()
+		content = reader
+		filename += encryption.Extension
+		saved.keyID = encryptor.KeyID()
+	}
+
+	hash := sha256.New()
+	counter := &countingReader{reader: io.TeeReader(content, hash)}
+	saved.path, err = storageService.Save(filename, counter)
+	if err != nil {
+		return nil, err
+	}
+	saved.checksum = hex.EncodeToString(hash.Sum(nil))
+	saved.size = counter.n
+	return saved, nil
+}
+
+// streamBackupFile 将write生成的内容通过管道边生成边加密上传，不经过临时文件，返回保存的文件和加密前的大小
+// 存储中文件的大小（加密后）见savedFile.size
+// write返回错误时上传中止；上传失败时write中的写入会返回错误
+func streamBackupFile(storageService storage.StorageService, filename string, write func(w io.Writer) error) (*savedFile, int64, error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := write(pw)
+		// err为nil时读取端收到io.EOF，否则上传以该错误中止
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	counter := &countingReader{reader: pr}
+	saved, err := saveBackupFile(storageService, filename, counter)
+	if err != nil {
+		// 关闭读取端，使写入方退出
+		pr.CloseWithError(err)
+	}
+	writeErr := <-done
+
+	// 优先返回生成内容时的错误，上传失败导致的写入错误返回上传错误
+	if writeErr != nil && (err == nil || !errors.Is(writeErr, err)) {
+		if err == nil {
+			_ = storageService.Delete(saved.path)
+		}
+		return nil, 0, writeErr
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return saved, counter.n, nil
+}
+
+// countingReader 统计读取的字节数，用于记录压缩后的大小
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+// Read 读取数据并累计字节数
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingWriter 统计写入的字节数，用于记录压缩前的大小
+type countingWriter struct {
+	writer io.Writer
+	n      int64
+}
+
+// Write 写入数据并累计字节数
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	c.n += int64(n)
+	return n, err
+}
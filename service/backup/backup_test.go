@@ -0,0 +1,45 @@
+package backup
+
+import (
+	"backup-go/service/storage"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStreamBackupFileCountsEncryptedSize(t *testing.T) {
+	setupDB(t)
+	setConfig(t, "encryption.enabled", "true")
+	setConfig(t, "encryption.keyId", "k1")
+	setConfig(t, "encryption.keys", `{"k1":{"type":"passphrase","passphrase":"secret"}}`)
+
+	data := bytes.Repeat([]byte("backup"), 50000)
+	saved, size, err := streamBackupFile(storage.NewLocalStorageService(), "data.bin", func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.keyID != "k1" {
+		t.Fatalf("keyID = %q, want k1", saved.keyID)
+	}
+
+	// 返回值为加密前的大小，savedFile.size为存储中文件的实际大小
+	if size != int64(len(data)) {
+		t.Errorf("size before encryption = %d, want %d", size, len(data))
+	}
+	// 本地存储默认保存在工作目录下的backups中
+	info, err := os.Stat(filepath.Join("backups", saved.path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.size != info.Size() {
+		t.Errorf("saved size = %d, stored file size = %d", saved.size, info.Size())
+	}
+	if saved.size <= size {
+		t.Errorf("saved size %d not larger than plaintext size %d", saved.size, size)
+	}
+}
@@ -0,0 +1,550 @@
+package backup
+
+import (
+	"archive/tar"
+	"backup-go/entity"
+	"backup-go/repository"
+	"backup-go/service/compression"
+	"backup-go/service/config"
+	"backup-go/service/dbclient"
+	"backup-go/service/redact"
+	"backup-go/service/storage"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// BinlogBackupService MySQL binlog归档服务
+// 每次执行时从服务器拉取关联的完整备份之后已写完的binlog文件，打包保存为一个备份记录
+type BinlogBackupService struct {
+	taskRepo       *repository.BackupTaskRepository
+	recordRepo     *repository.BackupRecordRepository
+	binlogRepo     *repository.BinlogFileRepository
+	storageType    entity.StorageType
+	webhookService *config.WebhookService
+}
+
+// NewBinlogBackupService 创建binlog归档服务
+func NewBinlogBackupService() *BinlogBackupService {
+	return &BinlogBackupService{
+		taskRepo:       repository.NewBackupTaskRepository(),
+		recordRepo:     repository.NewBackupRecordRepository(),
+		binlogRepo:     repository.NewBinlogFileRepository(),
+		storageType:    entity.LocalStorage, // 默认使用本地存储
+		webhookService: config.NewWebhookService(),
+	}
+}
+
+// Execute 执行归档
+func (s *BinlogBackupService) Execute(task *entity.BackupTask) (*entity.BackupRecord, error) {
+	// 解析源信息
+	sourceInfo, err := s.taskRepo.ParseBinlogSourceInfo(task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse binlog source info: %w", err)
+	}
+
+	// 创建备份记录
+	record := &entity.BackupRecord{
+		TaskID:    task.ID,
+		Status:    entity.StatusRunning,
+		StartTime: time.Now(),
+	}
+	err = s.recordRepo.Create(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup record: %w", err)
+	}
+
+	runLog := newRunLog()
+	runLog.Printf("开始归档binlog，关联的数据库备份任务ID: %d", sourceInfo.DatabaseTaskID)
+
+	dbSource, err := s.loadDatabaseSource(sourceInfo.DatabaseTaskID)
+	if err != nil {
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+		return record, err
+	}
+
+	method := sourceInfo.Compression
+	if method == "" {
+		method = compression.None
+	}
+	if err := compression.Validate(method, sourceInfo.CompressionLevel); err != nil {
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+		return record, err
+	}
+
+	// binlog从最近一次记录了位置的完整备份开始归档，归档记录依赖该完整备份
+	base, err := s.recordRepo.FindLatestWithBinlogPosition(sourceInfo.DatabaseTaskID, time.Now())
+	if err != nil {
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to find base backup: %w", err)
+	}
+	if base == nil {
+		err := fmt.Errorf("database task %d has no successful backup with a binlog position, enable binlogPosition and run a full backup first", sourceInfo.DatabaseTaskID)
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+		return record, err
+	}
+	record.ParentID = base.ID
+	runLog.Printf("基准完整备份: 记录 %d，binlog位置 %s:%d", base.ID, base.BinlogFile, base.BinlogPosition)
+
+	tool := dbclient.DetectMySQLBinlog(dbclient.MySQLFlavor(dbSource))
+	runLog.Printf("归档工具: %s", tool)
+	// mysql客户端与mysqlbinlog可能来自不同的安装，TLS参数按各自的类型和版本生成
+	client := dbclient.DetectMySQLClient(dbclient.MySQLFlavor(dbSource))
+	runLog.Printf("客户端工具: %s", client)
+
+	if sourceInfo.FlushLogs {
+		// 切换到新的binlog文件，使之前写入的事件都能归档
+		if _, err := runMySQLQuery(dbSource, client, "FLUSH BINARY LOGS"); err != nil {
+			s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+			return record, fmt.Errorf("failed to flush binary logs: %w", err)
+		}
+		runLog.Printf("已执行FLUSH BINARY LOGS")
+	}
+
+	serverLogs, err := listBinaryLogs(dbSource, client)
+	if err != nil {
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to list binary logs: %w", err)
+	}
+
+	archived, err := s.archivedBinlogs(task.ID)
+	if err != nil {
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to load archived binary logs: %w", err)
+	}
+
+	names, err := selectBinlogs(serverLogs, archived, base.BinlogFile)
+	if err != nil {
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+		return record, err
+	}
+
+	if len(names) == 0 {
+		runLog.Printf("没有需要归档的binlog文件")
+		record.Status = entity.StatusSuccess
+		record.EndTime = time.Now()
+		record.Summary = "no new binary logs"
+		record.Log = runLog.String()
+		if err := s.recordRepo.Update(record); err != nil {
+			return record, fmt.Errorf("failed to update backup record: %w", err)
+		}
+		return record, nil
+	}
+	runLog.Printf("待归档的binlog文件: %s", strings.Join(names, ", "))
+
+	// mysqlbinlog --raw只能将binlog写入本地文件
+	tempDir, err := ioutil.TempDir("", "binlog_backup")
+	if err != nil {
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := fetchBinlogs(dbSource, tool, tempDir, names, runLog); err != nil {
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+		return record, err
+	}
+
+	var files []*entity.BinlogFile
+	for _, name := range names {
+		info, err := os.Stat(filepath.Join(tempDir, name))
+		if err != nil {
+			s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+			return record, fmt.Errorf("failed to get binlog file info: %w", err)
+		}
+		// 归档的文件都已切换，最后一个事件的时间之前的事件都在已归档的文件中
+		coveredUntil, err := binlogEndTime(filepath.Join(tempDir, name))
+		if err != nil {
+			s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+			return record, fmt.Errorf("failed to read binlog %s: %w", name, err)
+		}
+		files = append(files, &entity.BinlogFile{TaskID: task.ID, Name: name, Size: info.Size(), CoveredUntil: coveredUntil})
+	}
+	runLog.Printf("归档的binlog覆盖到 %s", files[len(files)-1].CoveredUntil.Format("2006-01-02 15:04:05"))
+
+	// 为任务名称去除特殊字符，避免不合法的文件名
+	safeName := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, task.Name)
+	backupVersion := time.Now().Format("20060102150405")
+	filename := fmt.Sprintf("task_%d_%s_binlog_%s.tar%s", task.ID, safeName, backupVersion, compression.Extension(method))
+
+	storageService, err := storage.NewStorageService("")
+	if err != nil {
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to create storage service: %w", err)
+	}
+
+	original := &countingWriter{}
+	saved, size, err := streamBackupFile(storageService, filename, func(w io.Writer) error {
+		compressor, err := compression.NewWriter(w, method, sourceInfo.CompressionLevel)
+		if err != nil {
+			return err
+		}
+		original.writer = compressor
+		if err := writeBinlogArchive(original, tempDir, names); err != nil {
+			compressor.Close()
+			return err
+		}
+		return compressor.Close()
+	})
+	if err != nil {
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to save backup file: %w", err)
+	}
+	runLog.Printf("归档文件已保存到%s存储: %s，大小 %d 字节", storageService.GetStorageType(), saved.path, size)
+
+	// 先保存文件索引，恢复时据此查找binlog所在的归档
+	for _, file := range files {
+		file.RecordID = record.ID
+	}
+	if err := s.binlogRepo.CreateBatch(files); err != nil {
+		_ = storageService.Delete(saved.path)
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to save binlog file index: %w", err)
+	}
+
+	// 更新记录
+	record.Status = entity.StatusSuccess
+	record.EndTime = time.Now()
+	record.FileSize = saved.size
+	record.OriginalSize = original.n
+	record.CompressedSize = size
+	record.Compression = method
+	record.FilePath = saved.path
+	record.EncryptionKeyID = saved.keyID
+	record.Checksum = saved.checksum
+	record.BackupVersion = backupVersion
+	record.Summary = fmt.Sprintf("binary logs: %s\nbase backup: #%d (%s:%d)", strings.Join(names, ", "), base.ID, base.BinlogFile, base.BinlogPosition)
+	record.StorageType = storageService.GetStorageType()
+	record.Log = runLog.String()
+
+	if err := s.recordRepo.Update(record); err != nil {
+		return record, fmt.Errorf("failed to update backup record: %w", err)
+	}
+
+	// 发送备份成功通知
+	_ = s.webhookService.SendBackupSuccessNotification(
+		task.Name,
+		record.FileSize,
+		record.FilePath,
+		record.EndTime.Sub(record.StartTime),
+	)
+
+	return record, nil
+}
+
+// loadDatabaseSource 读取关联的MySQL数据库备份任务的连接信息
+func (s *BinlogBackupService) loadDatabaseSource(taskID int64) (*entity.DatabaseSourceInfo, error) {
+	dbTask, err := s.taskRepo.FindByID(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find database task: %w", err)
+	}
+	if dbTask == nil {
+		return nil, fmt.Errorf("database task %d not found", taskID)
+	}
+	dbSource, err := s.taskRepo.ParseDatabaseSourceInfo(dbTask)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database source info: %w", err)
+	}
+	if dbSource.Type != "mysql" {
+		return nil, fmt.Errorf("binlog archiving requires a mysql task, task %d is %s", taskID, dbSource.Type)
+	}
+	// 连接信息来自另一个任务，需要单独登记其中的密码
+	redact.RegisterSourceInfo(dbTask.SourceInfo)
+	return dbSource, nil
+}
+
+// archivedBinlogs 返回任务已归档且归档仍可用的binlog文件名
+func (s *BinlogBackupService) archivedBinlogs(taskID int64) (map[string]bool, error) {
+	files, err := s.binlogRepo.FindByTaskID(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	archived := make(map[string]bool)
+	available := make(map[int64]bool)
+	for _, file := range files {
+		ok, checked := available[file.RecordID]
+		if !checked {
+			record, err := s.recordRepo.FindByID(file.RecordID)
+			if err != nil {
+				return nil, err
+			}
+			// 已清理或损坏的归档中的文件需要重新归档
+			ok = record != nil && record.Status == entity.StatusSuccess
+			available[file.RecordID] = ok
+		}
+		if ok {
+			archived[file.Name] = true
+		}
+	}
+	return archived, nil
+}
+
+// GetBackupType 获取备份类型
+func (s *BinlogBackupService) GetBackupType() entity.BackupType {
+	return entity.BinlogBackup
+}
+
+// 更新记录状态
+func (s *BinlogBackupService) updateRecordStatus(record *entity.BackupRecord, runLog *runLog, status entity.BackupStatus, errorMsg string) {
+	// 错误信息可能包含命令行或连接信息，保存和通知前隐藏其中的敏感信息
+	errorMsg = redact.String(errorMsg)
+	runLog.Printf("归档失败: %s", errorMsg)
+
+	record.Status = status
+	record.EndTime = time.Now()
+	record.ErrorMessage = errorMsg
+	record.Log = runLog.String()
+
+	_ = s.recordRepo.Update(record)
+
+	// 如果是失败状态，发送Webhook通知
+	if status == entity.StatusFailed {
+		task, err := s.taskRepo.FindByID(record.TaskID)
+		if err == nil && task != nil {
+			// 附带最近的运行日志，便于直接从通知中判断失败原因
+			message := errorMsg + "\n\n最近的运行日志:\n" + runLog.Tail(notifyLogLines)
+			// 尝试发送通知，忽略错误
+			_ = s.webhookService.SendBackupFailureNotification(
+				task.Name,
+				message,
+			)
+		}
+	}
+}
+
+// mysqlConnectionArgs 生成MySQL客户端工具的连接参数，密码通过环境变量传递
+func mysqlConnectionArgs(sourceInfo *entity.DatabaseSourceInfo, tool *dbclient.MySQLTool) []string {
+	port := sourceInfo.Port
+	if port == 0 {
+		port = 3306
+	}
+	args := []string{
+		"--host=" + sourceInfo.Host,
+		fmt.Sprintf("--port=%d", port),
+		"--user=" + sourceInfo.User,
+	}
+
+	options := sourceInfo.MySQL
+	if options == nil {
+		options = &entity.MySQLOptions{}
+	}
+	return append(args, dbclient.MySQLSSLArgs(options, tool)...)
+}
+
+// runMySQLQuery 使用检测到的命令行客户端执行语句，返回不带表头的输出
+func runMySQLQuery(sourceInfo *entity.DatabaseSourceInfo, client *dbclient.MySQLTool, query string) (string, error) {
+	args := mysqlConnectionArgs(sourceInfo, client)
+	args = append(args, "-N", "-B", "-e", query)
+
+	cmd := exec.Command(client.Program, args...)
+	// 通过环境变量传递密码，避免出现在命令行中
+	cmd.Env = append(os.Environ(), "MYSQL_PWD="+sourceInfo.Password)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%w: %s", err, message)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// listBinaryLogs 查询服务器上的binlog文件，按序号排列，最后一个是正在写入的文件
+func listBinaryLogs(sourceInfo *entity.DatabaseSourceInfo, client *dbclient.MySQLTool) ([]string, error) {
+	output, err := runMySQLQuery(sourceInfo, client, "SHOW BINARY LOGS")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, line := range strings.Split(output, "\n") {
+		// 每行为文件名、大小，MySQL 8.0还有是否加密
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if _, ok := dbclient.BinlogSequence(fields[0]); !ok {
+			return nil, fmt.Errorf("unexpected binary log name: %s", fields[0])
+		}
+		names = append(names, fields[0])
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("binary logging is not enabled on the server")
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		a, _ := dbclient.BinlogSequence(names[i])
+		b, _ := dbclient.BinlogSequence(names[j])
+		return a < b
+	})
+	return names, nil
+}
+
+// selectBinlogs 选出需要归档的binlog文件：从基准完整备份所在的文件开始，到正在写入的文件之前，尚未归档的文件
+// 中间有文件既没有归档也已从服务器删除时，之后的binlog无法用于恢复，返回错误
+func selectBinlogs(serverLogs []string, archived map[string]bool, baseFile string) ([]string, error) {
+	baseSequence, ok := dbclient.BinlogSequence(baseFile)
+	if !ok {
+		return nil, fmt.Errorf("invalid binlog file in base backup: %s", baseFile)
+	}
+	activeSequence, _ := dbclient.BinlogSequence(serverLogs[len(serverLogs)-1])
+
+	available := make(map[int64]bool)
+	for name := range archived {
+		if sequence, ok := dbclient.BinlogSequence(name); ok {
+			available[sequence] = true
+		}
+	}
+
+	var names []string
+	for _, name := range serverLogs[:len(serverLogs)-1] {
+		sequence, _ := dbclient.BinlogSequence(name)
+		if sequence < baseSequence {
+			continue
+		}
+		if !archived[name] {
+			names = append(names, name)
+		}
+		available[sequence] = true
+	}
+
+	for sequence := baseSequence; sequence < activeSequence; sequence++ {
+		if !available[sequence] {
+			return nil, fmt.Errorf("binary log #%d after %s was purged from the server before it was archived, run a new full backup", sequence, baseFile)
+		}
+	}
+	return names, nil
+}
+
+// fetchBinlogs 使用mysqlbinlog从服务器读取binlog原始文件保存到目录中
+func fetchBinlogs(sourceInfo *entity.DatabaseSourceInfo, tool *dbclient.MySQLTool, dir string, names []string, runLog *runLog) error {
+	args := []string{"--read-from-remote-server"}
+	args = append(args, mysqlConnectionArgs(sourceInfo, tool)...)
+	// --result-file以"/"结尾时作为输出目录，文件名与服务器上的相同
+	args = append(args, "--raw", "--result-file="+dir+string(os.PathSeparator))
+	args = append(args, names...)
+
+	cmd := exec.Command(tool.Program, args...)
+	// 通过环境变量传递密码，避免出现在命令行中
+	cmd.Env = append(os.Environ(), "MYSQL_PWD="+sourceInfo.Password)
+	runLog.Printf("执行命令: %s", describeCommand(cmd))
+
+	output := runLog.Writer("mysqlbinlog")
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
+	output.Flush()
+	if err != nil {
+		if message := output.Last(); message != "" {
+			return fmt.Errorf("mysqlbinlog failed: %w: %s", err, message)
+		}
+		return fmt.Errorf("mysqlbinlog failed: %w", err)
+	}
+	return nil
+}
+
+// binlog文件头和事件头的格式
+var binlogMagic = []byte{0xfe, 'b', 'i', 'n'}
+
+const binlogEventHeaderSize = 19
+
+// binlogEndTime 读取binlog文件中最后一个事件的时间
+// 已切换的文件以ROTATE事件结束，其时间即为切换的时间，之后的事件都写入了下一个文件
+func binlogEndTime(path string) (time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	magic := make([]byte, len(binlogMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || !bytes.Equal(magic, binlogMagic) {
+		return time.Time{}, fmt.Errorf("not a binary log file")
+	}
+
+	// 事件头：时间戳(4)、类型(1)、服务器ID(4)、事件长度(4)、下一个事件的位置(4)、标志(2)，均为小端序
+	var end uint32
+	header := make([]byte, binlogEventHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err == io.EOF {
+			break
+		} else if err != nil {
+			return time.Time{}, fmt.Errorf("truncated binary log event: %w", err)
+		}
+		timestamp := binary.LittleEndian.Uint32(header[0:4])
+		size := binary.LittleEndian.Uint32(header[9:13])
+		if size < binlogEventHeaderSize {
+			return time.Time{}, fmt.Errorf("invalid binary log event size %d", size)
+		}
+		if _, err := reader.Discard(int(size - binlogEventHeaderSize)); err != nil {
+			return time.Time{}, fmt.Errorf("truncated binary log event: %w", err)
+		}
+		// 事务中的事件使用语句开始的时间，取最大值
+		if timestamp > end {
+			end = timestamp
+		}
+	}
+	if end == 0 {
+		return time.Time{}, fmt.Errorf("binary log has no events")
+	}
+	return time.Unix(int64(end), 0), nil
+}
+
+// writeBinlogArchive 将目录中的binlog文件按顺序写入tar归档
+func writeBinlogArchive(w io.Writer, dir string, names []string) error {
+	tarWriter := tar.NewWriter(w)
+	for _, name := range names {
+		if err := addBinlogToTar(tarWriter, filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("failed to archive %s: %w", name, err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	return nil
+}
+
+// addBinlogToTar 添加一个binlog文件到tar，归档中只保存文件名
+func addBinlogToTar(tarWriter *tar.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Format = tar.FormatPAX
+
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tarWriter, file)
+	return err
+}
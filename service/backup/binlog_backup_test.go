@@ -0,0 +1,142 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSelectBinlogs(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverLogs []string
+		archived   []string
+		baseFile   string
+		want       []string
+		wantErr    string
+	}{
+		{
+			// 正在写入的最后一个文件不归档
+			name:       "active file excluded",
+			serverLogs: []string{"binlog.000001", "binlog.000002", "binlog.000003"},
+			baseFile:   "binlog.000001",
+			want:       []string{"binlog.000001", "binlog.000002"},
+		},
+		{
+			name:       "only active file",
+			serverLogs: []string{"binlog.000003"},
+			baseFile:   "binlog.000003",
+		},
+		{
+			name:       "skip archived",
+			serverLogs: []string{"binlog.000001", "binlog.000002", "binlog.000003", "binlog.000004"},
+			archived:   []string{"binlog.000001", "binlog.000002"},
+			baseFile:   "binlog.000001",
+			want:       []string{"binlog.000003"},
+		},
+		{
+			name:       "skip files before base",
+			serverLogs: []string{"binlog.000001", "binlog.000002", "binlog.000003", "binlog.000004"},
+			baseFile:   "binlog.000003",
+			want:       []string{"binlog.000003"},
+		},
+		{
+			// 已从服务器删除但已归档的文件不影响连续性
+			name:       "purged after archiving",
+			serverLogs: []string{"binlog.000003", "binlog.000004"},
+			archived:   []string{"binlog.000001", "binlog.000002"},
+			baseFile:   "binlog.000001",
+			want:       []string{"binlog.000003"},
+		},
+		{
+			name:       "purged before archiving",
+			serverLogs: []string{"binlog.000003", "binlog.000004"},
+			archived:   []string{"binlog.000001"},
+			baseFile:   "binlog.000001",
+			wantErr:    "binary log #2",
+		},
+		{
+			name:       "gap in archive",
+			serverLogs: []string{"binlog.000004", "binlog.000005"},
+			archived:   []string{"binlog.000001", "binlog.000003"},
+			baseFile:   "binlog.000001",
+			wantErr:    "binary log #2",
+		},
+		{
+			name:       "invalid base file",
+			serverLogs: []string{"binlog.000001"},
+			baseFile:   "",
+			wantErr:    "invalid binlog file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archived := make(map[string]bool)
+			for _, name := range tt.archived {
+				archived[name] = true
+			}
+			got, err := selectBinlogs(tt.serverLogs, archived, tt.baseFile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// binlogEvent 生成指定时间和正文长度的binlog事件
+func binlogEvent(timestamp uint32, bodySize int) []byte {
+	event := make([]byte, binlogEventHeaderSize+bodySize)
+	binary.LittleEndian.PutUint32(event[0:4], timestamp)
+	binary.LittleEndian.PutUint32(event[9:13], uint32(len(event)))
+	return event
+}
+
+func TestBinlogEndTime(t *testing.T) {
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		want    int64
+		wantErr bool
+	}{
+		{"rotate event last", join(binlogMagic, binlogEvent(100, 100), binlogEvent(200, 30), binlogEvent(300, 25)), 300, false},
+		// 长事务的事件时间可能早于之前的事件，取最大值
+		{"out of order", join(binlogMagic, binlogEvent(100, 10), binlogEvent(500, 10), binlogEvent(400, 10)), 500, false},
+		{"truncated event", join(binlogMagic, binlogEvent(100, 10), binlogEvent(200, 10)[:25]), 0, true},
+		{"truncated header", join(binlogMagic, binlogEvent(100, 10), []byte{1, 2, 3}), 0, true},
+		{"no events", binlogMagic, 0, true},
+		{"not a binlog", []byte("plain text file"), 0, true},
+		{"invalid event size", join(binlogMagic, binlogEvent(100, 0)[:9], []byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0}), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "binlog.000001")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := binlogEndTime(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !got.Equal(time.Unix(tt.want, 0)) {
+				t.Errorf("got %v, want %v", got, time.Unix(tt.want, 0))
+			}
+		})
+	}
+}
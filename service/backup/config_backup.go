@@ -0,0 +1,438 @@
+package backup
+
+import (
+	"archive/zip"
+	"backup-go/entity"
+	"backup-go/repository"
+	"backup-go/service/compression"
+	"backup-go/service/config"
+	"backup-go/service/redact"
+	"backup-go/service/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// 配置备份归档格式版本，便于恢复时判断兼容性
+// 版本2增加了binlog_files表
+const configBackupFormatVersion = 2
+
+// 不导出的系统配置
+// 配置备份本身也会用密钥环加密，密钥环放在备份中，服务器丢失后既无法解密备份，也等于把密钥和密文存放在一起
+var excludedConfigKeys = []string{"encryption.keys"}
+
+// ConfigBackupManifest 配置备份归档的描述信息
+type ConfigBackupManifest struct {
+	FormatVersion   int            `json:"formatVersion"`             // 归档格式版本
+	ExportedAt      time.Time      `json:"exportedAt"`                // 导出时间
+	Tables          map[string]int `json:"tables"`                    // 各数据表导出的行数
+	ExcludedConfigs []string       `json:"excludedConfigs,omitempty"` // 未导出的系统配置，需要另外保管
+}
+
+// ConfigBackupService 配置备份服务，导出backup-go自身的任务、配置和备份记录
+type ConfigBackupService struct {
+	taskRepo       *repository.BackupTaskRepository
+	recordRepo     *repository.BackupRecordRepository
+	configRepo     *repository.ConfigRepository
+	storageType    entity.StorageType
+	webhookService *config.WebhookService
+}
+
+// NewConfigBackupService 创建配置备份服务
+func NewConfigBackupService() *ConfigBackupService {
+	return &ConfigBackupService{
+		taskRepo:       repository.NewBackupTaskRepository(),
+		recordRepo:     repository.NewBackupRecordRepository(),
+		configRepo:     repository.NewConfigRepository(),
+		storageType:    entity.LocalStorage, // 默认使用本地存储
+		webhookService: config.NewWebhookService(),
+	}
+}
+
+// Execute 执行备份
+func (s *ConfigBackupService) Execute(task *entity.BackupTask) (*entity.BackupRecord, error) {
+	// 创建备份记录
+	record := &entity.BackupRecord{
+		TaskID:    task.ID,
+		Status:    entity.StatusRunning,
+		StartTime: time.Now(),
+	}
+	err := s.recordRepo.Create(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup record: %w", err)
+	}
+
+	// 执行备份
+	backupVersion := time.Now().Format("20060102150405")
+
+	// 为任务名称去除特殊字符，避免不合法的文件名
+	safeName := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, task.Name)
+	filename := fmt.Sprintf("task_%d_%s_config_%s.zip", task.ID, safeName, backupVersion)
+
+	// 创建临时目录
+	tempDir, err := ioutil.TempDir("", "config_backup")
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// 导出数据到ZIP文件
+	tempFilePath := filepath.Join(tempDir, filename)
+	originalSize, err := s.export(tempFilePath)
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to export config: %w", err)
+	}
+
+	// 获取文件大小
+	fileInfo, err := os.Stat(tempFilePath)
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	// 读取备份文件
+	backupData, err := os.Open(tempFilePath)
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to read backup file: %w", err)
+	}
+	defer backupData.Close()
+
+	// 上传到存储
+	storageService, err := storage.NewStorageService("")
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to create storage service: %w", err)
+	}
+
+	saved, err := saveBackupFile(storageService, filename, backupData)
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to save backup file: %w", err)
+	}
+
+	// 更新记录
+	record.Status = entity.StatusSuccess
+	record.EndTime = time.Now()
+	record.FileSize = saved.size
+	record.OriginalSize = originalSize
+	record.CompressedSize = fileInfo.Size()
+	record.Compression = compression.Deflate
+	record.FilePath = saved.path
+	record.EncryptionKeyID = saved.keyID
+	record.Checksum = saved.checksum
+	record.BackupVersion = backupVersion
+	record.StorageType = storageService.GetStorageType()
+
+	if err := s.recordRepo.Update(record); err != nil {
+		return record, fmt.Errorf("failed to update backup record: %w", err)
+	}
+
+	// 发送备份成功通知
+	task, tErr := s.taskRepo.FindByID(record.TaskID)
+	if tErr == nil && task != nil {
+		duration := record.EndTime.Sub(record.StartTime)
+		// 尝试发送通知，忽略错误
+		_ = s.webhookService.SendBackupSuccessNotification(
+			task.Name,
+			record.FileSize,
+			record.FilePath,
+			duration,
+		)
+	}
+
+	return record, nil
+}
+
+// export 将各数据表导出为JSON文件并打包为ZIP，返回压缩前JSON文件的总大小
+func (s *ConfigBackupService) export(zipPath string) (int64, error) {
+	tasks, err := s.taskRepo.FindAll()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load backup tasks: %w", err)
+	}
+	allConfigs, err := s.configRepo.FindAll()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load system configs: %w", err)
+	}
+	configs := make([]*entity.SystemConfig, 0, len(allConfigs))
+	for _, config := range allConfigs {
+		if !isExcludedConfig(config.ConfigKey) {
+			configs = append(configs, config)
+		}
+	}
+	// binlog文件索引先于备份记录读取：归档时先创建记录再保存文件索引，这样读到的文件引用的记录都会被导出
+	allBinlogFiles, err := repository.NewBinlogFileRepository().ListAll()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load binlog files: %w", err)
+	}
+	records, err := s.recordRepo.ListAll()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load backup records: %w", err)
+	}
+	// 时间点恢复和binlog归档依赖binlog文件索引，只导出所属记录也被导出的文件
+	exported := make(map[int64]bool, len(records))
+	for _, record := range records {
+		exported[record.ID] = true
+	}
+	binlogFiles := make([]*entity.BinlogFile, 0, len(allBinlogFiles))
+	for _, file := range allBinlogFiles {
+		if exported[file.RecordID] {
+			binlogFiles = append(binlogFiles, file)
+		}
+	}
+	// 仓库格式的备份依赖数据块索引才能恢复
+	chunks, err := repository.NewChunkRepository().ListAll()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load chunks: %w", err)
+	}
+
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create zip file: %w", err)
+	}
+	defer zipFile.Close()
+
+	zipWriter := zip.NewWriter(zipFile)
+
+	// 每张表一个JSON文件，文件名与表名一致
+	tables := []struct {
+		name string
+		rows interface{}
+	}{
+		{entity.BackupTask{}.TableName(), tasks},
+		{entity.SystemConfig{}.TableName(), configs},
+		{entity.BackupRecord{}.TableName(), records},
+		{entity.Chunk{}.TableName(), chunks},
+		{entity.BinlogFile{}.TableName(), binlogFiles},
+	}
+	var originalSize int64
+	for _, table := range tables {
+		n, err := writeZipJSON(zipWriter, table.name+".json", table.rows)
+		if err != nil {
+			zipWriter.Close()
+			return 0, err
+		}
+		originalSize += n
+	}
+
+	manifest := &ConfigBackupManifest{
+		FormatVersion: configBackupFormatVersion,
+		ExportedAt:    time.Now(),
+		Tables: map[string]int{
+			entity.BackupTask{}.TableName():   len(tasks),
+			entity.SystemConfig{}.TableName(): len(configs),
+			entity.BackupRecord{}.TableName(): len(records),
+			entity.Chunk{}.TableName():        len(chunks),
+			entity.BinlogFile{}.TableName():   len(binlogFiles),
+		},
+		ExcludedConfigs: excludedConfigKeys,
+	}
+	n, err := writeZipJSON(zipWriter, "manifest.json", manifest)
+	if err != nil {
+		zipWriter.Close()
+		return 0, err
+	}
+	originalSize += n
+
+	return originalSize, zipWriter.Close()
+}
+
+// isExcludedConfig 判断系统配置是否不导出
+func isExcludedConfig(key string) bool {
+	for _, excluded := range excludedConfigKeys {
+		if key == excluded {
+			return true
+		}
+	}
+	return false
+}
+
+// writeZipJSON 将数据以JSON格式写入ZIP中的指定文件，返回写入的字节数
+func writeZipJSON(zipWriter *zip.Writer, name string, data interface{}) (int64, error) {
+	writer, err := zipWriter.Create(name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", name, err)
+	}
+
+	counter := &countingWriter{writer: writer}
+	encoder := json.NewEncoder(counter)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return counter.n, nil
+}
+
+// Import 从配置备份归档中导入任务、系统配置、备份记录、数据块索引和binlog文件索引
+// 任务、备份记录和索引整表替换并保留原有ID，系统配置按键更新，不导出的配置（如密钥环）保持不变
+// 版本1的归档没有binlog文件索引，导入后索引为空
+func (s *ConfigBackupService) Import(r io.ReaderAt, size int64) (*ConfigBackupManifest, error) {
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config backup: %w", err)
+	}
+
+	manifest := &ConfigBackupManifest{}
+	if err := readZipJSON(zipReader, "manifest.json", manifest); err != nil {
+		return nil, err
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > configBackupFormatVersion {
+		return nil, fmt.Errorf("unsupported config backup format version: %d", manifest.FormatVersion)
+	}
+
+	var tasks []*entity.BackupTask
+	var configs []*entity.SystemConfig
+	var records []*entity.BackupRecord
+	var chunks []*entity.Chunk
+	var binlogFiles []*entity.BinlogFile
+	tables := []struct {
+		name string
+		rows interface{}
+	}{
+		{entity.BackupTask{}.TableName(), &tasks},
+		{entity.SystemConfig{}.TableName(), &configs},
+		{entity.BackupRecord{}.TableName(), &records},
+		{entity.Chunk{}.TableName(), &chunks},
+		{entity.BinlogFile{}.TableName(), &binlogFiles},
+	}
+	for _, table := range tables {
+		if table.name == (entity.BinlogFile{}).TableName() && manifest.FormatVersion < 2 {
+			continue
+		}
+		if err := readZipJSON(zipReader, table.name+".json", table.rows); err != nil {
+			return nil, err
+		}
+	}
+
+	// 创建时enabled为零值会被替换为默认值，先记下已停用的任务，插入后再单独更新
+	var disabledTaskIDs []int64
+	for _, task := range tasks {
+		if !task.Enabled {
+			disabledTaskIDs = append(disabledTaskIDs, task.ID)
+		}
+	}
+
+	// 开始事务，导入失败时保持原有数据不变
+	tx := repository.GetDB().Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	// 备份记录引用任务ID，增量备份引用父记录ID，数据块索引被仓库格式的清单引用，binlog文件索引引用归档记录ID，保留ID才能继续使用
+	replaced := []struct {
+		model interface{}
+		rows  interface{}
+		count int
+	}{
+		{&entity.BackupTask{}, tasks, len(tasks)},
+		{&entity.BackupRecord{}, records, len(records)},
+		{&entity.Chunk{}, chunks, len(chunks)},
+		{&entity.BinlogFile{}, binlogFiles, len(binlogFiles)},
+	}
+	for _, table := range replaced {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(table.model).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to clear table: %w", err)
+		}
+		if table.count == 0 {
+			continue
+		}
+		if err := tx.CreateInBatches(table.rows, 100).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to import rows: %w", err)
+		}
+	}
+
+	if len(disabledTaskIDs) > 0 {
+		if err := tx.Model(&entity.BackupTask{}).Where("id IN ?", disabledTaskIDs).Update("enabled", false).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to import task status: %w", err)
+		}
+	}
+
+	for _, config := range configs {
+		if isExcludedConfig(config.ConfigKey) {
+			continue
+		}
+		var existing entity.SystemConfig
+		err := tx.Where("config_key = ?", config.ConfigKey).First(&existing).Error
+		switch {
+		case err == nil:
+			existing.ConfigValue = config.ConfigValue
+			existing.Description = config.Description
+			err = tx.Save(&existing).Error
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// ID可能与现有配置冲突，由数据库重新分配
+			config.ID = 0
+			err = tx.Create(config).Error
+		}
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to import config %s: %w", config.ConfigKey, err)
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// readZipJSON 读取ZIP中的指定JSON文件
+func readZipJSON(zipReader *zip.Reader, name string, data interface{}) error {
+	file, err := zipReader.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(data); err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return nil
+}
+
+// GetBackupType 获取备份类型
+func (s *ConfigBackupService) GetBackupType() entity.BackupType {
+	return entity.ConfigBackup
+}
+
+// 更新记录状态
+func (s *ConfigBackupService) updateRecordStatus(record *entity.BackupRecord, status entity.BackupStatus, errorMsg string) {
+	// 错误信息可能包含命令行或连接信息，保存和通知前隐藏其中的敏感信息
+	errorMsg = redact.String(errorMsg)
+
+	record.Status = status
+	record.EndTime = time.Now()
+	record.ErrorMessage = errorMsg
+
+	_ = s.recordRepo.Update(record)
+
+	// 如果是失败状态，发送Webhook通知
+	if status == entity.StatusFailed {
+		task, err := s.taskRepo.FindByID(record.TaskID)
+		if err == nil && task != nil {
+			// 尝试发送通知，忽略错误
+			_ = s.webhookService.SendBackupFailureNotification(
+				task.Name,
+				errorMsg,
+			)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package backup
+
+import (
+	"archive/zip"
+	"backup-go/config"
+	"backup-go/entity"
+	"backup-go/repository"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupDB 在临时目录中创建SQLite数据库
+func setupDB(t *testing.T) {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+
+	if err := config.LoadConfig("config.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.InitDB(); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.MigrateDB(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setConfig 创建或更新系统配置
+func setConfig(t *testing.T, key, value string) {
+	t.Helper()
+	configRepo := repository.NewConfigRepository()
+	existing, err := configRepo.FindByKey(key)
+	if err != nil {
+		err = configRepo.Create(&entity.SystemConfig{ConfigKey: key, ConfigValue: value})
+	} else {
+		existing.ConfigValue = value
+		err = configRepo.Update(existing)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigBackupExportImport(t *testing.T) {
+	setupDB(t)
+	taskRepo := repository.NewBackupTaskRepository()
+	recordRepo := repository.NewBackupRecordRepository()
+	configRepo := repository.NewConfigRepository()
+
+	task := &entity.BackupTask{Name: "db", Type: entity.DatabaseBackup, SourceInfo: "{}", Schedule: "0 0 2 * * *", Enabled: false}
+	if err := taskRepo.Create(task); err != nil {
+		t.Fatal(err)
+	}
+	// 创建时enabled为零值会使用默认值，停用需要单独更新
+	task.Enabled = false
+	if err := taskRepo.Update(task); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordRepo.Create(&entity.BackupRecord{TaskID: task.ID, Status: entity.StatusSuccess, FilePath: "20240101/db.sql.gz"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.NewChunkRepository().Create(&entity.Chunk{Hash: "abc", StorageType: entity.LocalStorage, Path: "chunks/ab/abc"}); err != nil {
+		t.Fatal(err)
+	}
+	binlogRecord := &entity.BackupRecord{TaskID: task.ID, Status: entity.StatusSuccess, FilePath: "20240101/binlog.tar"}
+	if err := recordRepo.Create(binlogRecord); err != nil {
+		t.Fatal(err)
+	}
+	coveredUntil := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	binlogRepo := repository.NewBinlogFileRepository()
+	if err := binlogRepo.CreateBatch([]*entity.BinlogFile{{TaskID: task.ID, RecordID: binlogRecord.ID, Name: "binlog.000007", Size: 100, CoveredUntil: coveredUntil}}); err != nil {
+		t.Fatal(err)
+	}
+	setConfig(t, "system.siteName", "exported")
+	setConfig(t, "encryption.keys", `{"k1":{"type":"passphrase","passphrase":"secret"}}`)
+
+	service := NewConfigBackupService()
+	zipPath := filepath.Join(t.TempDir(), "config.zip")
+	originalSize, err := service.export(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if originalSize == 0 {
+		t.Error("export reported zero original size")
+	}
+
+	// 密钥环不能出现在导出的配置中
+	zipReader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var configs []*entity.SystemConfig
+	if err := readZipJSON(&zipReader.Reader, "system_configs.json", &configs); err != nil {
+		t.Fatal(err)
+	}
+	zipReader.Close()
+	for _, c := range configs {
+		if c.ConfigKey == "encryption.keys" {
+			t.Fatal("encryption.keys exported")
+		}
+	}
+
+	// 修改导出后的数据，导入后应恢复为导出时的状态
+	if err := taskRepo.Delete(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordRepo.DeleteByTaskID(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := binlogRepo.DeleteByRecordID(binlogRecord.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := taskRepo.Create(&entity.BackupTask{Name: "other", Type: entity.FileBackup, SourceInfo: "{}", Schedule: "0 0 3 * * *", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	setConfig(t, "system.siteName", "changed")
+	setConfig(t, "encryption.keys", `{"k2":{"type":"passphrase","passphrase":"new"}}`)
+
+	file, err := os.Open(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := service.Import(file, info.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Tables["backup_tasks"] != 1 || manifest.Tables["binlog_files"] != 1 {
+		t.Errorf("manifest tables = %v", manifest.Tables)
+	}
+
+	tasks, err := taskRepo.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != task.ID || tasks[0].Name != "db" || tasks[0].Enabled {
+		t.Fatalf("tasks after import = %+v", tasks)
+	}
+	records, err := recordRepo.FindByTaskID(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make(map[string]bool)
+	for _, record := range records {
+		paths[record.FilePath] = true
+	}
+	if len(records) != 2 || !paths["20240101/db.sql.gz"] || !paths["20240101/binlog.tar"] {
+		t.Fatalf("records after import = %+v", records)
+	}
+	files, err := binlogRepo.FindByTaskID(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].RecordID != binlogRecord.ID || files[0].Name != "binlog.000007" || !files[0].CoveredUntil.Equal(coveredUntil) {
+		t.Fatalf("binlog files after import = %+v", files)
+	}
+	chunk, err := repository.NewChunkRepository().FindByHash("abc", entity.LocalStorage, "")
+	if err != nil || chunk == nil {
+		t.Fatalf("chunk after import = %v, %v", chunk, err)
+	}
+
+	siteName, err := configRepo.FindByKey("system.siteName")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if siteName.ConfigValue != "exported" {
+		t.Errorf("system.siteName = %q, want exported", siteName.ConfigValue)
+	}
+	keys, err := configRepo.FindByKey("encryption.keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.ConfigValue != `{"k2":{"type":"passphrase","passphrase":"new"}}` {
+		t.Errorf("encryption.keys changed by import: %q", keys.ConfigValue)
+	}
+}
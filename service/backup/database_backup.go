@@ -3,14 +3,16 @@ package backup
 import (
 	"backup-go/entity"
 	"backup-go/repository"
+	"backup-go/service/compression"
 	"backup-go/service/config"
+	"backup-go/service/dbclient"
+	"backup-go/service/redact"
 	"backup-go/service/storage"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 	"time"
 	"unicode"
@@ -53,6 +55,10 @@ func (s *DatabaseBackupService) Execute(task *entity.BackupTask) (*entity.Backup
 		return nil, fmt.Errorf("failed to create backup record: %w", err)
 	}
 
+	// 运行日志随备份记录保存，用于排查失败原因
+	runLog := newRunLog()
+	runLog.Printf("开始备份任务 %s，数据库类型: %s", task.Name, sourceInfo.Type)
+
 	// 执行备份
 	backupVersion := time.Now().Format("20060102150405")
 
@@ -65,103 +71,140 @@ func (s *DatabaseBackupService) Execute(task *entity.BackupTask) (*entity.Backup
 		return '_'
 	}, task.Name)
 
+	// 根据数据库类型确定文件扩展名
+	ext, err := s.fileExtension(sourceInfo)
+	if err != nil {
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+		return record, err
+	}
+
+	// mongodump的归档已经使用gzip压缩，不再额外压缩
+	method := sourceInfo.Compression
+	if method == "" || sourceInfo.Type == "mongodb" {
+		method = compression.None
+	}
+	if err := compression.Validate(method, sourceInfo.CompressionLevel); err != nil {
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+		return record, err
+	}
+
 	var filename string
 	if sourceInfo.Database == "" || sourceInfo.Database == "all" {
-		filename = fmt.Sprintf("task_%d_%s_all_databases_%s.sql", task.ID, safeName, backupVersion)
+		filename = fmt.Sprintf("task_%d_%s_all_databases_%s%s", task.ID, safeName, backupVersion, ext)
 	} else {
-		filename = fmt.Sprintf("task_%d_%s_%s_%s.sql", task.ID, safeName, sourceInfo.Database, backupVersion)
+		filename = fmt.Sprintf("task_%d_%s_%s_%s%s", task.ID, safeName, sourceInfo.Database, backupVersion, ext)
 	}
 
-	// 创建临时目录
-	//tempDir, err := ioutil.TempDir("", "db_backup")
-	tempDir, err := ioutil.TempDir("", "db_backup")
-	if err != nil {
-		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
-		return record, fmt.Errorf("failed to create temp directory: %w", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	tempFilePath := filepath.Join(tempDir, filename)
+	filename += compression.Extension(method)
 
-	// 执行备份命令
+	// 备份命令输出到标准输出
 	var cmd *exec.Cmd
+	var summary string
 	switch sourceInfo.Type {
 	case "mysql":
-		// 构造基本的mysqldump命令参数
-		args := []string{
-			"-h" + sourceInfo.Host,
-			"-P" + fmt.Sprintf("%d", sourceInfo.Port),
-			"-u" + sourceInfo.User,
-			"-p" + sourceInfo.Password,
-			"--result-file=" + tempFilePath,
-			"--ssl-mode=DISABLED", // mysql
-			//"--ssl=0", // 打包 mariadb
+		if err := validateMySQLOptions(sourceInfo); err != nil {
+			s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+			return record, err
 		}
-
-		// 判断是否为全库备份（备份所有数据库）
-		if sourceInfo.Database == "" || sourceInfo.Database == "all" {
-			// 备份所有数据库
-			args = append(args, "--all-databases")
-		} else {
-			// 备份指定的数据库
-			args = append(args, "--databases", sourceInfo.Database)
+		dumper := dbclient.DetectMySQLDump(dbclient.MySQLFlavor(sourceInfo))
+		summary = "dump tool: " + dumper.String()
+		runLog.Printf("导出工具: %s", dumper)
+		cmd = s.buildMySQLCommand(sourceInfo, dumper)
+	case "postgres":
+		cmd = s.buildPostgresCommand(sourceInfo)
+	case "mongodb":
+		var cleanup func()
+		var err error
+		cmd, cleanup, err = s.buildMongoCommand(sourceInfo)
+		if err != nil {
+			s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+			return record, err
 		}
-
-		// 用于调试，输出执行的命令
-		cmdStr := "mysqldump "
-		for _, arg := range args {
-			cmdStr += arg + " "
-		}
-		log.Println(cmdStr)
-
-		cmd = exec.Command("mysqldump", args...)
+		defer cleanup()
 	default:
 		err := fmt.Errorf("unsupported database type: %s", sourceInfo.Type)
-		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
 		return record, err
 	}
 
-	// 执行命令
-	if err := cmd.Run(); err != nil {
-		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
-		return record, fmt.Errorf("backup command failed: %w", err)
-	}
+	// 输出执行的命令（隐藏密码）
+	commandLine := describeCommand(cmd)
+	log.Println(commandLine)
+	runLog.Printf("执行命令: %s", commandLine)
 
-	// 获取文件大小
-	fileInfo, err := os.Stat(tempFilePath)
+	storageService, err := storage.NewStorageService("")
 	if err != nil {
-		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
-		return record, fmt.Errorf("failed to get file info: %w", err)
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to create storage service: %w", err)
 	}
 
-	// 读取备份文件
-	backupData, err := os.Open(tempFilePath)
-	if err != nil {
-		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
-		return record, fmt.Errorf("failed to read backup file: %w", err)
-	}
-	defer backupData.Close()
+	// 命令输出经压缩、加密后直接上传，不写入本地磁盘
+	original := &countingWriter{}
 
-	// 上传到存储
-	storageService, err := storage.NewStorageService("")
-	if err != nil {
-		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
-		return record, fmt.Errorf("failed to create storage service: %w", err)
+	// 开启记录binlog位置时从导出数据中解析，用于binlog归档和时间点恢复
+	var position *binlogPositionWriter
+	var stdout io.Writer = original
+	if sourceInfo.Type == "mysql" && sourceInfo.MySQL != nil && sourceInfo.MySQL.BinlogPosition {
+		position = &binlogPositionWriter{writer: original}
+		stdout = position
 	}
+	saved, size, err := streamBackupFile(storageService, filename, func(w io.Writer) error {
+		compressor, err := compression.NewWriter(w, method, sourceInfo.CompressionLevel)
+		if err != nil {
+			return err
+		}
+		original.writer = compressor
 
-	filePath, err := storageService.Save(filename, backupData)
+		// 标准输出是备份数据，标准错误中的警告和错误写入运行日志
+		stderr := runLog.Writer("stderr")
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		err = cmd.Run()
+		stderr.Flush()
+		if err != nil {
+			compressor.Close()
+			if message := stderr.Last(); message != "" {
+				return fmt.Errorf("backup command failed: %w: %s", err, message)
+			}
+			return fmt.Errorf("backup command failed: %w", err)
+		}
+		runLog.Printf("命令执行完成，导出数据 %d 字节", original.n)
+		return compressor.Close()
+	})
 	if err != nil {
-		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		s.updateRecordStatus(record, runLog, entity.StatusFailed, err.Error())
 		return record, fmt.Errorf("failed to save backup file: %w", err)
 	}
+	runLog.Printf("备份文件已保存到%s存储: %s，大小 %d 字节", storageService.GetStorageType(), saved.path, size)
+
+	if position != nil {
+		if position.file != "" {
+			record.BinlogFile = position.file
+			record.BinlogPosition = position.position
+			summary += fmt.Sprintf("\nbinlog position: %s:%d", position.file, position.position)
+			runLog.Printf("binlog位置: %s:%d", position.file, position.position)
+		} else {
+			runLog.Printf("警告: 导出数据中没有找到binlog位置，请确认服务器已开启binlog")
+		}
+	}
 
 	// 更新记录
 	record.Status = entity.StatusSuccess
 	record.EndTime = time.Now()
-	record.FileSize = fileInfo.Size()
-	record.FilePath = filePath
+	record.FileSize = saved.size
+	record.OriginalSize = original.n
+	record.CompressedSize = size
+	record.Compression = method
+	if sourceInfo.Type == "mongodb" {
+		record.Compression = compression.Gzip
+	}
+	record.FilePath = saved.path
+	record.EncryptionKeyID = saved.keyID
+	record.Checksum = saved.checksum
 	record.BackupVersion = backupVersion
+	record.Summary = summary
 	record.StorageType = storageService.GetStorageType()
+	record.Log = runLog.String()
 
 	if err := s.recordRepo.Update(record); err != nil {
 		return record, fmt.Errorf("failed to update backup record: %w", err)
@@ -183,16 +226,141 @@ func (s *DatabaseBackupService) Execute(task *entity.BackupTask) (*entity.Backup
 	return record, nil
 }
 
+// fileExtension 根据数据库类型和导出格式确定备份文件扩展名
+func (s *DatabaseBackupService) fileExtension(sourceInfo *entity.DatabaseSourceInfo) (string, error) {
+	switch sourceInfo.Type {
+	case "mysql":
+		return ".sql", nil
+	case "postgres":
+		switch sourceInfo.Format {
+		case "", "plain":
+			return ".sql", nil
+		case "custom":
+			// pg_dumpall只支持纯文本格式
+			if sourceInfo.Database == "" || sourceInfo.Database == "all" {
+				return "", fmt.Errorf("custom format is not supported when backing up all databases")
+			}
+			return ".dump", nil
+		default:
+			return "", fmt.Errorf("unsupported postgres format: %s", sourceInfo.Format)
+		}
+	case "mongodb":
+		return ".archive.gz", nil
+	default:
+		return "", fmt.Errorf("unsupported database type: %s", sourceInfo.Type)
+	}
+}
+
+// buildMySQLCommand 构造mysqldump命令
+func (s *DatabaseBackupService) buildMySQLCommand(sourceInfo *entity.DatabaseSourceInfo, dumper *dbclient.MySQLTool) *exec.Cmd {
+	// 构造基本的mysqldump命令参数
+	args := []string{
+		"-h" + sourceInfo.Host,
+		"-P" + fmt.Sprintf("%d", sourceInfo.Port),
+		"-u" + sourceInfo.User,
+	}
+	args = append(args, mysqlDumpArgs(sourceInfo, dumper)...)
+
+	cmd := exec.Command(dumper.Program, args...)
+	// 通过环境变量传递密码，避免出现在命令行中
+	cmd.Env = append(os.Environ(), "MYSQL_PWD="+sourceInfo.Password)
+	return cmd
+}
+
+// buildPostgresCommand 构造pg_dump或pg_dumpall命令
+func (s *DatabaseBackupService) buildPostgresCommand(sourceInfo *entity.DatabaseSourceInfo) *exec.Cmd {
+	port := sourceInfo.Port
+	if port == 0 {
+		port = 5432
+	}
+
+	// 基本连接参数，-w表示不交互式询问密码
+	args := []string{
+		"-h", sourceInfo.Host,
+		"-p", fmt.Sprintf("%d", port),
+		"-U", sourceInfo.User,
+		"-w",
+	}
+
+	program := "pg_dump"
+	if sourceInfo.Database == "" || sourceInfo.Database == "all" {
+		// 备份整个集群
+		program = "pg_dumpall"
+	} else {
+		// 备份指定的数据库
+		if sourceInfo.Format == "custom" {
+			args = append(args, "-F", "c")
+		} else {
+			args = append(args, "-F", "p")
+		}
+
+		// 只备份指定的模式
+		for _, schema := range strings.Split(sourceInfo.Schema, ",") {
+			schema = strings.TrimSpace(schema)
+			if schema != "" {
+				args = append(args, "-n", schema)
+			}
+		}
+
+		args = append(args, "-d", sourceInfo.Database)
+	}
+
+	cmd := exec.Command(program, args...)
+	// 通过环境变量传递密码，避免出现在命令行中
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+sourceInfo.Password)
+	return cmd
+}
+
+// buildMongoCommand 构造mongodump命令，向标准输出写入gzip压缩的归档
+// 返回的cleanup在命令结束后调用，删除保存密码的临时配置文件
+func (s *DatabaseBackupService) buildMongoCommand(sourceInfo *entity.DatabaseSourceInfo) (*exec.Cmd, func(), error) {
+	args, cleanup, err := dbclient.MongoToolArgs(sourceInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 不指定文件名时归档写入标准输出
+	args = append(args, "--archive", "--gzip")
+
+	// 为空或"all"时备份整个部署
+	if sourceInfo.Database != "" && sourceInfo.Database != "all" {
+		args = append(args, "--db="+sourceInfo.Database)
+	}
+
+	return exec.Command("mongodump", args...), cleanup, nil
+}
+
+// describeCommand 生成用于日志的命令行，隐藏参数中的密码
+func describeCommand(cmd *exec.Cmd) string {
+	args := make([]string, len(cmd.Args))
+	for i, arg := range cmd.Args {
+		switch {
+		case strings.HasPrefix(arg, "--password="):
+			args[i] = "--password=" + redact.Mask
+		case strings.HasPrefix(arg, "--uri="):
+			args[i] = "--uri=" + redact.URI(strings.TrimPrefix(arg, "--uri="))
+		default:
+			args[i] = arg
+		}
+	}
+	return strings.Join(args, " ")
+}
+
 // GetBackupType 获取备份类型
 func (s *DatabaseBackupService) GetBackupType() entity.BackupType {
 	return entity.DatabaseBackup
 }
 
 // 更新记录状态
-func (s *DatabaseBackupService) updateRecordStatus(record *entity.BackupRecord, status entity.BackupStatus, errorMsg string) {
+func (s *DatabaseBackupService) updateRecordStatus(record *entity.BackupRecord, runLog *runLog, status entity.BackupStatus, errorMsg string) {
+	// 错误信息可能包含命令行或连接信息，保存和通知前隐藏其中的敏感信息
+	errorMsg = redact.String(errorMsg)
+	runLog.Printf("备份失败: %s", errorMsg)
+
 	record.Status = status
 	record.EndTime = time.Now()
 	record.ErrorMessage = errorMsg
+	record.Log = runLog.String()
 
 	_ = s.recordRepo.Update(record)
 
@@ -200,10 +368,12 @@ func (s *DatabaseBackupService) updateRecordStatus(record *entity.BackupRecord,
 	if status == entity.StatusFailed {
 		task, err := s.taskRepo.FindByID(record.TaskID)
 		if err == nil && task != nil {
+			// 附带最近的运行日志，便于直接从通知中判断失败原因
+			message := errorMsg + "\n\n最近的运行日志:\n" + runLog.Tail(notifyLogLines)
 			// 尝试发送通知，忽略错误
 			_ = s.webhookService.SendBackupFailureNotification(
 				task.Name,
-				errorMsg,
+				message,
 			)
 		}
 	}
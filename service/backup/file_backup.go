@@ -1,14 +1,23 @@
 package backup
 
 import (
+	"archive/tar"
 	"archive/zip"
 	"backup-go/entity"
 	"backup-go/repository"
+	"backup-go/service/compression"
 	"backup-go/service/config"
+	"backup-go/service/manifest"
+	"backup-go/service/redact"
 	"backup-go/service/storage"
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
 	"time"
@@ -51,80 +60,103 @@ func (s *FileBackupService) Execute(task *entity.BackupTask) (*entity.BackupReco
 		return nil, fmt.Errorf("failed to create backup record: %w", err)
 	}
 
-	// 执行备份
-	backupVersion := time.Now().Format("20060102150405")
-	filename := fmt.Sprintf("files_%s.zip", backupVersion)
-
-	// 创建临时目录
-	tempDir, err := ioutil.TempDir("", "file_backup")
+	// 确定归档格式和压缩方式
+	format, method, err := s.archiveOptions(sourceInfo)
 	if err != nil {
 		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
-		return record, fmt.Errorf("failed to create temp directory: %w", err)
+		return record, err
 	}
-	defer os.RemoveAll(tempDir)
 
-	// 创建ZIP文件
-	tempFilePath := filepath.Join(tempDir, filename)
-	zipFile, err := os.Create(tempFilePath)
+	// 包含、排除等过滤规则
+	filter, err := newFileFilter(sourceInfo)
 	if err != nil {
 		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
-		return record, fmt.Errorf("failed to create zip file: %w", err)
+		return record, err
 	}
 
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
-	// 添加文件到ZIP
-	for _, path := range sourceInfo.Paths {
-		err = s.addFileToZip(zipWriter, path, "")
-		if err != nil {
-			zipWriter.Close()
-			zipFile.Close()
-			s.updateRecordStatus(record, entity.StatusFailed, err.Error())
-			return record, fmt.Errorf("failed to add file to zip: %w", err)
-		}
+	// 仓库格式按内容分块去重保存，不使用增量和差异方式
+	if format == "repository" {
+		return s.executeRepository(task, record, sourceInfo, method, filter)
 	}
 
-	// 关闭ZIP writer
-	err = zipWriter.Close()
-	if err != nil {
-		zipFile.Close()
-		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
-		return record, fmt.Errorf("failed to close zip writer: %w", err)
+	// 增量和差异备份与基准清单比较，只归档变化的文件
+	// 回退为完整备份时同样生成清单，作为后续备份的基准
+	mode, base, baseManifest := s.selectBase(task, sourceInfo)
+	if sourceInfo.Mode == entity.BackupModeIncremental || sourceInfo.Mode == entity.BackupModeDifferential {
+		filter.manifest = manifest.New(record.ID)
+		filter.base = baseManifest
 	}
 
-	// 关闭文件
-	defer zipFile.Close()
-
-	// 获取ZIP文件信息
-	fileInfo, err := zipFile.Stat()
-	if err != nil {
-		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
-		return record, fmt.Errorf("failed to get file info: %w", err)
+	// 执行备份
+	backupVersion := time.Now().Format("20060102150405")
+	filename := fmt.Sprintf("files_%s.zip", backupVersion)
+	if format == "tar" {
+		filename = fmt.Sprintf("files_%s.tar%s", backupVersion, compression.Extension(method))
 	}
 
-	// 上传到存储
 	storageService, err := storage.NewStorageService("")
 	if err != nil {
 		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
 		return record, fmt.Errorf("failed to create storage service: %w", err)
 	}
 
-	// 重新打开文件用于上传
-	zipFile.Seek(0, 0)
-	filePath, err := storageService.Save(filename, zipFile)
+	// 归档边生成边上传，不写入本地磁盘
+	saved, size, err := streamBackupFile(storageService, filename, func(w io.Writer) error {
+		if format == "tar" {
+			return s.writeTar(w, sourceInfo.Paths, method, sourceInfo.CompressionLevel, filter)
+		}
+		return s.writeZip(w, sourceInfo.Paths, method, sourceInfo.CompressionLevel, filter)
+	})
 	if err != nil {
 		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
 		return record, fmt.Errorf("failed to save backup file: %w", err)
 	}
 
+	// 保存清单，后续的增量和差异备份以及恢复都依赖清单
+	var manifestPath string
+	if filter.manifest != nil {
+		var buf bytes.Buffer
+		err := filter.manifest.Write(&buf)
+		if err == nil {
+			var manifest *savedFile
+			manifest, err = saveBackupFile(storageService, fmt.Sprintf("files_%s.manifest.json.gz", backupVersion), &buf)
+			// 读取清单时按备份记录的密钥ID检查，两者必须一致
+			if err == nil && manifest.keyID != saved.keyID {
+				_ = storageService.Delete(manifest.path)
+				err = fmt.Errorf("encryption key changed during backup")
+			}
+			if err == nil {
+				manifestPath = manifest.path
+			}
+		}
+		if err != nil {
+			_ = storageService.Delete(saved.path)
+			s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+			return record, fmt.Errorf("failed to save manifest: %w", err)
+		}
+	}
+
 	// 更新记录
 	record.Status = entity.StatusSuccess
 	record.EndTime = time.Now()
-	record.FileSize = fileInfo.Size()
-	record.FilePath = filePath
+	record.FileSize = saved.size
+	record.OriginalSize = filter.size
+	record.CompressedSize = size
+	record.Compression = method
+	if format == "zip" && method == compression.Gzip {
+		record.Compression = compression.Deflate
+	}
+	record.FilePath = saved.path
+	record.EncryptionKeyID = saved.keyID
+	record.Checksum = saved.checksum
 	record.BackupVersion = backupVersion
+	record.Summary = filter.summary()
 	record.StorageType = storageService.GetStorageType()
+	record.BackupMode = mode
+	record.ManifestPath = manifestPath
+	if base != nil {
+		record.ParentID = base.ID
+	}
 
 	if err := s.recordRepo.Update(record); err != nil {
 		return record, fmt.Errorf("failed to update backup record: %w", err)
@@ -146,8 +178,108 @@ func (s *FileBackupService) Execute(task *entity.BackupTask) (*entity.BackupReco
 	return record, nil
 }
 
+// selectBase 根据备份模式选择比较基准，返回实际使用的备份模式
+// 增量备份以上一次带清单的成功备份为基准，差异备份以上一次完整备份为基准；
+// 没有可用的完整备份、达到完整备份间隔或基准链不完整时执行完整备份
+func (s *FileBackupService) selectBase(task *entity.BackupTask, sourceInfo *entity.FileSourceInfo) (entity.BackupMode, *entity.BackupRecord, *manifest.Manifest) {
+	mode := sourceInfo.Mode
+	if mode != entity.BackupModeIncremental && mode != entity.BackupModeDifferential {
+		return entity.BackupModeFull, nil, nil
+	}
+
+	full, err := s.recordRepo.FindLatestWithManifest(task.ID, entity.BackupModeFull)
+	if err != nil || full == nil {
+		log.Printf("任务 %d 没有可用的完整备份，执行完整备份", task.ID)
+		return entity.BackupModeFull, nil, nil
+	}
+
+	// 自上次完整备份以来的备份次数达到间隔时执行完整备份
+	if sourceInfo.FullInterval > 0 {
+		count, err := s.recordRepo.CountSuccessSince(task.ID, full.StartTime)
+		if err == nil && count >= int64(sourceInfo.FullInterval) {
+			log.Printf("任务 %d 已达到完整备份间隔，执行完整备份", task.ID)
+			return entity.BackupModeFull, nil, nil
+		}
+	}
+
+	base := full
+	if mode == entity.BackupModeIncremental {
+		base, err = s.recordRepo.FindLatestWithManifest(task.ID, "")
+		if err != nil || base == nil {
+			return entity.BackupModeFull, nil, nil
+		}
+	}
+
+	baseManifest, err := manifest.Load(base)
+	if err != nil {
+		log.Printf("读取备份记录 %d 的清单失败，执行完整备份: %v", base.ID, err)
+		return entity.BackupModeFull, nil, nil
+	}
+
+	// 基准清单引用的备份必须都可用，否则无法恢复
+	for _, id := range baseManifest.RecordIDs() {
+		record, err := s.recordRepo.FindByID(id)
+		if err != nil || record == nil || record.Status != entity.StatusSuccess || record.FilePath == "" {
+			log.Printf("备份记录 %d 引用的备份 %d 不可用，执行完整备份", base.ID, id)
+			return entity.BackupModeFull, nil, nil
+		}
+	}
+
+	return mode, base, baseManifest
+}
+
+// archiveOptions 返回归档格式（zip、tar或repository）和压缩方式
+func (s *FileBackupService) archiveOptions(sourceInfo *entity.FileSourceInfo) (string, string, error) {
+	format := sourceInfo.Format
+	if format == "" {
+		format = "zip"
+	}
+	if format != "zip" && format != "tar" && format != "repository" {
+		return "", "", fmt.Errorf("unsupported archive format: %s", sourceInfo.Format)
+	}
+
+	method := sourceInfo.Compression
+	if method == "" {
+		method = compression.Gzip
+	}
+	if err := compression.Validate(method, sourceInfo.CompressionLevel); err != nil {
+		return "", "", err
+	}
+	// ZIP中的zstd压缩兼容性差，只在tar格式中使用
+	if format == "zip" && method == compression.Zstd {
+		return "", "", fmt.Errorf("zstd compression requires tar format")
+	}
+	return format, method, nil
+}
+
+// writeZip 将源路径写入ZIP归档，gzip对应Deflate压缩
+func (s *FileBackupService) writeZip(w io.Writer, paths []string, method string, level int, filter *fileFilter) error {
+	zipWriter := zip.NewWriter(w)
+
+	zipMethod := zip.Store
+	if method == compression.Gzip {
+		zipMethod = zip.Deflate
+		if level > 0 {
+			zipWriter.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+				return flate.NewWriter(out, level)
+			})
+		}
+	}
+
+	for _, path := range paths {
+		if err := s.addFileToZip(zipWriter, path, "", zipMethod, filter); err != nil {
+			return err
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close zip writer: %w", err)
+	}
+	return nil
+}
+
 // 添加文件到ZIP
-func (s *FileBackupService) addFileToZip(zipWriter *zip.Writer, path, baseInZip string) error {
+func (s *FileBackupService) addFileToZip(zipWriter *zip.Writer, path, baseInZip string, method uint16, filter *fileFilter) error {
 	// 获取文件信息
 	info, err := os.Stat(path)
 	if err != nil {
@@ -162,14 +294,22 @@ func (s *FileBackupService) addFileToZip(zipWriter *zip.Writer, path, baseInZip
 		zipPath = filepath.Join(baseInZip, filepath.Base(path))
 	}
 
+	// 源路径本身不经过过滤
+	if baseInZip != "" && filter.skip(filepath.ToSlash(zipPath), info) {
+		return nil
+	}
+
 	// 处理目录
 	if info.IsDir() {
 		// 为目录创建条目
 		if zipPath != "" {
-			_, err = zipWriter.Create(zipPath + "/")
+			header, err := zipFileHeader(info, zipPath+"/", zip.Store)
 			if err != nil {
 				return err
 			}
+			if _, err := zipWriter.CreateHeader(header); err != nil {
+				return err
+			}
 		}
 
 		// 读取目录内容
@@ -181,7 +321,7 @@ func (s *FileBackupService) addFileToZip(zipWriter *zip.Writer, path, baseInZip
 		// 递归处理子文件和子目录
 		for _, file := range files {
 			filePath := filepath.Join(path, file.Name())
-			err = s.addFileToZip(zipWriter, filePath, zipPath)
+			err = s.addFileToZip(zipWriter, filePath, zipPath, method, filter)
 			if err != nil {
 				return err
 			}
@@ -189,6 +329,12 @@ func (s *FileBackupService) addFileToZip(zipWriter *zip.Writer, path, baseInZip
 		return nil
 	}
 
+	// 增量和差异备份跳过未变化的文件
+	changed, err := filter.changed(path, filepath.ToSlash(zipPath), info)
+	if err != nil || !changed {
+		return err
+	}
+
 	// 处理普通文件
 	fileToZip, err := os.Open(path)
 	if err != nil {
@@ -197,20 +343,146 @@ func (s *FileBackupService) addFileToZip(zipWriter *zip.Writer, path, baseInZip
 	defer fileToZip.Close()
 
 	// 创建ZIP中的文件
-	writer, err := zipWriter.Create(zipPath)
+	header, err := zipFileHeader(info, zipPath, method)
+	if err != nil {
+		return err
+	}
+	writer, err := zipWriter.CreateHeader(header)
 	if err != nil {
 		return err
 	}
 
-	// 写入内容
-	_, err = io.Copy(writer, fileToZip)
+	// 写入内容，同时计算哈希用于清单
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(writer, hash), fileToZip)
 	if err != nil {
 		return err
 	}
+	filter.addFile(filepath.ToSlash(zipPath), info, n, hex.EncodeToString(hash.Sum(nil)))
 
 	return nil
 }
 
+// zipFileHeader 根据文件信息创建ZIP文件头，记录修改时间和权限，恢复时按原样设置
+func zipFileHeader(info os.FileInfo, name string, method uint16) (*zip.FileHeader, error) {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return nil, err
+	}
+	header.Name = filepath.ToSlash(name)
+	header.Method = method
+	return header, nil
+}
+
+// writeTar 将源路径写入tar归档并按指定方式压缩
+func (s *FileBackupService) writeTar(w io.Writer, paths []string, method string, level int, filter *fileFilter) error {
+	compressor, err := compression.NewWriter(w, method, level)
+	if err != nil {
+		return err
+	}
+	tarWriter := tar.NewWriter(compressor)
+
+	for _, path := range paths {
+		// 源路径本身是符号链接时备份其指向的内容
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to get file info: %w", err)
+		}
+		if err := s.addFileToTar(tarWriter, path, info, "", filter); err != nil {
+			return err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	if err := compressor.Close(); err != nil {
+		return fmt.Errorf("failed to close compressor: %w", err)
+	}
+	return nil
+}
+
+// addFileToTar 添加文件到tar，保留权限、属主和修改时间，符号链接作为链接保存
+func (s *FileBackupService) addFileToTar(tarWriter *tar.Writer, path string, info os.FileInfo, baseInTar string, filter *fileFilter) error {
+	// 构建tar中的路径
+	tarPath := filepath.Base(path)
+	if baseInTar != "" {
+		tarPath = baseInTar + "/" + tarPath
+	}
+
+	// 源路径本身不经过过滤
+	if baseInTar != "" && filter.skip(tarPath, info) {
+		return nil
+	}
+
+	// 增量和差异备份跳过未变化的文件，目录和符号链接总是归档
+	if info.Mode().IsRegular() {
+		changed, err := filter.changed(path, tarPath, info)
+		if err != nil || !changed {
+			return err
+		}
+	}
+
+	// 套接字无法归档
+	if info.Mode()&os.ModeSocket != 0 {
+		log.Printf("跳过套接字文件: %s", path)
+		return nil
+	}
+
+	var link string
+	if info.Mode()&os.ModeSymlink != 0 {
+		var err error
+		link, err = os.Readlink(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = tarPath
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	// PAX格式支持长路径和非ASCII文件名
+	header.Format = tar.FormatPAX
+
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+
+	switch {
+	case info.IsDir():
+		// ReadDir返回的文件信息不跟随符号链接
+		files, err := ioutil.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := s.addFileToTar(tarWriter, filepath.Join(path, file.Name()), file, tarPath, filter); err != nil {
+				return err
+			}
+		}
+	case info.Mode().IsRegular():
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		// 按文件头中的大小写入，备份过程中文件变小时报错
+		hash := sha256.New()
+		n, err := io.CopyN(io.MultiWriter(tarWriter, hash), file, header.Size)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		filter.addFile(tarPath, info, n, hex.EncodeToString(hash.Sum(nil)))
+	}
+	return nil
+}
+
 // GetBackupType 获取备份类型
 func (s *FileBackupService) GetBackupType() entity.BackupType {
 	return entity.FileBackup
@@ -218,6 +490,9 @@ func (s *FileBackupService) GetBackupType() entity.BackupType {
 
 // 更新记录状态
 func (s *FileBackupService) updateRecordStatus(record *entity.BackupRecord, status entity.BackupStatus, errorMsg string) {
+	// 错误信息可能包含命令行或连接信息，保存和通知前隐藏其中的敏感信息
+	errorMsg = redact.String(errorMsg)
+
 	record.Status = status
 	record.EndTime = time.Now()
 	record.ErrorMessage = errorMsg
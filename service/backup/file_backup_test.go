@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"archive/zip"
+	"backup-go/entity"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteZipKeepsModTimeAndMode(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "data")
+	if err := os.MkdirAll(filepath.Join(root, "bin"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(root, "bin", "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2023, 6, 1, 12, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(script, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	filter, err := newFileFilter(&entity.FileSourceInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := (&FileBackupService{}).writeZip(&buf, []string{root}, "gzip", 0, filter); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range reader.File {
+		files[f.Name] = f
+	}
+
+	f := files["data/bin/run.sh"]
+	if f == nil {
+		t.Fatalf("missing file entry, got %v", files)
+	}
+	if !f.Modified.Equal(modTime) {
+		t.Errorf("modified = %v, want %v", f.Modified, modTime)
+	}
+	if f.Mode().Perm() != 0700 {
+		t.Errorf("file mode = %v, want 0700", f.Mode().Perm())
+	}
+	if f.Method != zip.Deflate {
+		t.Errorf("method = %d, want deflate", f.Method)
+	}
+
+	dir := files["data/bin/"]
+	if dir == nil {
+		t.Fatalf("missing directory entry, got %v", files)
+	}
+	if !dir.Mode().IsDir() || dir.Mode().Perm() != 0750 {
+		t.Errorf("directory mode = %v, want drwxr-x---", dir.Mode())
+	}
+}
@@ -0,0 +1,281 @@
+package backup
+
+import (
+	"backup-go/entity"
+	"backup-go/service/manifest"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// 摘要中最多列出的跳过条目数
+const maxSummaryEntries = 100
+
+// 跳过条目的原因
+const (
+	skipExcluded    = "excluded"     // 匹配排除规则
+	skipNotIncluded = "not included" // 未匹配任何包含规则
+	skipHidden      = "hidden"       // 隐藏文件或目录
+	skipTooLarge    = "too large"    // 超过大小限制
+)
+
+// fileFilter 按任务配置过滤文件备份的条目，并统计归档和跳过的条目
+// 增量和差异备份时还会与基准清单比较，跳过未变化的文件
+type fileFilter struct {
+	include     []string
+	exclude     []string
+	maxFileSize int64
+	skipHidden  bool
+
+	base     *manifest.Manifest // 比较基准，为nil时归档所有文件
+	manifest *manifest.Manifest // 本次备份的清单，为nil时不生成清单
+
+	files        int            // 已归档的文件数
+	size         int64          // 已归档文件的总大小
+	unchanged    int            // 未变化而不再归档的文件数
+	skipped      map[string]int // 按原因统计的跳过条目数
+	skippedPaths []string       // 跳过的条目，最多保留maxSummaryEntries条
+}
+
+// newFileFilter 根据文件源信息创建过滤器，并检查匹配规则是否有效
+func newFileFilter(sourceInfo *entity.FileSourceInfo) (*fileFilter, error) {
+	filter := &fileFilter{
+		maxFileSize: sourceInfo.MaxFileSize,
+		skipHidden:  sourceInfo.SkipHidden,
+		skipped:     make(map[string]int),
+	}
+
+	for _, pattern := range sourceInfo.Include {
+		pattern, err := normalizePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if pattern != "" {
+			filter.include = append(filter.include, pattern)
+		}
+	}
+	for _, pattern := range sourceInfo.Exclude {
+		pattern, err := normalizePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if pattern != "" {
+			filter.exclude = append(filter.exclude, pattern)
+		}
+	}
+	return filter, nil
+}
+
+// skip 判断条目是否应跳过，archivePath为归档中的路径，跳过的条目会被记录
+// 源路径本身不经过过滤，目录被跳过时其下的所有条目都不再遍历
+func (f *fileFilter) skip(archivePath string, info os.FileInfo) bool {
+	reason := f.skipReason(archivePath, info)
+	if reason == "" {
+		return false
+	}
+
+	f.skipped[reason]++
+	if len(f.skippedPaths) < maxSummaryEntries {
+		name := archivePath
+		if info.IsDir() {
+			name += "/"
+		}
+		f.skippedPaths = append(f.skippedPaths, fmt.Sprintf("%s: %s", reason, name))
+	}
+	return true
+}
+
+// skipReason 返回条目被跳过的原因，不跳过时返回空字符串
+func (f *fileFilter) skipReason(archivePath string, info os.FileInfo) string {
+	if f.skipHidden && strings.HasPrefix(info.Name(), ".") {
+		return skipHidden
+	}
+
+	for _, pattern := range f.exclude {
+		if matchPattern(pattern, archivePath, info.IsDir()) {
+			return skipExcluded
+		}
+	}
+
+	// 目录总是继续遍历，包含规则和大小限制只作用于文件
+	if info.IsDir() {
+		return ""
+	}
+
+	if len(f.include) > 0 {
+		included := false
+		for _, pattern := range f.include {
+			if matchPattern(pattern, archivePath, false) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return skipNotIncluded
+		}
+	}
+
+	if f.maxFileSize > 0 && info.Mode().IsRegular() && info.Size() > f.maxFileSize {
+		return skipTooLarge
+	}
+	return ""
+}
+
+// changed 判断文件自基准备份以来是否变化，未变化的文件沿用基准清单中的记录
+// 大小和修改时间都相同时视为未变化；只有修改时间变化时比较内容哈希
+func (f *fileFilter) changed(filePath, archivePath string, info os.FileInfo) (bool, error) {
+	if f.base == nil {
+		return true, nil
+	}
+
+	previous, ok := f.base.Files[archivePath]
+	if !ok || previous.Size != info.Size() {
+		return true, nil
+	}
+
+	modTime := info.ModTime().UnixNano()
+	if previous.ModTime != modTime {
+		hash, err := hashFile(filePath)
+		if err != nil {
+			return false, err
+		}
+		if hash != previous.Hash {
+			return true, nil
+		}
+	}
+
+	file := *previous
+	file.ModTime = modTime
+	f.manifest.Files[archivePath] = &file
+	f.unchanged++
+	return false, nil
+}
+
+// addFile 统计已归档的文件，并记录到本次备份的清单中
+func (f *fileFilter) addFile(archivePath string, info os.FileInfo, size int64, hash string) {
+	f.files++
+	f.size += size
+
+	if f.manifest != nil {
+		f.manifest.Files[archivePath] = &manifest.File{
+			Size:     size,
+			ModTime:  info.ModTime().UnixNano(),
+			Hash:     hash,
+			RecordID: f.manifest.RecordID,
+		}
+	}
+}
+
+// summary 生成归档和跳过条目的摘要
+func (f *fileFilter) summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "files: %d, size: %d", f.files, f.size)
+	if f.base != nil {
+		fmt.Fprintf(&b, ", unchanged: %d", f.unchanged)
+	}
+
+	total := 0
+	var counts []string
+	for _, reason := range []string{skipExcluded, skipNotIncluded, skipHidden, skipTooLarge} {
+		if n := f.skipped[reason]; n > 0 {
+			total += n
+			counts = append(counts, fmt.Sprintf("%s: %d", reason, n))
+		}
+	}
+	if total == 0 {
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "\nskipped: %d (%s)", total, strings.Join(counts, ", "))
+	for _, p := range f.skippedPaths {
+		b.WriteString("\n" + p)
+	}
+	if total > len(f.skippedPaths) {
+		fmt.Fprintf(&b, "\n... and %d more", total-len(f.skippedPaths))
+	}
+	return b.String()
+}
+
+// hashFile 计算文件内容的SHA-256
+func hashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// normalizePattern 规范化匹配规则并检查语法，空规则返回空字符串
+func normalizePattern(pattern string) (string, error) {
+	pattern = strings.TrimSpace(strings.ReplaceAll(pattern, "\\", "/"))
+	pattern = strings.TrimPrefix(pattern, "./")
+	if pattern == "" || pattern == "/" {
+		return "", nil
+	}
+
+	for _, segment := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return "", fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return pattern, nil
+}
+
+// matchPattern 判断归档中的路径是否匹配规则
+// 规则以"/"结尾时只匹配目录；不含"/"的规则匹配任意层级的文件名，
+// 含"/"的规则匹配完整路径或相对于源路径的路径，"**"匹配任意层级的目录
+func matchPattern(pattern, archivePath string, isDir bool) bool {
+	if strings.HasSuffix(pattern, "/") {
+		if !isDir {
+			return false
+		}
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(archivePath))
+		return ok
+	}
+
+	segments := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	names := strings.Split(archivePath, "/")
+	// 归档路径的第一级为源路径的名称
+	return matchSegments(segments, names) || (len(names) > 1 && matchSegments(segments, names[1:]))
+}
+
+// matchSegments 逐级匹配路径
+func matchSegments(pattern, names []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(names); i++ {
+				if matchSegments(pattern, names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(names) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], names[0]); !ok {
+			return false
+		}
+		pattern, names = pattern[1:], names[1:]
+	}
+	return len(names) == 0
+}
@@ -0,0 +1,82 @@
+package backup
+
+import "testing"
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		archivePath string
+		isDir       bool
+		want        bool
+	}{
+		// 不含"/"的规则匹配任意层级的名称
+		{"*.log", "app/error.log", false, true},
+		{"*.log", "app/logs/2024/error.log", false, true},
+		{"*.log", "app/error.log.gz", false, false},
+		{"node_modules", "app/web/node_modules", true, true},
+		{"?.txt", "app/a.txt", false, true},
+		{"?.txt", "app/ab.txt", false, false},
+		{"[ab].txt", "app/b.txt", false, true},
+
+		// 以"/"结尾的规则只匹配目录
+		{"cache/", "app/cache", true, true},
+		{"cache/", "app/cache", false, false},
+		{"tmp/", "app/data/tmp", true, true},
+
+		// 含"/"的规则匹配完整路径或相对于源路径的路径
+		{"app/config.yaml", "app/config.yaml", false, true},
+		{"config.yaml", "app/sub/config.yaml", false, true},
+		{"data/*.db", "app/data/main.db", false, true},
+		{"data/*.db", "app/data/sub/main.db", false, false},
+		{"data/*.db", "app/other/data/main.db", false, false},
+		{"/data/*.db", "app/data/main.db", false, true},
+		{"*/config.yaml", "app/config.yaml", false, true},
+		{"*/config.yaml", "app/a/b/config.yaml", false, false},
+
+		// "**"匹配任意层级的目录，包括零层
+		{"**/*.tmp", "app/a.tmp", false, true},
+		{"**/*.tmp", "app/a/b/c.tmp", false, true},
+		{"data/**", "app/data/a/b", false, true},
+		{"data/**/*.db", "app/data/main.db", false, true},
+		{"data/**/*.db", "app/data/x/y/main.db", false, true},
+		{"data/**/*.db", "app/logs/main.db", false, false},
+		{"**/build/", "app/a/build", true, true},
+		{"**/build/", "app/a/build", false, false},
+	}
+	for _, tt := range tests {
+		pattern, err := normalizePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("normalizePattern(%q): %v", tt.pattern, err)
+		}
+		if got := matchPattern(pattern, tt.archivePath, tt.isDir); got != tt.want {
+			t.Errorf("matchPattern(%q, %q, %v) = %v, want %v", tt.pattern, tt.archivePath, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{"  *.log ", "*.log", false},
+		{"./data/*.db", "data/*.db", false},
+		{`logs\*.log`, "logs/*.log", false},
+		{"", "", false},
+		{"/", "", false},
+		{"cache/", "cache/", false},
+		{"[a-", "", true},
+		{"data/[z", "", true},
+	}
+	for _, tt := range tests {
+		got, err := normalizePattern(tt.pattern)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizePattern(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizePattern(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
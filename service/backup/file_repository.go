@@ -0,0 +1,256 @@
+package backup
+
+import (
+	"archive/tar"
+	"backup-go/entity"
+	"backup-go/service/chunkstore"
+	"backup-go/service/encryption"
+	"backup-go/service/storage"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotWriter 将源路径按内容分块写入数据块存储，并生成快照
+type snapshotWriter struct {
+	store    *chunkstore.Store
+	snapshot *chunkstore.Snapshot
+	parent   map[string]*chunkstore.Node // 上一次快照中的文件，大小和修改时间未变时直接复用数据块
+	filter   *fileFilter
+	chunker  *chunkstore.Chunker // 所有文件共用一个分块器，避免每个文件分配缓冲区
+	reused   int                 // 直接复用上一次快照的文件数
+}
+
+// executeRepository 以仓库格式执行文件备份，文件内容按内容分块去重保存，记录指向快照
+func (s *FileBackupService) executeRepository(task *entity.BackupTask, record *entity.BackupRecord, sourceInfo *entity.FileSourceInfo, method string, filter *fileFilter) (*entity.BackupRecord, error) {
+	storageService, err := storage.NewStorageService("")
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to create storage service: %w", err)
+	}
+	storageType := storageService.GetStorageType()
+
+	// 数据块与其他备份文件一样按系统配置加密，只复用使用当前密钥保存的数据块
+	encryptor, err := encryption.NewEncryptor()
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to load encryption key: %w", err)
+	}
+	keyID := ""
+	if encryptor != nil {
+		keyID = encryptor.KeyID()
+	}
+	store, err := chunkstore.NewStore(storageType, keyID, method, sourceInfo.CompressionLevel, func(filename string, content io.Reader) (string, string, int64, error) {
+		saved, err := saveBackupFile(storageService, filename, content)
+		if err != nil {
+			return "", "", 0, err
+		}
+		// 备份期间修改了加密配置时，数据块记录的密钥ID与实际不符
+		if saved.keyID != keyID {
+			_ = storageService.Delete(saved.path)
+			return "", "", 0, fmt.Errorf("encryption key changed during backup")
+		}
+		return saved.path, saved.checksum, saved.size, nil
+	})
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, err
+	}
+	defer store.Close()
+
+	writer := &snapshotWriter{
+		store:    store,
+		snapshot: chunkstore.NewSnapshot(record.ID),
+		parent:   s.parentSnapshot(task, storageType),
+		filter:   filter,
+		chunker:  chunkstore.NewChunker(nil),
+	}
+	for _, path := range sourceInfo.Paths {
+		// 源路径本身是符号链接时备份其指向的内容
+		info, err := os.Stat(path)
+		if err == nil {
+			err = writer.add(path, info, "")
+		}
+		if err != nil {
+			s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+			return record, fmt.Errorf("failed to add file to repository: %w", err)
+		}
+	}
+
+	// 保存快照
+	backupVersion := time.Now().Format("20060102150405")
+	var buf bytes.Buffer
+	if err := writer.snapshot.Write(&buf); err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, err
+	}
+	saved, err := saveBackupFile(storageService, "files_"+backupVersion+chunkstore.SnapshotExtension, &buf)
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	// 文件大小为本次实际写入存储的数据量
+	newChunks, reusedChunks, storedSize := store.Stats()
+	record.Status = entity.StatusSuccess
+	record.EndTime = time.Now()
+	record.FileSize = storedSize + saved.size
+	record.OriginalSize = filter.size
+	record.CompressedSize = record.FileSize
+	record.Compression = method
+	record.FilePath = saved.path
+	record.EncryptionKeyID = saved.keyID
+	record.Checksum = saved.checksum
+	record.BackupVersion = backupVersion
+	record.Summary = fmt.Sprintf("chunks: %d new, %d reused, stored: %d, unchanged files: %d\n%s",
+		newChunks, reusedChunks, storedSize, writer.reused, filter.summary())
+	record.StorageType = storageType
+
+	if err := s.recordRepo.Update(record); err != nil {
+		return record, fmt.Errorf("failed to update backup record: %w", err)
+	}
+
+	// 发送备份成功通知，忽略错误
+	_ = s.webhookService.SendBackupSuccessNotification(
+		task.Name,
+		record.FileSize,
+		record.FilePath,
+		record.EndTime.Sub(record.StartTime),
+	)
+
+	return record, nil
+}
+
+// parentSnapshot 读取任务上一次成功备份的快照，用于跳过未变化的文件，没有可用快照时返回nil
+func (s *FileBackupService) parentSnapshot(task *entity.BackupTask, storageType entity.StorageType) map[string]*chunkstore.Node {
+	parent, err := s.recordRepo.FindLatestSuccessByTaskID(task.ID)
+	if err != nil || parent == nil || !chunkstore.IsSnapshot(parent.FilePath) || parent.StorageType != storageType {
+		return nil
+	}
+
+	snapshot, err := chunkstore.LoadSnapshot(parent)
+	if err != nil {
+		log.Printf("读取备份记录 %d 的快照失败，将重新读取所有文件: %v", parent.ID, err)
+		return nil
+	}
+
+	files := make(map[string]*chunkstore.Node)
+	for _, node := range snapshot.Nodes {
+		if node.Type == chunkstore.NodeFile {
+			files[node.Path] = node
+		}
+	}
+	return files
+}
+
+// add 将条目及其下的所有条目加入快照
+func (w *snapshotWriter) add(path string, info os.FileInfo, baseInSnapshot string) error {
+	// 构建快照中的路径
+	nodePath := filepath.Base(path)
+	if baseInSnapshot != "" {
+		nodePath = baseInSnapshot + "/" + nodePath
+	}
+
+	// 源路径本身不经过过滤
+	if baseInSnapshot != "" && w.filter.skip(nodePath, info) {
+		return nil
+	}
+
+	// 只保存普通文件、目录和符号链接
+	mode := info.Mode()
+	if !mode.IsRegular() && !mode.IsDir() && mode&os.ModeSymlink == 0 {
+		log.Printf("跳过特殊文件: %s", path)
+		return nil
+	}
+
+	var link string
+	if mode&os.ModeSymlink != 0 {
+		var err error
+		link, err = os.Readlink(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 借助tar文件头获取跨平台的属主信息
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	node := &chunkstore.Node{
+		Path:     nodePath,
+		Mode:     mode.Perm(),
+		ModTime:  info.ModTime(),
+		Linkname: link,
+		Uid:      header.Uid,
+		Gid:      header.Gid,
+		Uname:    header.Uname,
+		Gname:    header.Gname,
+	}
+	w.snapshot.Nodes = append(w.snapshot.Nodes, node)
+
+	switch {
+	case mode.IsDir():
+		node.Type = chunkstore.NodeDir
+
+		// ReadDir返回的文件信息不跟随符号链接
+		files, err := ioutil.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := w.add(filepath.Join(path, file.Name()), file, nodePath); err != nil {
+				return err
+			}
+		}
+	case link != "":
+		node.Type = chunkstore.NodeSymlink
+	default:
+		node.Type = chunkstore.NodeFile
+		return w.addFile(path, info, node)
+	}
+	return nil
+}
+
+// addFile 将文件内容分块保存，大小和修改时间与上一次快照相同时直接复用数据块
+func (w *snapshotWriter) addFile(path string, info os.FileInfo, node *chunkstore.Node) error {
+	if previous, ok := w.parent[node.Path]; ok && previous.Size == info.Size() && previous.ModTime.Equal(info.ModTime()) {
+		node.Size = previous.Size
+		node.Chunks = previous.Chunks
+		w.reused++
+		w.filter.addFile(node.Path, info, node.Size, "")
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w.chunker.Reset(file)
+	for {
+		data, err := w.chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		chunkHash, err := w.store.Put(data)
+		if err != nil {
+			return err
+		}
+		node.Chunks = append(node.Chunks, chunkHash)
+		node.Size += int64(len(data))
+	}
+
+	w.filter.addFile(node.Path, info, node.Size, "")
+	return nil
+}
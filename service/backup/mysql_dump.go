@@ -0,0 +1,140 @@
+package backup
+
+import (
+	"backup-go/entity"
+	"backup-go/service/dbclient"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// validateMySQLOptions 检查mysqldump导出选项
+func validateMySQLOptions(sourceInfo *entity.DatabaseSourceInfo) error {
+	options := sourceInfo.MySQL
+	if options == nil {
+		return nil
+	}
+
+	switch options.Flavor {
+	case "", dbclient.FlavorMySQL, dbclient.FlavorMariaDB:
+	default:
+		return fmt.Errorf("unsupported mysql flavor: %s", options.Flavor)
+	}
+
+	switch strings.ToUpper(options.SSLMode) {
+	case "", "DISABLED", "PREFERRED", "REQUIRED", "VERIFY_CA", "VERIFY_IDENTITY":
+	default:
+		return fmt.Errorf("unsupported ssl mode: %s", options.SSLMode)
+	}
+
+	allDatabases := sourceInfo.Database == "" || sourceInfo.Database == "all"
+	if allDatabases && len(options.Tables) > 0 {
+		return fmt.Errorf("tables can only be specified when backing up a single database")
+	}
+	for _, table := range options.ExcludeTables {
+		if allDatabases && !strings.Contains(table, ".") {
+			return fmt.Errorf("excluded table %q must be in database.table form when backing up all databases", table)
+		}
+	}
+	return nil
+}
+
+// mysqlDumpArgs 根据导出选项生成mysqldump参数
+func mysqlDumpArgs(sourceInfo *entity.DatabaseSourceInfo, dumper *dbclient.MySQLTool) []string {
+	options := sourceInfo.MySQL
+	if options == nil {
+		options = &entity.MySQLOptions{}
+	}
+
+	args := dbclient.MySQLSSLArgs(options, dumper)
+
+	if options.BinlogPosition {
+		// 以注释形式写入binlog位置，导入时不会执行；MySQL 8.0.26起--master-data改名为--source-data
+		if dumper.Flavor == dbclient.FlavorMySQL && dbclient.VersionAtLeast(dumper.Version, 8, 0, 26) {
+			args = append(args, "--source-data=2")
+		} else {
+			args = append(args, "--master-data=2")
+		}
+	}
+	if options.SingleTransaction {
+		args = append(args, "--single-transaction")
+	}
+	if options.Routines {
+		args = append(args, "--routines")
+	}
+	if options.SkipTriggers {
+		args = append(args, "--skip-triggers")
+	}
+	if options.Events {
+		args = append(args, "--events")
+	}
+	if options.Where != "" {
+		args = append(args, "--where="+options.Where)
+	}
+	for _, table := range options.ExcludeTables {
+		table = strings.TrimSpace(table)
+		if table == "" {
+			continue
+		}
+		// --ignore-table需要库名
+		if !strings.Contains(table, ".") {
+			table = sourceInfo.Database + "." + table
+		}
+		args = append(args, "--ignore-table="+table)
+	}
+
+	// 判断是否为全库备份（备份所有数据库）
+	if sourceInfo.Database == "" || sourceInfo.Database == "all" {
+		// 备份所有数据库
+		args = append(args, "--all-databases")
+	} else if len(options.Tables) > 0 {
+		// 只备份指定的表，此时不能使用--databases
+		args = append(args, sourceInfo.Database)
+		for _, table := range options.Tables {
+			if table = strings.TrimSpace(table); table != "" {
+				args = append(args, table)
+			}
+		}
+	} else {
+		// 备份指定的数据库
+		args = append(args, "--databases", sourceInfo.Database)
+	}
+	return args
+}
+
+// mysqldump记录的binlog位置，如：
+// -- CHANGE REPLICATION SOURCE TO SOURCE_LOG_FILE='binlog.000012', SOURCE_LOG_POS=157;
+// -- CHANGE MASTER TO MASTER_LOG_FILE='mysql-bin.000003', MASTER_LOG_POS=4;
+var binlogPositionPattern = regexp.MustCompile(`(?:MASTER|SOURCE)_LOG_FILE='([^']+)',\s*(?:MASTER|SOURCE)_LOG_POS=(\d+);`)
+
+// 只在导出数据开头的这部分内容中查找binlog位置
+const binlogPositionScanLimit = 1024 * 1024
+
+// binlogPositionWriter 转发导出数据，同时从开头部分解析binlog位置
+type binlogPositionWriter struct {
+	writer   io.Writer
+	head     []byte
+	done     bool
+	file     string // binlog文件名，未找到时为空
+	position int64  // binlog位置
+}
+
+// Write 写入导出数据
+func (w *binlogPositionWriter) Write(p []byte) (int, error) {
+	if !w.done {
+		w.head = append(w.head, p...)
+		if match := binlogPositionPattern.FindSubmatch(w.head); match != nil {
+			w.file = string(match[1])
+			w.position, _ = strconv.ParseInt(string(match[2]), 10, 64)
+			w.done = true
+		} else if len(w.head) >= binlogPositionScanLimit {
+			w.done = true
+		}
+		if w.done {
+			w.head = nil
+		}
+	}
+	return w.writer.Write(p)
+}
@@ -0,0 +1,284 @@
+package backup
+
+import (
+	"backup-go/entity"
+	"backup-go/repository"
+	"backup-go/service/compression"
+	"backup-go/service/config"
+	"backup-go/service/redact"
+	"backup-go/service/storage"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// bgsave等待超时时间
+const redisBgsaveTimeout = 30 * time.Minute
+
+// 查询bgsave是否完成的间隔
+var redisBgsavePollInterval = time.Second
+
+// RedisBackupService Redis快照备份服务
+type RedisBackupService struct {
+	taskRepo       *repository.BackupTaskRepository
+	recordRepo     *repository.BackupRecordRepository
+	storageType    entity.StorageType
+	webhookService *config.WebhookService
+}
+
+// NewRedisBackupService 创建Redis备份服务
+func NewRedisBackupService() *RedisBackupService {
+	return &RedisBackupService{
+		taskRepo:       repository.NewBackupTaskRepository(),
+		recordRepo:     repository.NewBackupRecordRepository(),
+		storageType:    entity.LocalStorage, // 默认使用本地存储
+		webhookService: config.NewWebhookService(),
+	}
+}
+
+// Execute 执行备份
+func (s *RedisBackupService) Execute(task *entity.BackupTask) (*entity.BackupRecord, error) {
+	// 解析源信息
+	sourceInfo, err := s.taskRepo.ParseRedisSourceInfo(task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse redis source info: %w", err)
+	}
+	if sourceInfo.Port == 0 {
+		sourceInfo.Port = 6379
+	}
+
+	// 创建备份记录
+	record := &entity.BackupRecord{
+		TaskID:    task.ID,
+		Status:    entity.StatusRunning,
+		StartTime: time.Now(),
+	}
+	err = s.recordRepo.Create(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup record: %w", err)
+	}
+
+	// 执行备份
+	backupVersion := time.Now().Format("20060102150405")
+
+	// 为任务名称去除特殊字符，避免不合法的文件名
+	safeName := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, task.Name)
+	filename := fmt.Sprintf("task_%d_%s_redis_%s.rdb", task.ID, safeName, backupVersion)
+
+	// 创建临时目录
+	tempDir, err := ioutil.TempDir("", "redis_backup")
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	tempFilePath := filepath.Join(tempDir, filename)
+
+	// 生成RDB快照
+	switch sourceInfo.Mode {
+	case "", "sync":
+		err = s.snapshotBySync(sourceInfo, tempFilePath)
+	case "bgsave":
+		err = s.snapshotByBgsave(sourceInfo, tempFilePath)
+	default:
+		err = fmt.Errorf("unsupported redis snapshot mode: %s", sourceInfo.Mode)
+	}
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("redis snapshot failed: %w", err)
+	}
+
+	// 获取文件大小
+	fileInfo, err := os.Stat(tempFilePath)
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	// 读取备份文件
+	backupData, err := os.Open(tempFilePath)
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to read backup file: %w", err)
+	}
+	defer backupData.Close()
+
+	// 上传到存储
+	storageService, err := storage.NewStorageService("")
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to create storage service: %w", err)
+	}
+
+	saved, err := saveBackupFile(storageService, filename, backupData)
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to save backup file: %w", err)
+	}
+
+	// 更新记录
+	record.Status = entity.StatusSuccess
+	record.EndTime = time.Now()
+	record.FileSize = saved.size
+	record.OriginalSize = fileInfo.Size()
+	record.CompressedSize = fileInfo.Size()
+	record.Compression = compression.None
+	record.FilePath = saved.path
+	record.EncryptionKeyID = saved.keyID
+	record.Checksum = saved.checksum
+	record.BackupVersion = backupVersion
+	record.StorageType = storageService.GetStorageType()
+
+	if err := s.recordRepo.Update(record); err != nil {
+		return record, fmt.Errorf("failed to update backup record: %w", err)
+	}
+
+	// 发送备份成功通知
+	task, tErr := s.taskRepo.FindByID(record.TaskID)
+	if tErr == nil && task != nil {
+		duration := record.EndTime.Sub(record.StartTime)
+		// 尝试发送通知，忽略错误
+		_ = s.webhookService.SendBackupSuccessNotification(
+			task.Name,
+			record.FileSize,
+			record.FilePath,
+			duration,
+		)
+	}
+
+	return record, nil
+}
+
+// snapshotBySync 通过复制协议获取RDB，适用于无法访问Redis服务器磁盘的场景
+func (s *RedisBackupService) snapshotBySync(sourceInfo *entity.RedisSourceInfo, tempFilePath string) error {
+	client, err := dialRedis(sourceInfo.Host, sourceInfo.Port, sourceInfo.Username, sourceInfo.Password)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	file, err := os.Create(tempFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to create rdb file: %w", err)
+	}
+	defer file.Close()
+
+	log.Printf("通过SYNC获取Redis快照: %s:%d", sourceInfo.Host, sourceInfo.Port)
+	size, err := client.syncRDB(file)
+	if err != nil {
+		return fmt.Errorf("failed to receive rdb: %w", err)
+	}
+	log.Printf("已接收Redis快照，大小: %d 字节", size)
+
+	return nil
+}
+
+// snapshotByBgsave 触发BGSAVE并在完成后复制RDB文件，要求RDB文件在本机可访问
+func (s *RedisBackupService) snapshotByBgsave(sourceInfo *entity.RedisSourceInfo, tempFilePath string) error {
+	client, err := dialRedis(sourceInfo.Host, sourceInfo.Port, sourceInfo.Username, sourceInfo.Password)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// 确定RDB文件路径
+	rdbPath := sourceInfo.RDBPath
+	if rdbPath == "" {
+		dir, err := client.configGet("dir")
+		if err != nil {
+			return fmt.Errorf("failed to get redis dir: %w", err)
+		}
+		dbFilename, err := client.configGet("dbfilename")
+		if err != nil {
+			return fmt.Errorf("failed to get redis dbfilename: %w", err)
+		}
+		rdbPath = filepath.Join(dir, dbFilename)
+	}
+
+	log.Printf("触发Redis BGSAVE: %s:%d", sourceInfo.Host, sourceInfo.Port)
+	if _, err := client.do("BGSAVE"); err != nil {
+		// 已有保存任务在执行时，等待其完成即可
+		if !strings.Contains(err.Error(), "in progress") {
+			return fmt.Errorf("BGSAVE failed: %w", err)
+		}
+	}
+
+	// 等待保存完成，保存失败时（如磁盘已满）RDB文件仍是旧的，不能当作本次备份
+	deadline := time.Now().Add(redisBgsaveTimeout)
+	for {
+		time.Sleep(redisBgsavePollInterval)
+		persistence, err := client.info("persistence")
+		if err != nil {
+			return fmt.Errorf("failed to get redis persistence info: %w", err)
+		}
+		if persistence["rdb_bgsave_in_progress"] == "0" {
+			if status := persistence["rdb_last_bgsave_status"]; status != "ok" {
+				return fmt.Errorf("BGSAVE failed: rdb_last_bgsave_status is %q", status)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for BGSAVE to finish")
+		}
+	}
+
+	// 复制RDB文件
+	src, err := os.Open(rdbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open rdb file: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.Create(tempFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to create rdb file: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return fmt.Errorf("failed to copy rdb file: %w", err)
+	}
+
+	return nil
+}
+
+// GetBackupType 获取备份类型
+func (s *RedisBackupService) GetBackupType() entity.BackupType {
+	return entity.RedisBackup
+}
+
+// 更新记录状态
+func (s *RedisBackupService) updateRecordStatus(record *entity.BackupRecord, status entity.BackupStatus, errorMsg string) {
+	// 错误信息可能包含命令行或连接信息，保存和通知前隐藏其中的敏感信息
+	errorMsg = redact.String(errorMsg)
+
+	record.Status = status
+	record.EndTime = time.Now()
+	record.ErrorMessage = errorMsg
+
+	_ = s.recordRepo.Update(record)
+
+	// 如果是失败状态，发送Webhook通知
+	if status == entity.StatusFailed {
+		task, err := s.taskRepo.FindByID(record.TaskID)
+		if err == nil && task != nil {
+			// 尝试发送通知，忽略错误
+			_ = s.webhookService.SendBackupFailureNotification(
+				task.Name,
+				errorMsg,
+			)
+		}
+	}
+}
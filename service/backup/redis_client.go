@@ -0,0 +1,288 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Redis读写超时：普通命令为发送到收到完整回复的最长时间，SYNC为两次收到数据之间的最长间隔
+const redisIOTimeout = 60 * time.Second
+
+// redisClient 精简的Redis客户端，只实现备份所需的RESP协议子集
+type redisClient struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration // 读写超时，服务器无响应时返回错误而不是一直阻塞
+}
+
+// redisError Redis服务端返回的错误
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// dialRedis 连接Redis并完成认证
+func dialRedis(host string, port int, username, password string) (*redisClient, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis %s: %w", addr, err)
+	}
+
+	client := &redisClient{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: redisIOTimeout,
+	}
+
+	// 认证
+	if password != "" {
+		args := []string{"AUTH", password}
+		if username != "" {
+			args = []string{"AUTH", username, password}
+		}
+		if _, err := client.do(args...); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("redis auth failed: %w", err)
+		}
+	}
+
+	return client, nil
+}
+
+// Close 关闭连接
+func (c *redisClient) Close() error {
+	return c.conn.Close()
+}
+
+// do 发送命令并读取一个回复
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	if err := c.extendDeadline(); err != nil {
+		return nil, err
+	}
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// extendDeadline 从当前时间起重新计算读写超时
+func (c *redisClient) extendDeadline() error {
+	return c.conn.SetDeadline(time.Now().Add(c.timeout))
+}
+
+// send 以RESP数组格式发送命令
+func (c *redisClient) send(args ...string) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := c.conn.Write(buf.Bytes())
+	return err
+}
+
+// readLine 读取一行并去掉行尾的\r\n
+func (c *redisClient) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readReply 读取一个RESP回复
+func (c *redisClient) readReply() (interface{}, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, fmt.Errorf("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk length: %s", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length: %s", line)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected redis reply: %s", line)
+	}
+}
+
+// info 获取INFO命令指定部分的字段
+func (c *redisClient) info(section string) (map[string]string, error) {
+	reply, err := c.do("INFO", section)
+	if err != nil {
+		return nil, err
+	}
+	text, ok := reply.(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected INFO reply: %v", reply)
+	}
+
+	// 每行为key:value，#开头的行为分组标题
+	fields := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			fields[key] = value
+		}
+	}
+	return fields, nil
+}
+
+// configGet 获取单个配置项的值
+func (c *redisClient) configGet(key string) (string, error) {
+	reply, err := c.do("CONFIG", "GET", key)
+	if err != nil {
+		return "", err
+	}
+	items, ok := reply.([]interface{})
+	if !ok || len(items) != 2 {
+		return "", fmt.Errorf("unexpected CONFIG GET reply: %v", reply)
+	}
+	value, _ := items[1].(string)
+	return value, nil
+}
+
+// syncRDB 通过复制协议的SYNC命令获取RDB快照并写入w
+// 传输期间每收到数据都会延长超时，RDB较大时只要数据持续到达就不会超时
+func (c *redisClient) syncRDB(w io.Writer) (int64, error) {
+	if err := c.extendDeadline(); err != nil {
+		return 0, err
+	}
+	if err := c.send("SYNC"); err != nil {
+		return 0, err
+	}
+
+	// 主节点在生成RDB期间会发送换行符作为心跳，需要跳过
+	var header string
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read SYNC reply: %w", err)
+		}
+		if err := c.extendDeadline(); err != nil {
+			return 0, err
+		}
+		if line == "" {
+			continue
+		}
+		header = line
+		break
+	}
+
+	w = &deadlineWriter{writer: w, client: c}
+
+	switch {
+	case strings.HasPrefix(header, "-"):
+		return 0, redisError(header[1:])
+	case strings.HasPrefix(header, "$EOF:"):
+		// 无盘复制：数据以40字节的随机标记结尾
+		mark := []byte(header[5:])
+		if len(mark) != 40 {
+			return 0, fmt.Errorf("invalid diskless sync mark: %s", header)
+		}
+		return copyUntilMark(w, c.reader, mark)
+	case strings.HasPrefix(header, "$"):
+		size, err := strconv.ParseInt(header[1:], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid SYNC payload length: %s", header)
+		}
+		return io.CopyN(w, c.reader, size)
+	default:
+		return 0, fmt.Errorf("unexpected SYNC reply: %s", header)
+	}
+}
+
+// deadlineWriter 每次写入收到的数据后延长连接的超时
+type deadlineWriter struct {
+	writer io.Writer
+	client *redisClient
+}
+
+// Write 写入数据并延长超时
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	n, err := d.writer.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, d.client.extendDeadline()
+}
+
+// copyUntilMark 将r中的数据写入w，直到遇到结束标记（标记本身不写入）
+func copyUntilMark(w io.Writer, r *bufio.Reader, mark []byte) (int64, error) {
+	var written int64
+	buf := make([]byte, 0, 64*1024+len(mark))
+	chunk := make([]byte, 64*1024)
+	for {
+		n, err := r.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+
+		if bytes.HasSuffix(buf, mark) {
+			data := buf[:len(buf)-len(mark)]
+			m, werr := w.Write(data)
+			return written + int64(m), werr
+		}
+
+		// 保留末尾可能是标记一部分的数据
+		if len(buf) > len(mark) {
+			flush := buf[:len(buf)-len(mark)]
+			m, werr := w.Write(flush)
+			written += int64(m)
+			if werr != nil {
+				return written, werr
+			}
+			buf = append(buf[:0], buf[len(flush):]...)
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				return written, fmt.Errorf("connection closed before end of RDB payload")
+			}
+			return written, err
+		}
+	}
+}
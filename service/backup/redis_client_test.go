@@ -0,0 +1,189 @@
+package backup
+
+import (
+	"backup-go/entity"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRedis 启动一个按handler回复命令的Redis服务端，handler返回原始RESP回复，返回空字符串时不回复
+func fakeRedis(t *testing.T, handler func(args []string) string) (string, int) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					args, err := readCommand(reader)
+					if err != nil {
+						return
+					}
+					if reply := handler(args); reply != "" {
+						io.WriteString(conn, reply)
+					}
+				}
+			}()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+// readCommand 读取一个RESP数组格式的命令
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimRight(arg, "\r\n")
+	}
+	return args, nil
+}
+
+// bulk 返回RESP批量字符串
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func TestRedisClientTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(args []string) string
+		run     func(c *redisClient) error
+	}{
+		{
+			name:    "command without reply",
+			handler: func(args []string) string { return "" },
+			run: func(c *redisClient) error {
+				_, err := c.do("PING")
+				return err
+			},
+		},
+		{
+			name: "sync stalls during transfer",
+			handler: func(args []string) string {
+				return "\n$100\r\n0123456789"
+			},
+			run: func(c *redisClient) error {
+				_, err := c.syncRDB(io.Discard)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port := fakeRedis(t, tt.handler)
+			client, err := dialRedis(host, port, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			client.timeout = 100 * time.Millisecond
+
+			done := make(chan error, 1)
+			go func() { done <- tt.run(client) }()
+			select {
+			case err := <-done:
+				var netErr net.Error
+				if !errors.As(err, &netErr) || !netErr.Timeout() {
+					t.Fatalf("error = %v, want timeout", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("client did not time out")
+			}
+		})
+	}
+}
+
+func TestSnapshotByBgsave(t *testing.T) {
+	interval := redisBgsavePollInterval
+	redisBgsavePollInterval = 10 * time.Millisecond
+	defer func() { redisBgsavePollInterval = interval }()
+
+	tests := []struct {
+		name    string
+		status  string
+		wantErr bool
+	}{
+		{"save succeeded", "ok", false},
+		{"save failed", "err", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			polls := 0
+			host, port := fakeRedis(t, func(args []string) string {
+				switch strings.ToUpper(args[0]) {
+				case "BGSAVE":
+					return "+Background saving started\r\n"
+				case "INFO":
+					// 第一次查询时仍在保存
+					polls++
+					inProgress := "0"
+					if polls == 1 {
+						inProgress = "1"
+					}
+					return bulk("# Persistence\r\nrdb_bgsave_in_progress:" + inProgress + "\r\nrdb_last_bgsave_status:" + tt.status + "\r\n")
+				default:
+					return "-ERR unknown command\r\n"
+				}
+			})
+
+			dir := t.TempDir()
+			rdbPath := filepath.Join(dir, "dump.rdb")
+			if err := os.WriteFile(rdbPath, []byte("REDIS0011"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			sourceInfo := &entity.RedisSourceInfo{Host: host, Port: port, RDBPath: rdbPath}
+			tempFilePath := filepath.Join(dir, "backup.rdb")
+
+			err := (&RedisBackupService{}).snapshotByBgsave(sourceInfo, tempFilePath)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "rdb_last_bgsave_status") {
+					t.Fatalf("error = %v, want bgsave status error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(tempFilePath)
+			if err != nil || string(data) != "REDIS0011" {
+				t.Fatalf("copied rdb = %q, %v", data, err)
+			}
+		})
+	}
+}
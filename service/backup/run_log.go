@@ -0,0 +1,139 @@
+package backup
+
+import (
+	"backup-go/service/redact"
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 运行日志保存的最大长度，超过时丢弃最早的内容
+const maxRunLogSize = 256 * 1024
+
+// 失败通知中附带的运行日志行数
+const notifyLogLines = 20
+
+// runLog 单次备份的运行日志，记录执行的命令、命令的输出和各步骤的时间
+type runLog struct {
+	mutex     sync.Mutex
+	buf       []byte
+	truncated bool
+}
+
+// newRunLog 创建运行日志
+func newRunLog() *runLog {
+	return &runLog{}
+}
+
+// Printf 追加一行带时间戳的日志
+func (l *runLog) Printf(format string, args ...interface{}) {
+	l.addLine("", fmt.Sprintf(format, args...))
+}
+
+// addLine 追加一行日志，source不为空时标明输出来源，如stderr
+func (l *runLog) addLine(source, line string) {
+	prefix := time.Now().Format("2006-01-02 15:04:05") + " "
+	if source != "" {
+		prefix += "[" + source + "] "
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.buf = append(l.buf, prefix...)
+	l.buf = append(l.buf, strings.TrimRight(line, "\r\n")...)
+	l.buf = append(l.buf, '\n')
+	if len(l.buf) > maxRunLogSize {
+		// 一次多丢弃一些，避免之后每写一行都要移动整个缓冲区；从完整的行开始保留
+		cut := len(l.buf) - maxRunLogSize*3/4
+		if i := bytes.IndexByte(l.buf[cut:], '\n'); i >= 0 {
+			cut += i + 1
+		}
+		l.buf = append([]byte(nil), l.buf[cut:]...)
+		l.truncated = true
+	}
+}
+
+// String 返回日志内容，其中的敏感信息已隐藏
+func (l *runLog) String() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	content := string(l.buf)
+	if l.truncated {
+		content = "...（较早的日志已丢弃）\n" + content
+	}
+	return redact.String(content)
+}
+
+// Tail 返回最后n行日志
+func (l *runLog) Tail(n int) string {
+	lines := strings.Split(strings.TrimRight(l.String(), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Writer 返回按行写入日志的io.Writer，用于记录命令输出
+func (l *runLog) Writer(source string) *runLogWriter {
+	return &runLogWriter{log: l, source: source}
+}
+
+// 命令失败时错误信息中附带的输出行数
+const errorOutputLines = 5
+
+// runLogWriter 将命令输出按行写入运行日志，并保留最后几行用于错误信息
+type runLogWriter struct {
+	log     *runLog
+	source  string
+	partial []byte   // 尚未遇到换行符的内容
+	last    []string // 最后几行输出
+}
+
+// Write 写入命令输出
+func (w *runLogWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(string(w.partial[:i]))
+		w.partial = w.partial[i+1:]
+	}
+	// 没有换行符的超长输出直接作为一行写入
+	if len(w.partial) > 64*1024 {
+		w.writeLine(string(w.partial))
+		w.partial = nil
+	}
+	return len(p), nil
+}
+
+// Flush 写入最后一行不以换行符结尾的输出，命令结束后调用
+func (w *runLogWriter) Flush() {
+	if len(w.partial) > 0 {
+		w.writeLine(string(w.partial))
+		w.partial = nil
+	}
+}
+
+// writeLine 写入一行输出，忽略空行
+func (w *runLogWriter) writeLine(line string) {
+	line = strings.TrimRight(line, "\r")
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	w.log.addLine(w.source, line)
+	w.last = append(w.last, line)
+	if len(w.last) > errorOutputLines {
+		w.last = w.last[1:]
+	}
+}
+
+// Last 返回最后几行输出
+func (w *runLogWriter) Last() string {
+	return strings.Join(w.last, "\n")
+}
@@ -0,0 +1,210 @@
+package backup
+
+import (
+	"backup-go/entity"
+	"backup-go/repository"
+	"backup-go/service/compression"
+	"backup-go/service/config"
+	"backup-go/service/redact"
+	"backup-go/service/storage"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// SQLiteBackupService SQLite在线备份服务
+type SQLiteBackupService struct {
+	taskRepo       *repository.BackupTaskRepository
+	recordRepo     *repository.BackupRecordRepository
+	storageType    entity.StorageType
+	webhookService *config.WebhookService
+}
+
+// NewSQLiteBackupService 创建SQLite备份服务
+func NewSQLiteBackupService() *SQLiteBackupService {
+	return &SQLiteBackupService{
+		taskRepo:       repository.NewBackupTaskRepository(),
+		recordRepo:     repository.NewBackupRecordRepository(),
+		storageType:    entity.LocalStorage, // 默认使用本地存储
+		webhookService: config.NewWebhookService(),
+	}
+}
+
+// Execute 执行备份
+func (s *SQLiteBackupService) Execute(task *entity.BackupTask) (*entity.BackupRecord, error) {
+	// 解析源信息
+	sourceInfo, err := s.taskRepo.ParseSQLiteSourceInfo(task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sqlite source info: %w", err)
+	}
+
+	// 创建备份记录
+	record := &entity.BackupRecord{
+		TaskID:    task.ID,
+		Status:    entity.StatusRunning,
+		StartTime: time.Now(),
+	}
+	err = s.recordRepo.Create(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup record: %w", err)
+	}
+
+	// 执行备份
+	backupVersion := time.Now().Format("20060102150405")
+
+	// 为任务名称去除特殊字符，避免不合法的文件名
+	safeName := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, task.Name)
+	filename := fmt.Sprintf("task_%d_%s_sqlite_%s.db", task.ID, safeName, backupVersion)
+
+	// 创建临时目录
+	tempDir, err := ioutil.TempDir("", "sqlite_backup")
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	tempFilePath := filepath.Join(tempDir, filename)
+
+	// 生成一致性快照
+	if err := s.snapshot(sourceInfo.Path, tempFilePath); err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("sqlite snapshot failed: %w", err)
+	}
+
+	// 获取文件大小
+	fileInfo, err := os.Stat(tempFilePath)
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	// 读取备份文件
+	backupData, err := os.Open(tempFilePath)
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to read backup file: %w", err)
+	}
+	defer backupData.Close()
+
+	// 上传到存储
+	storageService, err := storage.NewStorageService("")
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to create storage service: %w", err)
+	}
+
+	saved, err := saveBackupFile(storageService, filename, backupData)
+	if err != nil {
+		s.updateRecordStatus(record, entity.StatusFailed, err.Error())
+		return record, fmt.Errorf("failed to save backup file: %w", err)
+	}
+
+	// 更新记录
+	record.Status = entity.StatusSuccess
+	record.EndTime = time.Now()
+	record.FileSize = saved.size
+	record.OriginalSize = fileInfo.Size()
+	record.CompressedSize = fileInfo.Size()
+	record.Compression = compression.None
+	record.FilePath = saved.path
+	record.EncryptionKeyID = saved.keyID
+	record.Checksum = saved.checksum
+	record.BackupVersion = backupVersion
+	record.StorageType = storageService.GetStorageType()
+
+	if err := s.recordRepo.Update(record); err != nil {
+		return record, fmt.Errorf("failed to update backup record: %w", err)
+	}
+
+	// 发送备份成功通知
+	task, tErr := s.taskRepo.FindByID(record.TaskID)
+	if tErr == nil && task != nil {
+		duration := record.EndTime.Sub(record.StartTime)
+		// 尝试发送通知，忽略错误
+		_ = s.webhookService.SendBackupSuccessNotification(
+			task.Name,
+			record.FileSize,
+			record.FilePath,
+			duration,
+		)
+	}
+
+	return record, nil
+}
+
+// snapshot 使用VACUUM INTO生成数据库的一致性副本，备份期间其他进程仍可正常读写
+func (s *SQLiteBackupService) snapshot(dbPath, tempFilePath string) error {
+	// 先检查文件是否存在，避免打开时自动创建空数据库
+	info, err := os.Stat(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat database file: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("database path is a directory: %s", dbPath)
+	}
+
+	// 设置忙等待超时，避免与写入进程冲突时立即失败
+	dsn := dbPath + "?_pragma=busy_timeout(10000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database handle: %w", err)
+	}
+	defer sqlDB.Close()
+
+	log.Printf("生成SQLite快照: %s", dbPath)
+	if err := db.Exec("VACUUM INTO ?", tempFilePath).Error; err != nil {
+		return fmt.Errorf("VACUUM INTO failed: %w", err)
+	}
+
+	return nil
+}
+
+// GetBackupType 获取备份类型
+func (s *SQLiteBackupService) GetBackupType() entity.BackupType {
+	return entity.SQLiteBackup
+}
+
+// 更新记录状态
+func (s *SQLiteBackupService) updateRecordStatus(record *entity.BackupRecord, status entity.BackupStatus, errorMsg string) {
+	// 错误信息可能包含命令行或连接信息，保存和通知前隐藏其中的敏感信息
+	errorMsg = redact.String(errorMsg)
+
+	record.Status = status
+	record.EndTime = time.Now()
+	record.ErrorMessage = errorMsg
+
+	_ = s.recordRepo.Update(record)
+
+	// 如果是失败状态，发送Webhook通知
+	if status == entity.StatusFailed {
+		task, err := s.taskRepo.FindByID(record.TaskID)
+		if err == nil && task != nil {
+			// 尝试发送通知，忽略错误
+			_ = s.webhookService.SendBackupFailureNotification(
+				task.Name,
+				errorMsg,
+			)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package chunkstore
+
+import (
+	"io"
+)
+
+// 数据块大小，内容定义的分块点落在最小和最大大小之间，平均约为1MB
+const (
+	minChunkSize = 512 * 1024
+	maxChunkSize = 8 * 1024 * 1024
+)
+
+// 分块点掩码，取gear哈希的高20位，使分块点平均每1MB出现一次
+const chunkMask = uint64(1<<20-1) << 44
+
+// gear 滚动哈希使用的随机表，由固定种子生成，保证不同运行中的分块点一致
+var gear = func() [256]uint64 {
+	var table [256]uint64
+	seed := uint64(0x6261636b75702d67) // "backup-g"
+	for i := range table {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// Chunker 使用gear滚动哈希按内容切分数据，插入或删除数据只影响附近的数据块
+type Chunker struct {
+	reader     io.Reader
+	buf        []byte
+	start, end int
+	eof        bool
+}
+
+// NewChunker 创建分块器
+func NewChunker(r io.Reader) *Chunker {
+	return &Chunker{reader: r, buf: make([]byte, maxChunkSize)}
+}
+
+// Reset 复用缓冲区切分新的数据
+func (c *Chunker) Reset(r io.Reader) {
+	c.reader = r
+	c.start, c.end = 0, 0
+	c.eof = false
+}
+
+// Next 返回下一个数据块，数据读完时返回io.EOF
+// 返回的切片在下一次调用前有效
+func (c *Chunker) Next() ([]byte, error) {
+	// 缓冲区中的数据不足一个最大数据块时继续读取
+	if c.end-c.start < maxChunkSize && !c.eof {
+		copy(c.buf, c.buf[c.start:c.end])
+		c.end -= c.start
+		c.start = 0
+
+		n, err := io.ReadFull(c.reader, c.buf[c.end:])
+		c.end += n
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			c.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	data := c.buf[c.start:c.end]
+	if len(data) == 0 {
+		return nil, io.EOF
+	}
+
+	n := cutPoint(data)
+	c.start += n
+	return data[:n], nil
+}
+
+// cutPoint 查找数据中的第一个分块点
+func cutPoint(data []byte) int {
+	if len(data) <= minChunkSize {
+		return len(data)
+	}
+
+	n := len(data)
+	if n > maxChunkSize {
+		n = maxChunkSize
+	}
+
+	var hash uint64
+	for i := minChunkSize; i < n; i++ {
+		hash = (hash << 1) + gear[data[i]]
+		if hash&chunkMask == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
@@ -0,0 +1,167 @@
+package chunkstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+// randomData 生成固定种子的随机数据，使分块点在每次运行中一致
+func randomData(size int, seed int64) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+// chunkAll 切分全部数据，返回各数据块的副本
+func chunkAll(t *testing.T, r io.Reader) [][]byte {
+	t.Helper()
+	var chunks [][]byte
+	chunker := NewChunker(r)
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			return chunks
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, append([]byte{}, chunk...))
+	}
+}
+
+// chunkHashes 返回各数据块的SHA-256
+func chunkHashes(chunks [][]byte) [][32]byte {
+	hashes := make([][32]byte, len(chunks))
+	for i, chunk := range chunks {
+		hashes[i] = sha256.Sum256(chunk)
+	}
+	return hashes
+}
+
+func TestChunkerSizes(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"smaller than minimum", randomData(1000, 1)},
+		{"exactly minimum", randomData(minChunkSize, 2)},
+		{"random", randomData(20*1024*1024, 3)},
+		// 全零数据的滚动哈希没有分块点，按最大大小切分
+		{"zeros", make([]byte, 2*maxChunkSize+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := chunkAll(t, bytes.NewReader(tt.data))
+			if len(tt.data) == 0 && len(chunks) != 0 {
+				t.Fatalf("got %d chunks for empty input", len(chunks))
+			}
+			for i, chunk := range chunks {
+				last := i == len(chunks)-1
+				if len(chunk) > maxChunkSize || (!last && len(chunk) < minChunkSize) {
+					t.Errorf("chunk %d has size %d, want %d to %d", i, len(chunk), minChunkSize, maxChunkSize)
+				}
+			}
+			if joined := bytes.Join(chunks, nil); !bytes.Equal(joined, tt.data) {
+				t.Fatal("chunks do not reassemble to the input")
+			}
+		})
+	}
+}
+
+func TestChunkerIndependentOfReadSize(t *testing.T) {
+	data := randomData(12*1024*1024, 4)
+	want := chunkHashes(chunkAll(t, bytes.NewReader(data)))
+	got := chunkHashes(chunkAll(t, iotest.HalfReader(bytes.NewReader(data))))
+	if len(got) != len(want) {
+		t.Fatalf("got %d chunks, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("chunk %d differs when reading in small pieces", i)
+		}
+	}
+}
+
+func TestChunkerBoundaryStability(t *testing.T) {
+	data := randomData(20*1024*1024, 5)
+	original := chunkHashes(chunkAll(t, bytes.NewReader(data)))
+
+	tests := []struct {
+		name   string
+		modify func([]byte) []byte
+	}{
+		{"insert", func(d []byte) []byte {
+			return append(d[:5<<20:5<<20], append([]byte("inserted bytes"), d[5<<20:]...)...)
+		}},
+		{"delete", func(d []byte) []byte {
+			return append(d[:5<<20:5<<20], d[5<<20+100:]...)
+		}},
+		{"overwrite", func(d []byte) []byte {
+			copy(d[5<<20:], "overwritten")
+			return d
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modified := chunkHashes(chunkAll(t, bytes.NewReader(tt.modify(append([]byte{}, data...)))))
+
+			// 修改位置附近之外的数据块应保持不变
+			seen := make(map[[32]byte]bool)
+			for _, hash := range original {
+				seen[hash] = true
+			}
+			changed := 0
+			for _, hash := range modified {
+				if !seen[hash] {
+					changed++
+				}
+			}
+			if changed == 0 || changed > 2 {
+				t.Errorf("%d of %d chunks changed, want 1 or 2", changed, len(modified))
+			}
+		})
+	}
+}
+
+func TestChunkerReset(t *testing.T) {
+	first := randomData(3*1024*1024, 6)
+	second := randomData(2*1024*1024, 7)
+
+	chunker := NewChunker(bytes.NewReader(first))
+	for {
+		if _, err := chunker.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 复用的分块器与新建的分块器结果一致
+	chunker.Reset(bytes.NewReader(second))
+	var reused [][]byte
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		reused = append(reused, append([]byte{}, chunk...))
+	}
+	want := chunkHashes(chunkAll(t, bytes.NewReader(second)))
+	got := chunkHashes(reused)
+	if len(got) != len(want) {
+		t.Fatalf("got %d chunks after Reset, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("chunk %d differs after Reset", i)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package chunkstore
+
+import (
+	"backup-go/entity"
+	"backup-go/service/encryption"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 快照格式版本
+const snapshotVersion = 1
+
+// SnapshotExtension 快照文件的扩展名
+const SnapshotExtension = ".snapshot.json.gz"
+
+// 快照中的条目类型
+const (
+	NodeFile    = "file"    // 普通文件
+	NodeDir     = "dir"     // 目录
+	NodeSymlink = "symlink" // 符号链接
+)
+
+// Snapshot 仓库格式的文件备份快照，记录所有条目及文件内容对应的数据块
+type Snapshot struct {
+	Version  int     `json:"version"`  // 快照格式版本
+	RecordID int64   `json:"recordId"` // 快照所属的备份记录ID
+	Nodes    []*Node `json:"nodes"`    // 按遍历顺序排列的条目，目录在其下的条目之前
+}
+
+// Node 快照中的条目
+type Node struct {
+	Path     string      `json:"path"`               // 条目路径，使用"/"分隔
+	Type     string      `json:"type"`               // 条目类型：file、dir、symlink
+	Mode     os.FileMode `json:"mode"`               // 文件权限
+	ModTime  time.Time   `json:"modTime"`            // 修改时间
+	Size     int64       `json:"size,omitempty"`     // 文件大小，单位字节
+	Linkname string      `json:"linkname,omitempty"` // 符号链接的目标
+	Uid      int         `json:"uid"`                // 用户ID
+	Gid      int         `json:"gid"`                // 组ID
+	Uname    string      `json:"uname,omitempty"`    // 用户名
+	Gname    string      `json:"gname,omitempty"`    // 组名
+	Chunks   []string    `json:"chunks,omitempty"`   // 文件内容的数据块哈希，按顺序拼接
+}
+
+// NewSnapshot 创建空快照
+func NewSnapshot(recordID int64) *Snapshot {
+	return &Snapshot{
+		Version:  snapshotVersion,
+		RecordID: recordID,
+		Nodes:    []*Node{},
+	}
+}
+
+// IsSnapshot 判断备份文件是否为快照
+func IsSnapshot(filePath string) bool {
+	return strings.HasSuffix(encryption.PlainName(filepath.Base(filePath)), SnapshotExtension)
+}
+
+// LoadSnapshot 读取备份记录的快照
+func LoadSnapshot(record *entity.BackupRecord) (*Snapshot, error) {
+	if !IsSnapshot(record.FilePath) {
+		return nil, fmt.Errorf("backup record %d is not a snapshot", record.ID)
+	}
+
+	file, err := encryption.OpenRecordFile(record)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	defer reader.Close()
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(reader).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	if snapshot.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version: %d", snapshot.Version)
+	}
+	return &snapshot, nil
+}
+
+// Write 将快照以gzip压缩的JSON写入w
+func (s *Snapshot) Write(w io.Writer) error {
+	writer := gzip.NewWriter(w)
+	if err := json.NewEncoder(writer).Encode(s); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	return writer.Close()
+}
@@ -0,0 +1,415 @@
+package chunkstore
+
+import (
+	"backup-go/entity"
+	"backup-go/repository"
+	"backup-go/service/compression"
+	"backup-go/service/encryption"
+	"backup-go/service/storage"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// gcLock 写入数据块时持有共享锁，垃圾回收时持有排他锁
+// 避免回收掉正在进行的备份刚刚复用、但快照尚未保存的数据块
+var gcLock sync.RWMutex
+
+// chunkLocks 按数据块哈希加锁，多个备份同时保存相同的新数据块时只上传一次
+var chunkLocks = &keyedMutex{locks: make(map[string]*keyedLock)}
+
+// SaveFunc 将内容保存到存储，返回路径、实际写入内容的SHA-256和大小，由调用方加密，须使用创建Store时指定的密钥
+type SaveFunc func(filename string, content io.Reader) (string, string, int64, error)
+
+// Store 将数据块按内容寻址保存到存储，同一存储中使用同一密钥保存过的数据块不会重复保存
+type Store struct {
+	storageType      entity.StorageType
+	keyID            string // 加密使用的密钥ID，为空表示不加密
+	chunkRepo        *repository.ChunkRepository
+	compression      string
+	compressionLevel int
+	save             SaveFunc
+	known            map[string]bool // 本次已确认存在的数据块
+	closeOnce        sync.Once
+
+	newChunks    int   // 新保存的数据块数
+	reusedChunks int   // 复用已有数据块的次数
+	storedSize   int64 // 新保存的数据块压缩和加密后的总大小
+}
+
+// NewStore 创建数据块存储，keyID为save加密使用的密钥ID，使用完毕后需要调用Close
+func NewStore(storageType entity.StorageType, keyID string, method string, level int, save SaveFunc) (*Store, error) {
+	if err := compression.Validate(method, level); err != nil {
+		return nil, err
+	}
+
+	gcLock.RLock()
+	return &Store{
+		storageType:      storageType,
+		keyID:            keyID,
+		chunkRepo:        repository.NewChunkRepository(),
+		compression:      method,
+		compressionLevel: level,
+		save:             save,
+		known:            make(map[string]bool),
+	}, nil
+}
+
+// Close 释放共享锁，允许垃圾回收
+func (s *Store) Close() {
+	s.closeOnce.Do(gcLock.RUnlock)
+}
+
+// Put 保存数据块并返回其SHA-256，已存在时只返回哈希
+func (s *Store) Put(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	if s.known[hash] {
+		s.reusedChunks++
+		return hash, nil
+	}
+
+	// 查找、上传和创建记录期间持有该数据块的锁，其他备份等待后会找到已创建的记录
+	// 未加密或使用其他密钥保存的数据块不能复用，否则加密的备份会引用明文或已停用密钥加密的内容
+	unlock := chunkLocks.Lock(string(s.storageType) + ":" + s.keyID + ":" + hash)
+	defer unlock()
+
+	existing, err := s.chunkRepo.FindByHash(hash, s.storageType, s.keyID)
+	if err != nil {
+		return "", fmt.Errorf("failed to find chunk: %w", err)
+	}
+	if existing != nil {
+		s.known[hash] = true
+		s.reusedChunks++
+		return hash, nil
+	}
+
+	// 压缩数据块
+	var buf bytes.Buffer
+	writer, err := compression.NewWriter(&buf, s.compression, s.compressionLevel)
+	if err != nil {
+		return "", err
+	}
+	if _, err := writer.Write(data); err != nil {
+		writer.Close()
+		return "", fmt.Errorf("failed to compress chunk: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress chunk: %w", err)
+	}
+
+	// 保存到存储，记录实际写入的大小
+	filename := chunkFilename(hash, s.keyID) + compression.Extension(s.compression)
+	chunkPath, checksum, storedSize, err := s.save(filename, &buf)
+	if err != nil {
+		return "", fmt.Errorf("failed to save chunk: %w", err)
+	}
+
+	chunk := &entity.Chunk{
+		Hash:            hash,
+		StorageType:     s.storageType,
+		EncryptionKeyID: s.keyID,
+		Path:            chunkPath,
+		Size:            int64(len(data)),
+		StoredSize:      storedSize,
+		Compression:     s.compression,
+		Checksum:        checksum,
+	}
+	if err := s.chunkRepo.Create(chunk); err != nil {
+		// 其他进程同时保存了相同的数据块时违反唯一索引，改为复用已有的记录
+		existing, findErr := s.chunkRepo.FindByHash(hash, s.storageType, s.keyID)
+		if findErr != nil || existing == nil {
+			return "", fmt.Errorf("failed to create chunk record: %w", err)
+		}
+		if existing.Path != chunkPath {
+			s.deleteDuplicate(chunkPath)
+		}
+		s.known[hash] = true
+		s.reusedChunks++
+		return hash, nil
+	}
+
+	s.known[hash] = true
+	s.newChunks++
+	s.storedSize += storedSize
+	return hash, nil
+}
+
+// chunkFilename 返回数据块的文件名，加密的数据块带有密钥ID的摘要，避免与其他密钥保存的同一数据块使用相同的路径
+func chunkFilename(hash, keyID string) string {
+	if keyID == "" {
+		return hash + ".chunk"
+	}
+	sum := sha256.Sum256([]byte(keyID))
+	return hash + "-" + hex.EncodeToString(sum[:4]) + ".chunk"
+}
+
+// deleteDuplicate 删除重复上传的数据块，失败时只记录日志
+func (s *Store) deleteDuplicate(chunkPath string) {
+	storageService, err := storage.NewStorageService(s.storageType)
+	if err == nil {
+		err = storageService.Delete(chunkPath)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("删除重复的数据块失败: %s, 错误: %v", chunkPath, err)
+	}
+}
+
+// Stats 返回新保存的数据块数、复用的数据块数和新保存的数据大小
+func (s *Store) Stats() (int, int, int64) {
+	return s.newChunks, s.reusedChunks, s.storedSize
+}
+
+// OpenChunks 按顺序读取数据块并拼接为文件内容，读取时校验每个数据块的哈希
+// keyID为快照使用的密钥ID，优先读取使用该密钥保存的数据块
+func OpenChunks(storageType entity.StorageType, keyID string, hashes []string) (io.ReadCloser, error) {
+	storageService, err := storage.NewStorageService(storageType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage service: %w", err)
+	}
+	return &chunkReader{
+		storageService: storageService,
+		storageType:    storageType,
+		keyID:          keyID,
+		chunkRepo:      repository.NewChunkRepository(),
+		hashes:         hashes,
+	}, nil
+}
+
+// chunkReader 依次读取数据块
+type chunkReader struct {
+	storageService storage.StorageService
+	storageType    entity.StorageType
+	keyID          string
+	chunkRepo      *repository.ChunkRepository
+	hashes         []string
+
+	current  string        // 正在读取的数据块哈希
+	file     io.ReadCloser // 存储中的文件
+	reader   io.ReadCloser // 解密和解压后的内容
+	checksum hash.Hash
+}
+
+// Read 读取文件内容，一个数据块读完后自动打开下一个
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.reader == nil {
+			if len(r.hashes) == 0 {
+				return 0, io.EOF
+			}
+			if err := r.open(r.hashes[0]); err != nil {
+				return 0, err
+			}
+			r.hashes = r.hashes[1:]
+		}
+
+		n, err := r.reader.Read(p)
+		r.checksum.Write(p[:n])
+		if err == io.EOF {
+			r.closeChunk()
+			if hex.EncodeToString(r.checksum.Sum(nil)) != r.current {
+				return n, fmt.Errorf("chunk %s is corrupted", r.current)
+			}
+			err = nil
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// open 打开数据块
+func (r *chunkReader) open(chunkHash string) error {
+	chunk, err := r.chunkRepo.FindForRead(chunkHash, r.storageType, r.keyID)
+	if err != nil {
+		return fmt.Errorf("failed to find chunk: %w", err)
+	}
+	if chunk == nil {
+		return fmt.Errorf("chunk %s not found", chunkHash)
+	}
+
+	file, err := r.storageService.Get(chunk.Path)
+	if err != nil {
+		return fmt.Errorf("failed to get chunk %s: %w", chunkHash, err)
+	}
+	decrypted, err := encryption.DecryptWithKey(file, chunk.EncryptionKeyID)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	reader, err := compression.NewReader(decrypted, chunk.Compression)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.current = chunkHash
+	r.file = file
+	r.reader = reader
+	r.checksum = sha256.New()
+	return nil
+}
+
+// closeChunk 关闭当前数据块
+func (r *chunkReader) closeChunk() {
+	if r.reader != nil {
+		r.reader.Close()
+		r.file.Close()
+		r.reader, r.file = nil, nil
+	}
+}
+
+// Close 关闭读取器
+func (r *chunkReader) Close() error {
+	r.closeChunk()
+	return nil
+}
+
+// VerifyChunks 重新读取存储中的数据块并与保存时的校验和比较，返回缺失或不一致的数据块
+// 未记录校验和的数据块会被跳过，读取存储失败（文件缺失除外）时返回错误
+// keyID为快照使用的密钥ID，检查的是读取时会使用的数据块
+func VerifyChunks(storageType entity.StorageType, keyID string, hashes []string) ([]string, error) {
+	storageService, err := storage.NewStorageService(storageType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage service: %w", err)
+	}
+	chunkRepo := repository.NewChunkRepository()
+
+	checked := make(map[string]bool)
+	var bad []string
+	for _, chunkHash := range hashes {
+		if checked[chunkHash] {
+			continue
+		}
+		checked[chunkHash] = true
+
+		chunk, err := chunkRepo.FindForRead(chunkHash, storageType, keyID)
+		if err != nil {
+			return bad, fmt.Errorf("failed to find chunk: %w", err)
+		}
+		if chunk == nil {
+			bad = append(bad, chunkHash)
+			continue
+		}
+		if chunk.Checksum == "" {
+			continue
+		}
+
+		checksum, err := storage.Checksum(storageService, chunk.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			bad = append(bad, chunkHash)
+			continue
+		}
+		if err != nil {
+			return bad, fmt.Errorf("failed to read chunk %s: %w", chunkHash, err)
+		}
+		if checksum != chunk.Checksum {
+			bad = append(bad, chunkHash)
+		}
+	}
+	return bad, nil
+}
+
+// CollectGarbage 删除不再被任何快照引用的数据块，返回删除的数据块数和释放的存储大小
+// 有备份正在写入数据块时跳过回收
+func CollectGarbage() (int, int64, error) {
+	if !gcLock.TryLock() {
+		return 0, 0, fmt.Errorf("backups are writing chunks, garbage collection skipped")
+	}
+	defer gcLock.Unlock()
+
+	records, err := repository.NewBackupRecordRepository().ListAll()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load backup records: %w", err)
+	}
+
+	// 收集所有快照引用的数据块，任何快照读取失败都不进行回收，避免误删
+	referenced := make(map[string]bool)
+	for _, record := range records {
+		if record.Status == entity.StatusCleaned || !IsSnapshot(record.FilePath) {
+			continue
+		}
+		snapshot, err := LoadSnapshot(record)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to load snapshot of backup record %d: %w", record.ID, err)
+		}
+		for _, node := range snapshot.Nodes {
+			for _, chunkHash := range node.Chunks {
+				referenced[string(record.StorageType)+":"+chunkHash] = true
+			}
+		}
+	}
+
+	chunkRepo := repository.NewChunkRepository()
+	chunks, err := chunkRepo.ListAll()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load chunks: %w", err)
+	}
+
+	deleted := 0
+	var freed int64
+	for _, chunk := range chunks {
+		if referenced[string(chunk.StorageType)+":"+chunk.Hash] {
+			continue
+		}
+
+		storageService, err := storage.NewStorageService(chunk.StorageType)
+		if err != nil {
+			return deleted, freed, fmt.Errorf("failed to create storage service: %w", err)
+		}
+		if err := storageService.Delete(chunk.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("删除数据块失败: %s, 错误: %v", chunk.Path, err)
+			continue
+		}
+		if err := chunkRepo.Delete(chunk.ID); err != nil {
+			log.Printf("删除数据块记录失败: %s, 错误: %v", chunk.Hash, err)
+			continue
+		}
+		deleted++
+		freed += chunk.StoredSize
+	}
+	return deleted, freed, nil
+}
+
+// keyedMutex 按键加锁，不再使用的键会被移除
+type keyedMutex struct {
+	mutex sync.Mutex
+	locks map[string]*keyedLock
+}
+
+// keyedLock 单个键的锁和等待者数量
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+// Lock 锁定指定的键，返回解锁函数
+func (m *keyedMutex) Lock(key string) func() {
+	m.mutex.Lock()
+	lock := m.locks[key]
+	if lock == nil {
+		lock = &keyedLock{}
+		m.locks[key] = lock
+	}
+	lock.refs++
+	m.mutex.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		m.mutex.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(m.locks, key)
+		}
+		m.mutex.Unlock()
+	}
+}
@@ -0,0 +1,170 @@
+package chunkstore
+
+import (
+	"backup-go/config"
+	"backup-go/entity"
+	"backup-go/repository"
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setupDB 在临时目录中创建SQLite数据库
+func setupDB(t *testing.T) {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+
+	if err := config.LoadConfig("config.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.InitDB(); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.MigrateDB(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPutConcurrentSameChunk(t *testing.T) {
+	setupDB(t)
+
+	var saves int32
+	save := func(filename string, content io.Reader) (string, string, int64, error) {
+		atomic.AddInt32(&saves, 1)
+		n, _ := io.Copy(io.Discard, content)
+		// 拉长上传时间，让另一个备份在此期间查找同一个数据块
+		time.Sleep(50 * time.Millisecond)
+		return "20240101/" + filename, "", n, nil
+	}
+
+	data := bytes.Repeat([]byte("chunk"), 1000)
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store, err := NewStore(entity.LocalStorage, "", "none", 0, save)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer store.Close()
+			_, errs[i] = store.Put(data)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if saves != 1 {
+		t.Errorf("chunk uploaded %d times, want 1", saves)
+	}
+}
+
+func TestPutReusesChunkCreatedConcurrently(t *testing.T) {
+	setupDB(t)
+
+	// 模拟另一个进程在上传期间创建了同一数据块的记录
+	save := func(filename string, content io.Reader) (string, string, int64, error) {
+		n, _ := io.Copy(io.Discard, content)
+		other := &entity.Chunk{
+			Hash:        filename[:64],
+			StorageType: entity.LocalStorage,
+			Path:        "20231231/" + filename,
+			Compression: "none",
+		}
+		if err := repository.NewChunkRepository().Create(other); err != nil {
+			return "", "", 0, err
+		}
+		return "20240101/" + filename, "", n, nil
+	}
+
+	store, err := NewStore(entity.LocalStorage, "", "none", 0, save)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	hash, err := store.Put([]byte("shared chunk"))
+	if err != nil {
+		t.Fatalf("Put failed on unique conflict: %v", err)
+	}
+	chunk, err := repository.NewChunkRepository().FindByHash(hash, entity.LocalStorage, "")
+	if err != nil || chunk == nil {
+		t.Fatalf("chunk record not found: %v", err)
+	}
+	if chunk.Path != "20231231/"+hash+".chunk" {
+		t.Errorf("chunk path = %s, want the existing record", chunk.Path)
+	}
+	if newChunks, reused, _ := store.Stats(); newChunks != 0 || reused != 1 {
+		t.Errorf("stats = %d new, %d reused, want 0 new, 1 reused", newChunks, reused)
+	}
+}
+
+func TestPutSeparatesChunksByKey(t *testing.T) {
+	setupDB(t)
+
+	var saved []string
+	save := func(filename string, content io.Reader) (string, string, int64, error) {
+		n, _ := io.Copy(io.Discard, content)
+		saved = append(saved, filename)
+		return "20240101/" + filename, "", n, nil
+	}
+	data := []byte("same content under different keys")
+
+	// 未加密、使用key1、再次使用key1、使用key2依次保存相同的数据块
+	for _, keyID := range []string{"", "key1", "key1", "key2"} {
+		store, err := NewStore(entity.LocalStorage, keyID, "none", 0, save)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Put(data); err != nil {
+			t.Fatal(err)
+		}
+		store.Close()
+	}
+
+	if len(saved) != 3 {
+		t.Fatalf("chunk uploaded %d times, want 3: %v", len(saved), saved)
+	}
+	if saved[0] == saved[1] || saved[1] == saved[2] || saved[0] == saved[2] {
+		t.Errorf("chunks saved with different keys share a filename: %v", saved)
+	}
+
+	chunkRepo := repository.NewChunkRepository()
+	hash := saved[0][:64]
+	for _, keyID := range []string{"", "key1", "key2"} {
+		chunk, err := chunkRepo.FindByHash(hash, entity.LocalStorage, keyID)
+		if err != nil || chunk == nil {
+			t.Fatalf("chunk for key %q not found: %v", keyID, err)
+		}
+		if chunk.EncryptionKeyID != keyID {
+			t.Errorf("chunk key = %q, want %q", chunk.EncryptionKeyID, keyID)
+		}
+	}
+
+	// 读取时优先使用快照的密钥，没有时使用任一记录
+	chunk, err := chunkRepo.FindForRead(hash, entity.LocalStorage, "key2")
+	if err != nil || chunk == nil || chunk.EncryptionKeyID != "key2" {
+		t.Errorf("FindForRead(key2) = %+v, %v", chunk, err)
+	}
+	chunk, err = chunkRepo.FindForRead(hash, entity.LocalStorage, "retired")
+	if err != nil || chunk == nil {
+		t.Errorf("FindForRead(retired) = %+v, %v", chunk, err)
+	}
+}
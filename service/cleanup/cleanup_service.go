@@ -3,18 +3,17 @@ package cleanup
 import (
 	"backup-go/entity"
 	"backup-go/repository"
+	"backup-go/service/chunkstore"
 	"backup-go/service/config"
+	"backup-go/service/storage"
+	"errors"
+	"fmt"
 	"log"
 	"os"
-	"path/filepath"
 	"strconv"
 	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/robfig/cron/v3"
 )
 
@@ -23,6 +22,7 @@ type CleanupService struct {
 	cron           *cron.Cron
 	configService  *config.ConfigService
 	recordRepo     *repository.BackupRecordRepository
+	binlogRepo     *repository.BinlogFileRepository
 	webhookService *config.WebhookService
 	cronEntryID    cron.EntryID
 	mutex          sync.Mutex
@@ -41,6 +41,7 @@ func GetCleanupService() *CleanupService {
 			cron:           cron.New(cron.WithSeconds()),
 			configService:  config.NewConfigService(),
 			recordRepo:     repository.NewBackupRecordRepository(),
+			binlogRepo:     repository.NewBinlogFileRepository(),
 			webhookService: config.NewWebhookService(),
 		}
 	})
@@ -98,13 +99,22 @@ func (s *CleanupService) ExecuteAndGetResult() *CleanupResult {
 		ErrorMessages: []string{},
 	}
 
+	s.cleanupRecords(result)
+	s.collectGarbage(result)
+
+	log.Printf("清理任务完成。成功: %d, 失败: %d, 跳过: %d", result.Success, result.Failed, result.Skipped)
+	return result
+}
+
+// cleanupRecords 清理过期的备份记录及其文件
+func (s *CleanupService) cleanupRecords(result *CleanupResult) {
 	// 获取清理天数配置
 	daysStr, err := s.configService.GetConfigValue("system.autoCleanupDays")
 	if err != nil {
 		errMsg := "获取清理天数配置失败: " + err.Error()
 		log.Println(errMsg)
 		result.ErrorMessages = append(result.ErrorMessages, errMsg)
-		return result
+		return
 	}
 
 	days, err := strconv.Atoi(daysStr)
@@ -112,14 +122,14 @@ func (s *CleanupService) ExecuteAndGetResult() *CleanupResult {
 		errMsg := "清理天数配置值无效: " + err.Error()
 		log.Println(errMsg)
 		result.ErrorMessages = append(result.ErrorMessages, errMsg)
-		return result
+		return
 	}
 
 	// 0表示不清理
 	if days <= 0 {
 		log.Println("清理天数设置为0，跳过清理")
 		result.ErrorMessages = append(result.ErrorMessages, "清理天数设置为0，跳过清理")
-		return result
+		return
 	}
 
 	// 计算清理日期
@@ -132,54 +142,29 @@ func (s *CleanupService) ExecuteAndGetResult() *CleanupResult {
 		errMsg := "查询过期备份记录失败: " + err.Error()
 		log.Println(errMsg)
 		result.ErrorMessages = append(result.ErrorMessages, errMsg)
-		return result
+		return
 	}
 
 	log.Printf("找到%d条需要清理的备份记录", len(records))
 	if len(records) == 0 {
-		return result
-	}
-
-	// 获取存储配置（这些只用于本地存储路径）
-	localPath, _ := s.configService.GetConfigValue("storage.localPath")
-	if localPath == "" {
-		localPath = "./backup" // 默认路径
+		return
 	}
 
-	// 获取S3配置信息
-	s3Endpoint, _ := s.configService.GetConfigValue("storage.s3Endpoint")
-	s3Region, _ := s.configService.GetConfigValue("storage.s3Region")
-	s3AccessKey, _ := s.configService.GetConfigValue("storage.s3AccessKey")
-	s3SecretKey, _ := s.configService.GetConfigValue("storage.s3SecretKey")
-	s3Bucket, _ := s.configService.GetConfigValue("storage.s3Bucket")
-
 	// 清理记录
 	for _, record := range records {
-		// 根据记录中的StorageType来处理不同的存储类型
+		// 仍被增量或差异备份依赖的记录暂不清理，记录按时间倒序处理，依赖它的记录清理后即可在同一轮中清理
+		if dependents, err := s.recordRepo.CountDependents(record.ID); err != nil || dependents > 0 {
+			errMsg := fmt.Sprintf("备份记录 %d 仍被其他备份依赖，跳过清理", record.ID)
+			log.Println(errMsg)
+			result.Skipped++
+			result.ErrorMessages = append(result.ErrorMessages, errMsg)
+			continue
+		}
+
+		// 所有存储类型都通过存储服务删除文件，旧记录没有存储类型时按配置和路径判断
 		switch record.StorageType {
-		case entity.LocalStorage:
-			// 获取本地存储路径配置
-			localPath, _ := s.configService.GetConfigValue("storage.localPath")
-			if localPath == "" {
-				localPath = "backups"
-			}
-			// 本地存储文件清理
-			if s.cleanupLocalFile(record, localPath) {
-				result.Success++
-			} else {
-				result.Failed++
-			}
-		case entity.S3Storage:
-			// 检查S3配置是否有效
-			if s3Endpoint == "" || s3AccessKey == "" || s3SecretKey == "" || s3Bucket == "" {
-				errMsg := "S3配置不完整，跳过S3文件清理: " + record.FilePath
-				log.Println(errMsg)
-				result.Skipped++
-				result.ErrorMessages = append(result.ErrorMessages, errMsg)
-				continue
-			}
-			// S3存储文件清理
-			if s.cleanupS3File(record, s3Endpoint, s3Region, s3AccessKey, s3SecretKey, s3Bucket) {
+		case "", entity.LocalStorage, entity.S3Storage, entity.SFTPStorage, entity.WebDAVStorage, entity.FTPStorage, entity.AzureStorage, entity.GCSStorage:
+			if s.cleanupStorageFile(record) {
 				result.Success++
 			} else {
 				result.Failed++
@@ -191,10 +176,30 @@ func (s *CleanupService) ExecuteAndGetResult() *CleanupResult {
 			result.ErrorMessages = append(result.ErrorMessages, errMsg)
 			continue
 		}
+
+		// 已清理的binlog归档不能再用于时间点恢复
+		if record.Status == entity.StatusCleaned {
+			if err := s.binlogRepo.DeleteByRecordID(record.ID); err != nil {
+				log.Printf("删除记录 %d 的binlog索引失败: %v", record.ID, err)
+			}
+		}
 	}
+}
 
-	log.Printf("清理任务完成。成功: %d, 失败: %d, 跳过: %d", result.Success, result.Failed, result.Skipped)
-	return result
+// collectGarbage 删除仓库格式的备份中不再被任何快照引用的数据块
+func (s *CleanupService) collectGarbage(result *CleanupResult) {
+	deleted, freed, err := chunkstore.CollectGarbage()
+	if err != nil {
+		errMsg := "回收数据块失败: " + err.Error()
+		log.Println(errMsg)
+		result.ErrorMessages = append(result.ErrorMessages, errMsg)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("已回收%d个数据块，释放%d字节", deleted, freed)
+	}
+	result.DeletedChunks = deleted
+	result.FreedSize = freed
 }
 
 // cleanup 执行清理任务
@@ -211,90 +216,36 @@ func (s *CleanupService) cleanup() {
 	}
 }
 
-// cleanupLocalFile 清理本地文件
-func (s *CleanupService) cleanupLocalFile(record *entity.BackupRecord, localPath string) bool {
+// cleanupStorageFile 通过存储服务删除备份文件和清单文件
+func (s *CleanupService) cleanupStorageFile(record *entity.BackupRecord) bool {
 	if record.FilePath == "" {
 		log.Printf("记录 %d 没有文件路径，跳过", record.ID)
 		return true
 	}
 
-	// 本地存储，直接删除文件
-	filePath := record.FilePath
-	if !filepath.IsAbs(filePath) {
-		filePath = filepath.Join(localPath, filePath)
-	}
-
-	if err := os.Remove(filePath); err != nil {
-		if os.IsNotExist(err) {
-			log.Printf("文件已不存在: %s", filePath)
-			// 更新数据库记录
-			record.FilePath = ""
-			record.FileSize = 0
-			record.Status = "cleaned" // 标记为已清理状态
-			record.ErrorMessage = "文件已被自动清理"
-			if err := s.recordRepo.Update(record); err != nil {
-				log.Printf("更新记录失败: %v", err)
-				return false
-			}
-			return true
-		}
-		log.Printf("删除本地文件失败: %s, 错误: %v", filePath, err)
-		return false
-	}
-
-	// 更新数据库记录
-	record.FilePath = ""
-	record.FileSize = 0
-	record.Status = "cleaned" // 标记为已清理状态
-	record.ErrorMessage = "文件已被自动清理"
-	if err := s.recordRepo.Update(record); err != nil {
-		log.Printf("更新记录失败: %v", err)
-		return false
-	}
-	return true
-}
-
-// cleanupS3File 清理S3文件
-func (s *CleanupService) cleanupS3File(record *entity.BackupRecord, endpoint, region, accessKey, secretKey, bucket string) bool {
-	if record.FilePath == "" {
-		log.Printf("记录 %d 没有文件路径，跳过", record.ID)
-		return true
-	}
-
-	// 数据库中存储的就是相对路径，直接用作S3对象键
-	s3Key := record.FilePath
-	log.Printf("使用S3对象键: %s", s3Key)
-
-	// 使用AWS SDK删除S3文件
-	sess, err := session.NewSession(&aws.Config{
-		Endpoint:         aws.String(endpoint),
-		Region:           aws.String(region),
-		Credentials:      credentials.NewStaticCredentials(accessKey, secretKey, ""),
-		S3ForcePathStyle: aws.Bool(true),
-	})
-
+	storageService, err := storage.NewStorageServiceForRecord(record)
 	if err != nil {
-		log.Printf("创建S3会话失败: %v", err)
+		log.Printf("创建存储服务失败: %v", err)
 		return false
 	}
 
-	// 创建S3客户端
-	svc := s3.New(sess)
-
-	// 删除S3对象
-	input := &s3.DeleteObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(s3Key),
+	// 删除清单文件
+	if record.ManifestPath != "" {
+		if err := storageService.Delete(record.ManifestPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("删除清单文件失败: %s, 错误: %v", record.ManifestPath, err)
+			return false
+		}
+		record.ManifestPath = ""
 	}
 
-	_, err = svc.DeleteObject(input)
-	if err != nil {
-		log.Printf("删除S3文件失败: %s, 错误: %v", s3Key, err)
-		return false
+	if err := storageService.Delete(record.FilePath); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("删除文件失败: %s, 错误: %v", record.FilePath, err)
+			return false
+		}
+		log.Printf("文件已不存在: %s", record.FilePath)
 	}
 
-	log.Printf("成功删除S3文件: %s", s3Key)
-
 	// 更新数据库记录
 	record.FilePath = ""
 	record.FileSize = 0
@@ -312,6 +263,8 @@ type CleanupResult struct {
 	Success       int
 	Failed        int
 	Skipped       int
+	DeletedChunks int   // 回收的数据块数
+	FreedSize     int64 // 回收数据块释放的存储大小，单位字节
 	ErrorMessages []string
 }
 
@@ -0,0 +1,138 @@
+package compression
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// 压缩方式
+const (
+	None    = "none"    // 不压缩
+	Gzip    = "gzip"    // gzip压缩
+	Zstd    = "zstd"    // zstd压缩
+	Deflate = "deflate" // ZIP内部的Deflate压缩，仅用于备份记录
+)
+
+// Validate 检查压缩方式和压缩级别，级别为0时使用默认级别
+func Validate(method string, level int) error {
+	switch method {
+	case None:
+		return nil
+	case Gzip:
+		if level < 0 || level > 9 {
+			return fmt.Errorf("gzip compression level must be between 1 and 9")
+		}
+		return nil
+	case Zstd:
+		if level < 0 || level > 22 {
+			return fmt.Errorf("zstd compression level must be between 1 and 22")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported compression: %s", method)
+	}
+}
+
+// Extension 返回压缩方式对应的文件扩展名，不压缩时为空
+func Extension(method string) string {
+	switch method {
+	case Gzip:
+		return ".gz"
+	case Zstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+// Detect 根据文件扩展名判断压缩方式
+func Detect(filename string) string {
+	switch {
+	case strings.HasSuffix(filename, ".gz"):
+		return Gzip
+	case strings.HasSuffix(filename, ".zst"):
+		return Zstd
+	default:
+		return None
+	}
+}
+
+// TrimExtension 去掉文件名中的压缩扩展名
+func TrimExtension(filename string) string {
+	return strings.TrimSuffix(filename, Extension(Detect(filename)))
+}
+
+// NewWriter 返回压缩写入器，调用方需要Close以写入剩余数据，不会关闭底层写入器
+func NewWriter(w io.Writer, method string, level int) (io.WriteCloser, error) {
+	if err := Validate(method, level); err != nil {
+		return nil, err
+	}
+
+	switch method {
+	case Gzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case Zstd:
+		encoderLevel := zstd.SpeedDefault
+		if level > 0 {
+			encoderLevel = zstd.EncoderLevelFromZstd(level)
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(encoderLevel))
+	default:
+		return nopWriteCloser{w}, nil
+	}
+}
+
+// CompressReader 将读取器转换为压缩后的读取器，压缩在后台进行
+func CompressReader(r io.Reader, method string, level int) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		writer, err := NewWriter(pw, method, level)
+		if err == nil {
+			_, err = io.Copy(writer, r)
+			if closeErr := writer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// NewReader 返回解压读取器，不会关闭底层读取器
+func NewReader(r io.Reader, method string) (io.ReadCloser, error) {
+	switch method {
+	case Gzip:
+		reader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip data: %w", err)
+		}
+		return reader, nil
+	case Zstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zstd data: %w", err)
+		}
+		return decoder.IOReadCloser(), nil
+	case None:
+		return io.NopCloser(r), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", method)
+	}
+}
+
+// nopWriteCloser 不压缩时使用的写入器
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close 不做任何操作
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -34,13 +34,53 @@ func (s *ConfigService) InitDefaultConfigs() error {
 		{"storage.s3AccessKey", "", "S3访问密钥"},
 		{"storage.s3SecretKey", "", "S3私有密钥"},
 		{"storage.s3Bucket", "", "S3存储桶名称"},
+		// 添加SFTP相关配置
+		{"storage.sftpHost", "", "SFTP服务器地址"},
+		{"storage.sftpPort", "22", "SFTP端口"},
+		{"storage.sftpUser", "", "SFTP用户名"},
+		{"storage.sftpPassword", "", "SFTP密码，使用私钥时为私钥口令"},
+		{"storage.sftpKeyPath", "", "SFTP私钥文件路径"},
+		{"storage.sftpKnownHosts", "", "known_hosts文件路径，为空时使用~/.ssh/known_hosts"},
+		{"storage.sftpBasePath", "backups", "SFTP服务器上的备份目录"},
+		// 添加WebDAV相关配置
+		{"storage.webdavUrl", "", "WebDAV地址"},
+		{"storage.webdavUser", "", "WebDAV用户名"},
+		{"storage.webdavPassword", "", "WebDAV密码或应用密码"},
+		{"storage.webdavToken", "", "WebDAV Bearer令牌，设置后不使用用户名密码"},
+		{"storage.webdavChunkSize", "0", "分块上传的分块大小（MB），0表示不分块，仅Nextcloud/ownCloud支持"},
+		// 添加FTP相关配置
+		{"storage.ftpHost", "", "FTP服务器地址"},
+		{"storage.ftpPort", "", "FTP端口，为空时普通FTP和显式TLS使用21，隐式TLS使用990"},
+		{"storage.ftpUser", "", "FTP用户名，为空时匿名登录"},
+		{"storage.ftpPassword", "", "FTP密码"},
+		{"storage.ftpTLS", "none", "FTP加密方式：none、explicit（显式TLS）、implicit（隐式TLS）"},
+		{"storage.ftpInsecureSkipVerify", "false", "是否跳过FTPS服务器证书校验"},
+		{"storage.ftpBasePath", "backups", "FTP服务器上的备份目录"},
+		// 添加Azure Blob相关配置
+		{"storage.azureAccount", "", "Azure存储账户名"},
+		{"storage.azureAccountKey", "", "Azure存储账户共享密钥"},
+		{"storage.azureSasToken", "", "Azure SAS令牌，未配置共享密钥时使用"},
+		{"storage.azureContainer", "backup-go", "Azure容器名称"},
+		{"storage.azureEndpoint", "", "Azure Blob服务端点，为空时使用https://<账户名>.blob.core.windows.net"},
+		{"storage.azureBlockSize", "8", "Azure块上传的块大小（MB）"},
+		{"storage.azureAccessTier", "", "Azure访问层：Hot、Cool、Cold、Archive，为空时使用账户默认值"},
+		// 添加Google Cloud Storage相关配置
+		{"storage.gcsBucket", "backup-go", "GCS存储桶名称"},
+		{"storage.gcsCredentials", "", "GCS服务账号密钥，JSON内容或密钥文件路径"},
+		{"storage.gcsEndpoint", "", "GCS服务端点，为空时使用https://storage.googleapis.com"},
+		{"storage.gcsStorageClass", "", "GCS存储类别：STANDARD、NEARLINE、COLDLINE、ARCHIVE，为空时使用存储桶默认值"},
 		// 添加系统自动清理配置
 		{"system.autoCleanupDays", "90", "自动清理天数，0表示不清理"},
+		{"system.integrityCheckSchedule", "", "完整性校验的Cron表达式，为空表示不定时校验"},
 		// 添加Webhook相关配置
 		{"webhook.enabled", "false", "是否启用Webhook通知"},
 		{"webhook.url", "", "Webhook URL"},
 		{"webhook.headers", "", "Webhook请求头，一行一个"},
 		{"webhook.body", `{"event":"${event}","taskName":"${taskName}","message":"${message}"}`, "Webhook请求体模板"},
+		// 添加备份加密配置
+		{"encryption.enabled", "false", "是否加密备份文件"},
+		{"encryption.keyId", "", "新备份使用的加密密钥ID"},
+		{"encryption.keys", "{}", "加密密钥环，JSON格式，保留历史密钥用于解密"},
 		// 添加站点配置
 		{"system.siteName", "备份系统", "站点名称"},
 	}
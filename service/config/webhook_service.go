@@ -1,6 +1,7 @@
 package config
 
 import (
+	"backup-go/service/redact"
 	"fmt"
 	"log"
 	"net/url"
@@ -94,6 +95,73 @@ func (w *WebhookService) SendBackupSuccessNotification(taskName string, fileSize
 	return w.sendWebhook(data)
 }
 
+// SendRestoreNotification 发送恢复结果通知
+func (w *WebhookService) SendRestoreNotification(taskName string, success bool, message string, duration time.Duration) error {
+	// 检查是否启用了webhook
+	enabled, err := w.configService.GetConfigValue("webhook.enabled")
+	if err != nil || enabled != "true" {
+		return nil // 未启用或查询错误，不发送通知
+	}
+
+	event := "恢复成功"
+	if !success {
+		event = "恢复失败"
+	}
+
+	// 准备数据
+	data := &WebhookData{
+		TaskName: taskName,
+		Event:    event,
+		Message:  fmt.Sprintf("%s，耗时: %.2f秒", message, duration.Seconds()),
+	}
+
+	// 发送通知
+	return w.sendWebhook(data)
+}
+
+// SendVerifyNotification 发送恢复校验结果通知
+func (w *WebhookService) SendVerifyNotification(taskName string, success bool, message string) error {
+	// 检查是否启用了webhook
+	enabled, err := w.configService.GetConfigValue("webhook.enabled")
+	if err != nil || enabled != "true" {
+		return nil // 未启用或查询错误，不发送通知
+	}
+
+	event := "校验通过"
+	if !success {
+		event = "校验失败"
+	}
+
+	// 准备数据
+	data := &WebhookData{
+		TaskName: taskName,
+		Event:    event,
+		Message:  message,
+	}
+
+	// 发送通知
+	return w.sendWebhook(data)
+}
+
+// SendIntegrityNotification 发送备份文件完整性校验失败通知
+func (w *WebhookService) SendIntegrityNotification(taskName string, message string) error {
+	// 检查是否启用了webhook
+	enabled, err := w.configService.GetConfigValue("webhook.enabled")
+	if err != nil || enabled != "true" {
+		return nil // 未启用或查询错误，不发送通知
+	}
+
+	// 准备数据
+	data := &WebhookData{
+		TaskName: taskName,
+		Event:    "完整性校验失败",
+		Message:  message,
+	}
+
+	// 发送通知
+	return w.sendWebhook(data)
+}
+
 // SendCleanupNotification 发送清理操作完成通知
 func (w *WebhookService) SendCleanupNotification(success, failed, skipped int, isAuto bool, errorMessages []string) error {
 	// 检查是否启用了webhook
@@ -197,6 +265,12 @@ func (w *WebhookService) sendWebhook(data *WebhookData) error {
 
 // sendWebhookWithConfig 使用指定配置发送webhook通知
 func (w *WebhookService) sendWebhookWithConfig(data *WebhookData, urlStr, headersStr, bodyTemplate string) error {
+	// 通知内容可能来自错误信息，发送前隐藏其中的敏感信息
+	redacted := *data
+	redacted.TaskName = redact.String(data.TaskName)
+	redacted.Message = redact.String(data.Message)
+	data = &redacted
+
 	// 替换变量
 	body := w.replaceVariables(bodyTemplate, data)
 
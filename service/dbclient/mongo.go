@@ -0,0 +1,110 @@
+package dbclient
+
+import (
+	"backup-go/entity"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// MongoToolArgs 生成mongodump/mongorestore的连接参数
+// 密码和连接字符串写入临时配置文件，通过--config传递，避免出现在命令行和进程列表中
+// 返回的cleanup在命令结束后调用，删除临时文件
+func MongoToolArgs(info *entity.DatabaseSourceInfo) ([]string, func(), error) {
+	var args []string
+	secrets := make(map[string]string)
+	if info.URI != "" {
+		// 使用连接字符串，支持副本集、authSource等参数
+		secrets["uri"] = info.URI
+	} else {
+		args = append(args, "--host="+info.Host, fmt.Sprintf("--port=%d", mongoPort(info)))
+		if info.User != "" {
+			args = append(args, "--username="+info.User)
+			secrets["password"] = info.Password
+			if info.AuthSource != "" {
+				args = append(args, "--authenticationDatabase="+info.AuthSource)
+			}
+		}
+	}
+	if len(secrets) == 0 {
+		return args, func() {}, nil
+	}
+
+	path, err := writeSecretFile("backup-go-mongo-*.yaml", secrets)
+	if err != nil {
+		return nil, nil, err
+	}
+	args = append(args, "--config="+path)
+	return args, func() { os.Remove(path) }, nil
+}
+
+// MongoShellArgs 生成mongosh执行表达式的参数
+// mongosh不支持--config，连接字符串写入临时文件，由脚本读取后连接
+// 返回的cleanup在命令结束后调用，删除临时文件
+func MongoShellArgs(info *entity.DatabaseSourceInfo, script string) ([]string, func(), error) {
+	path, err := writeSecretFile("backup-go-mongosh-*.json", map[string]string{"uri": MongoURI(info)})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pathJSON, _ := json.Marshal(path)
+	connect := fmt.Sprintf("db = connect(JSON.parse(require(\"fs\").readFileSync(%s, \"utf8\")).uri); ", pathJSON)
+	args := []string{"--nodb", "--quiet", "--eval", connect + script}
+	return args, func() { os.Remove(path) }, nil
+}
+
+// MongoURI 返回连接字符串，未配置时由主机、端口和用户信息生成
+func MongoURI(info *entity.DatabaseSourceInfo) string {
+	if info.URI != "" {
+		return info.URI
+	}
+
+	u := &url.URL{
+		Scheme: "mongodb",
+		Host:   net.JoinHostPort(info.Host, strconv.Itoa(mongoPort(info))),
+		Path:   "/",
+	}
+	if info.User != "" {
+		u.User = url.UserPassword(info.User, info.Password)
+		if info.AuthSource != "" {
+			u.RawQuery = url.Values{"authSource": {info.AuthSource}}.Encode()
+		}
+	}
+	return u.String()
+}
+
+// mongoPort 返回端口，未配置时使用默认端口
+func mongoPort(info *entity.DatabaseSourceInfo) int {
+	if info.Port == 0 {
+		return 27017
+	}
+	return info.Port
+}
+
+// writeSecretFile 将敏感配置写入仅当前用户可读写的临时文件
+// 内容为JSON，同时也是mongodump --config可以读取的YAML
+func writeSecretFile(pattern string, values map[string]string) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	// CreateTemp创建的文件权限为0600
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create credentials file: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	return file.Name(), nil
+}
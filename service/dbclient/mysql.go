@@ -0,0 +1,204 @@
+package dbclient
+
+import (
+	"backup-go/entity"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MySQL客户端工具类型
+const (
+	FlavorMySQL   = "mysql"
+	FlavorMariaDB = "mariadb"
+)
+
+// MySQLTool MySQL或MariaDB的客户端工具，如mysqldump、mysql、mysqlbinlog
+type MySQLTool struct {
+	Program string // 可执行文件
+	Flavor  string // 工具类型：mysql或mariadb
+	Version string // 版本号，未能检测时为空
+}
+
+// String 返回工具的描述，用于日志和备份摘要
+func (t *MySQLTool) String() string {
+	if t.Version == "" {
+		return fmt.Sprintf("%s (%s)", t.Program, t.Flavor)
+	}
+	return fmt.Sprintf("%s %s (%s)", t.Program, t.Version, t.Flavor)
+}
+
+// --version 输出中的版本号，如：
+// mysqldump  Ver 8.0.36 for Linux on x86_64 (MySQL Community Server - GPL)
+// mysqldump  Ver 10.13 Distrib 5.7.44, for Linux (x86_64)
+// mysqldump  Ver 10.19 Distrib 10.6.16-MariaDB, for debian-linux-gnu (x86_64)
+// mariadb-dump from 11.4.2-MariaDB, client 10.19 for debian-linux-gnu (x86_64)
+// mysql  Ver 8.0.36 for Linux on x86_64 (MySQL Community Server - GPL)
+var (
+	mariadbVersionPattern = regexp.MustCompile(`(\d+\.\d+\.\d+)-MariaDB`)
+	mysqlVersionPattern   = regexp.MustCompile(`(?:Distrib|Ver) (\d+\.\d+\.\d+)`)
+)
+
+// DetectMySQLDump 查找可用的导出工具（mysqldump或mariadb-dump）并检测类型和版本
+func DetectMySQLDump(flavor string) *MySQLTool {
+	switch flavor {
+	case FlavorMySQL:
+		return detectMySQLTool(flavor, "mysqldump")
+	case FlavorMariaDB:
+		// 新版本的MariaDB只提供mariadb-dump，mysqldump可能是指向它的链接
+		return detectMySQLTool(flavor, "mariadb-dump", "mysqldump")
+	default:
+		return detectMySQLTool(flavor, "mysqldump", "mariadb-dump")
+	}
+}
+
+// DetectMySQLClient 查找可用的命令行客户端（mysql或mariadb）并检测类型和版本
+func DetectMySQLClient(flavor string) *MySQLTool {
+	switch flavor {
+	case FlavorMySQL:
+		return detectMySQLTool(flavor, "mysql")
+	case FlavorMariaDB:
+		return detectMySQLTool(flavor, "mariadb", "mysql")
+	default:
+		return detectMySQLTool(flavor, "mysql", "mariadb")
+	}
+}
+
+// DetectMySQLBinlog 查找可用的binlog工具（mysqlbinlog或mariadb-binlog）并检测类型和版本
+func DetectMySQLBinlog(flavor string) *MySQLTool {
+	switch flavor {
+	case FlavorMySQL:
+		return detectMySQLTool(flavor, "mysqlbinlog")
+	case FlavorMariaDB:
+		// 新版本的MariaDB只提供mariadb-binlog
+		return detectMySQLTool(flavor, "mariadb-binlog", "mysqlbinlog")
+	default:
+		return detectMySQLTool(flavor, "mysqlbinlog", "mariadb-binlog")
+	}
+}
+
+// detectMySQLTool 依次尝试各个可执行文件，使用第一个可以运行的
+// 指定类型时以指定的为准，未能检测时按MySQL处理
+func detectMySQLTool(flavor string, programs ...string) *MySQLTool {
+	for _, program := range programs {
+		output, err := exec.Command(program, "--version").Output()
+		if err != nil {
+			continue
+		}
+		detectedFlavor, version := ParseMySQLVersion(string(output))
+		tool := &MySQLTool{Program: program, Flavor: detectedFlavor, Version: version}
+		if flavor != "" && flavor != detectedFlavor {
+			// 检测到的版本号属于另一种工具，不能用于判断参数
+			tool.Flavor = flavor
+			tool.Version = ""
+		}
+		return tool
+	}
+
+	if flavor == "" {
+		flavor = FlavorMySQL
+	}
+	return &MySQLTool{Program: programs[0], Flavor: flavor}
+}
+
+// ParseMySQLVersion 从--version输出中解析工具类型和版本号
+func ParseMySQLVersion(output string) (string, string) {
+	if match := mariadbVersionPattern.FindStringSubmatch(output); match != nil {
+		return FlavorMariaDB, match[1]
+	}
+	if match := mysqlVersionPattern.FindStringSubmatch(output); match != nil {
+		return FlavorMySQL, match[1]
+	}
+	if strings.Contains(output, "MariaDB") {
+		return FlavorMariaDB, ""
+	}
+	return FlavorMySQL, ""
+}
+
+// VersionAtLeast 判断版本号是否不低于指定版本，版本号未知时返回true
+func VersionAtLeast(version string, major, minor, patch int) bool {
+	if version == "" {
+		return true
+	}
+	parts := strings.Split(version, ".")
+	want := []int{major, minor, patch}
+	for i, w := range want {
+		if i >= len(parts) {
+			return true
+		}
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return true
+		}
+		if n != w {
+			return n > w
+		}
+	}
+	return true
+}
+
+// MySQLSSLArgs 生成TLS参数，MySQL 5.7.11起使用--ssl-mode，MariaDB和更早的版本使用--ssl
+func MySQLSSLArgs(options *entity.MySQLOptions, tool *MySQLTool) []string {
+	if options == nil {
+		options = &entity.MySQLOptions{}
+	}
+	mode := strings.ToUpper(options.SSLMode)
+	if mode == "" {
+		mode = "DISABLED"
+	}
+
+	var args []string
+	if tool.Flavor == FlavorMySQL && VersionAtLeast(tool.Version, 5, 7, 11) {
+		args = append(args, "--ssl-mode="+mode)
+	} else {
+		switch mode {
+		case "DISABLED":
+			args = append(args, "--skip-ssl")
+		case "PREFERRED", "REQUIRED":
+			// MariaDB 11.4起默认校验服务器证书，这两种模式不校验
+			args = append(args, "--ssl", "--skip-ssl-verify-server-cert")
+		default:
+			args = append(args, "--ssl", "--ssl-verify-server-cert")
+		}
+	}
+
+	if options.SSLCA != "" {
+		args = append(args, "--ssl-ca="+options.SSLCA)
+	}
+	if options.SSLCert != "" {
+		args = append(args, "--ssl-cert="+options.SSLCert)
+	}
+	if options.SSLKey != "" {
+		args = append(args, "--ssl-key="+options.SSLKey)
+	}
+	return args
+}
+
+// MySQLFlavor 返回任务配置中指定的工具类型，为空时自动检测
+func MySQLFlavor(info *entity.DatabaseSourceInfo) string {
+	if info.MySQL == nil {
+		return ""
+	}
+	return info.MySQL.Flavor
+}
+
+// BinlogSequence 解析binlog文件名中的序号，如binlog.000012为12，不是binlog文件名时返回false
+func BinlogSequence(name string) (int64, bool) {
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 || dot == len(name)-1 {
+		return 0, false
+	}
+	digits := name[dot+1:]
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	sequence, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return sequence, true
+}
@@ -0,0 +1,112 @@
+package dbclient
+
+import (
+	"backup-go/entity"
+	"reflect"
+	"testing"
+)
+
+func TestParseMySQLVersion(t *testing.T) {
+	tests := []struct {
+		output  string
+		flavor  string
+		version string
+	}{
+		{"mysqldump  Ver 8.0.36 for Linux on x86_64 (MySQL Community Server - GPL)", FlavorMySQL, "8.0.36"},
+		{"mysqldump  Ver 10.13 Distrib 5.7.44, for Linux (x86_64)", FlavorMySQL, "5.7.44"},
+		{"mysqldump  Ver 10.19 Distrib 10.6.16-MariaDB, for debian-linux-gnu (x86_64)", FlavorMariaDB, "10.6.16"},
+		{"mariadb-dump from 11.4.2-MariaDB, client 10.19 for debian-linux-gnu (x86_64)", FlavorMariaDB, "11.4.2"},
+		{"mysql  Ver 15.1 Distrib 10.11.6-MariaDB, for debian-linux-gnu (x86_64) using  EditLine wrapper", FlavorMariaDB, "10.11.6"},
+		{"mysql  Ver 8.4.0 for Linux on x86_64 (MySQL Community Server - GPL)", FlavorMySQL, "8.4.0"},
+		{"unknown output", FlavorMySQL, ""},
+	}
+
+	for _, tt := range tests {
+		flavor, version := ParseMySQLVersion(tt.output)
+		if flavor != tt.flavor || version != tt.version {
+			t.Errorf("ParseMySQLVersion(%q) = %s %s, want %s %s", tt.output, flavor, version, tt.flavor, tt.version)
+		}
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"8.0.26", true},
+		{"8.0.25", false},
+		{"8.1.0", true},
+		{"5.7.44", false},
+		{"", true},
+		{"8.0", true},
+	}
+
+	for _, tt := range tests {
+		if got := VersionAtLeast(tt.version, 8, 0, 26); got != tt.want {
+			t.Errorf("VersionAtLeast(%q, 8.0.26) = %t, want %t", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestMySQLSSLArgs(t *testing.T) {
+	mysql8 := &MySQLTool{Program: "mysql", Flavor: FlavorMySQL, Version: "8.0.36"}
+	mysql56 := &MySQLTool{Program: "mysql", Flavor: FlavorMySQL, Version: "5.6.51"}
+	mariadb := &MySQLTool{Program: "mariadb", Flavor: FlavorMariaDB, Version: "11.4.2"}
+
+	tests := []struct {
+		name    string
+		options *entity.MySQLOptions
+		tool    *MySQLTool
+		want    []string
+	}{
+		{"default mysql", nil, mysql8, []string{"--ssl-mode=DISABLED"}},
+		{"default mariadb", nil, mariadb, []string{"--skip-ssl"}},
+		{"old mysql", &entity.MySQLOptions{SSLMode: "required"}, mysql56, []string{"--ssl", "--skip-ssl-verify-server-cert"}},
+		{
+			"verify ca",
+			&entity.MySQLOptions{SSLMode: "VERIFY_CA", SSLCA: "/ca.pem"},
+			mysql8,
+			[]string{"--ssl-mode=VERIFY_CA", "--ssl-ca=/ca.pem"},
+		},
+		{
+			"mariadb client cert",
+			&entity.MySQLOptions{SSLMode: "VERIFY_IDENTITY", SSLCert: "/c.pem", SSLKey: "/k.pem"},
+			mariadb,
+			[]string{"--ssl", "--ssl-verify-server-cert", "--ssl-cert=/c.pem", "--ssl-key=/k.pem"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MySQLSSLArgs(tt.options, tt.tool); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MySQLSSLArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBinlogSequence(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence int64
+		ok       bool
+	}{
+		{"binlog.000012", 12, true},
+		{"mysql-bin.000001", 1, true},
+		{"host.example.com-bin.123456", 123456, true},
+		{"binlog.1000000", 1000000, true},
+		{"binlog", 0, false},
+		{"binlog.", 0, false},
+		{"binlog.index", 0, false},
+		{"binlog.-1", 0, false},
+		{"binlog.+1", 0, false},
+		{"binlog.99999999999999999999", 0, false},
+	}
+	for _, tt := range tests {
+		sequence, ok := BinlogSequence(tt.name)
+		if sequence != tt.sequence || ok != tt.ok {
+			t.Errorf("BinlogSequence(%q) = %d, %v, want %d, %v", tt.name, sequence, ok, tt.sequence, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package encryption
+
+import (
+	"backup-go/entity"
+	configService "backup-go/service/config"
+	"backup-go/service/storage"
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Extension 加密后的备份文件追加的扩展名
+const Extension = ".enc"
+
+// 加密文件头：magic(6) + 版本(1) + 方式(1) + 密钥ID长度(1) + 密钥ID + 方式相关的参数
+const (
+	magic   = "BGOENC"
+	version = 1
+)
+
+// 加密方式
+const (
+	modeKey        byte = 1 // AES-256-GCM，使用配置的密钥
+	modePassphrase byte = 2 // AES-256-GCM，使用口令经scrypt派生的密钥
+	modeAge        byte = 3 // age，使用X25519公钥加密
+)
+
+// 密钥类型
+const (
+	KeyTypeAES        = "aes"
+	KeyTypePassphrase = "passphrase"
+	KeyTypeAge        = "age"
+)
+
+// 盐长度，每个文件随机生成，用于派生文件密钥
+const saltSize = 16
+
+// Key 加密密钥配置
+type Key struct {
+	Type       string   `json:"type"`                 // 密钥类型：aes、passphrase、age
+	Key        string   `json:"key,omitempty"`        // AES-256密钥，32字节，十六进制或Base64编码
+	Passphrase string   `json:"passphrase,omitempty"` // 口令
+	Recipients []string `json:"recipients,omitempty"` // age公钥（age1...），用于加密
+	Identities []string `json:"identities,omitempty"` // age私钥（AGE-SECRET-KEY-1...），用于解密，不配置时服务端无法解密
+}
+
+// Keyring 密钥环，保留历史密钥以便解密轮换前的备份
+type Keyring struct {
+	Enabled     bool            // 是否加密新备份
+	ActiveKeyID string          // 新备份使用的密钥ID
+	Keys        map[string]*Key // 所有密钥，按ID索引
+}
+
+// LoadKeyring 从系统配置读取密钥环
+func LoadKeyring() (*Keyring, error) {
+	cs := configService.NewConfigService()
+
+	keyring := &Keyring{
+		Enabled:     cs.GetConfigValueOrDefault("encryption.enabled", "false") == "true",
+		ActiveKeyID: strings.TrimSpace(cs.GetConfigValueOrDefault("encryption.keyId", "")),
+		Keys:        make(map[string]*Key),
+	}
+
+	keys := strings.TrimSpace(cs.GetConfigValueOrDefault("encryption.keys", ""))
+	if keys != "" {
+		if err := json.Unmarshal([]byte(keys), &keyring.Keys); err != nil {
+			return nil, fmt.Errorf("invalid encryption.keys: %w", err)
+		}
+	}
+	return keyring, nil
+}
+
+// Encryptor 备份加密器
+type Encryptor struct {
+	keyID string
+	key   *Key
+}
+
+// NewEncryptor 根据系统配置创建加密器，未启用加密时返回nil
+func NewEncryptor() (*Encryptor, error) {
+	keyring, err := LoadKeyring()
+	if err != nil {
+		return nil, err
+	}
+	if !keyring.Enabled {
+		return nil, nil
+	}
+
+	if keyring.ActiveKeyID == "" {
+		return nil, fmt.Errorf("encryption is enabled but encryption.keyId is not set")
+	}
+	if len(keyring.ActiveKeyID) > 255 {
+		return nil, fmt.Errorf("encryption key ID is too long")
+	}
+	key, ok := keyring.Keys[keyring.ActiveKeyID]
+	if !ok {
+		return nil, fmt.Errorf("encryption key %q not found in encryption.keys", keyring.ActiveKeyID)
+	}
+
+	encryptor := &Encryptor{keyID: keyring.ActiveKeyID, key: key}
+	// 提前校验密钥，避免备份完成后才发现配置错误
+	if _, err := encryptor.Encrypt(io.Discard); err != nil {
+		return nil, err
+	}
+	return encryptor, nil
+}
+
+// KeyID 返回加密使用的密钥ID
+func (e *Encryptor) KeyID() string {
+	return e.keyID
+}
+
+// Encrypt 返回加密写入器，写入的明文加密后输出到w，调用方需要Close以写入最后一块
+func (e *Encryptor) Encrypt(w io.Writer) (io.WriteCloser, error) {
+	header := []byte(magic)
+	header = append(header, version)
+
+	switch e.key.Type {
+	case KeyTypeAES, KeyTypePassphrase:
+		mode := modeKey
+		if e.key.Type == KeyTypePassphrase {
+			mode = modePassphrase
+		}
+		header = append(header, mode, byte(len(e.keyID)))
+		header = append(header, e.keyID...)
+
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+		header = append(header, salt...)
+
+		aead, err := fileCipher(e.key, mode, salt)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(header); err != nil {
+			return nil, err
+		}
+		return newChunkWriter(w, aead, header), nil
+
+	case KeyTypeAge:
+		recipients, err := age.ParseRecipients(strings.NewReader(strings.Join(e.key.Recipients, "\n")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipients for key %q: %w", e.keyID, err)
+		}
+
+		header = append(header, modeAge, byte(len(e.keyID)))
+		header = append(header, e.keyID...)
+		if _, err := w.Write(header); err != nil {
+			return nil, err
+		}
+		return age.Encrypt(w, recipients...)
+
+	default:
+		return nil, fmt.Errorf("unsupported encryption key type: %s", e.key.Type)
+	}
+}
+
+// EncryptReader 将明文读取器转换为密文读取器，加密在后台进行
+func (e *Encryptor) EncryptReader(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		writer, err := e.Encrypt(pw)
+		if err == nil {
+			_, err = io.Copy(writer, r)
+			if closeErr := writer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// Decrypt 解密备份数据，未加密的数据原样返回
+// 加密方式和密钥ID从文件头读取，因此轮换密钥后仍能解密旧备份
+func Decrypt(r io.Reader) (io.Reader, error) {
+	return DecryptWithKey(r, "")
+}
+
+// DecryptWithKey 解密备份数据，keyID为备份记录中保存的密钥ID
+// keyID不为空时数据必须是使用该密钥加密的，防止存储中的文件被替换为明文或其他密钥加密的内容；为空时与Decrypt相同
+func DecryptWithKey(r io.Reader, keyID string) (io.Reader, error) {
+	return decrypt(r, keyID, func(keyID string) (*Key, error) {
+		keyring, err := LoadKeyring()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := keyring.Keys[keyID]
+		if !ok {
+			return nil, fmt.Errorf("encryption key %q not found in encryption.keys", keyID)
+		}
+		return key, nil
+	})
+}
+
+// decrypt 解析文件头，通过lookup按密钥ID查找密钥后解密，expectedKeyID不为空时检查文件头中的密钥ID
+func decrypt(r io.Reader, expectedKeyID string, lookup func(keyID string) (*Key, error)) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, chunkSize)
+	head, err := br.Peek(len(magic))
+	if err != nil || string(head) != magic {
+		if expectedKeyID != "" {
+			return nil, fmt.Errorf("file is not encrypted, expected encryption key %q", expectedKeyID)
+		}
+		return br, nil
+	}
+
+	// 读取文件头
+	fixed := make([]byte, len(magic)+3)
+	if _, err := io.ReadFull(br, fixed); err != nil {
+		return nil, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+	if fixed[len(magic)] != version {
+		return nil, fmt.Errorf("unsupported encryption version: %d", fixed[len(magic)])
+	}
+	mode := fixed[len(magic)+1]
+	keyID := make([]byte, fixed[len(magic)+2])
+	if _, err := io.ReadFull(br, keyID); err != nil {
+		return nil, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+	header := append(fixed, keyID...)
+	if expectedKeyID != "" && string(keyID) != expectedKeyID {
+		return nil, fmt.Errorf("file is encrypted with key %q, expected %q", string(keyID), expectedKeyID)
+	}
+
+	key, err := lookup(string(keyID))
+	if err != nil {
+		return nil, err
+	}
+
+	switch mode {
+	case modeKey, modePassphrase:
+		salt := make([]byte, saltSize)
+		if _, err := io.ReadFull(br, salt); err != nil {
+			return nil, fmt.Errorf("failed to read encryption header: %w", err)
+		}
+		header = append(header, salt...)
+
+		aead, err := fileCipher(key, mode, salt)
+		if err != nil {
+			return nil, err
+		}
+		return newChunkReader(br, aead, header), nil
+
+	case modeAge:
+		if len(key.Identities) == 0 {
+			return nil, fmt.Errorf("no age identity configured for key %q", string(keyID))
+		}
+		identities, err := age.ParseIdentities(strings.NewReader(strings.Join(key.Identities, "\n")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid age identities for key %q: %w", string(keyID), err)
+		}
+		return age.Decrypt(br, identities...)
+
+	default:
+		return nil, fmt.Errorf("unsupported encryption mode: %d", mode)
+	}
+}
+
+// OpenRecordFile 从存储中读取备份记录对应的文件，加密的文件会被解密
+func OpenRecordFile(record *entity.BackupRecord) (io.ReadCloser, error) {
+	return OpenFile(record, record.FilePath)
+}
+
+// OpenFile 从备份记录所在的存储中读取指定路径的文件，加密的文件会被解密
+// 记录了密钥ID的备份，文件必须是使用该密钥加密的
+func OpenFile(record *entity.BackupRecord, path string) (io.ReadCloser, error) {
+	storageService, err := storage.NewStorageServiceForRecord(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage service: %w", err)
+	}
+
+	file, err := storageService.Get(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backup file: %w", err)
+	}
+
+	reader, err := DecryptWithKey(file, record.EncryptionKeyID)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &readCloser{Reader: reader, Closer: file}, nil
+}
+
+// PlainName 去掉加密文件名中的加密扩展名
+func PlainName(filename string) string {
+	return strings.TrimSuffix(filename, Extension)
+}
+
+// readCloser 组合解密读取器和底层文件
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// fileCipher 派生文件密钥并创建AES-256-GCM
+// 主密钥经HMAC-SHA256与每个文件的随机盐派生出文件密钥，使每个文件的块序号nonce不会重复
+func fileCipher(key *Key, mode byte, salt []byte) (cipher.AEAD, error) {
+	var master []byte
+	switch mode {
+	case modeKey:
+		if key.Type != KeyTypeAES {
+			return nil, fmt.Errorf("encryption key type mismatch: expected aes, got %s", key.Type)
+		}
+		decoded, err := decodeKey(key.Key)
+		if err != nil {
+			return nil, err
+		}
+		master = decoded
+	case modePassphrase:
+		if key.Type != KeyTypePassphrase {
+			return nil, fmt.Errorf("encryption key type mismatch: expected passphrase, got %s", key.Type)
+		}
+		if key.Passphrase == "" {
+			return nil, fmt.Errorf("encryption passphrase is empty")
+		}
+		derived, err := scrypt.Key([]byte(key.Passphrase), salt, 1<<15, 8, 1, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key from passphrase: %w", err)
+		}
+		master = derived
+	}
+
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte("backup-go file key"))
+	mac.Write(salt)
+
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decodeKey 解析十六进制或Base64编码的32字节密钥
+func decodeKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if decoded, err := hex.DecodeString(encoded); err == nil && len(decoded) == 32 {
+		return decoded, nil
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(decoded) == 32 {
+		return decoded, nil
+	}
+	return nil, fmt.Errorf("encryption key must be 32 bytes encoded as hex or base64")
+}
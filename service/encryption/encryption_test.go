@@ -0,0 +1,177 @@
+package encryption
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// 测试使用的AES密钥，32字节的十六进制
+var testKey = &Key{Type: KeyTypeAES, Key: strings.Repeat("0f", 32)}
+
+// testLookup 返回只包含指定密钥的查找函数
+func testLookup(keyID string, key *Key) func(string) (*Key, error) {
+	return func(id string) (*Key, error) {
+		if id != keyID {
+			return nil, fmt.Errorf("encryption key %q not found", id)
+		}
+		return key, nil
+	}
+}
+
+// encrypt 使用指定密钥加密明文
+func encrypt(t *testing.T, key *Key, plain []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer, err := (&Encryptor{keyID: "k1", key: key}).Encrypt(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// decryptAll 解密并读取全部明文
+func decryptAll(data []byte, key *Key) ([]byte, error) {
+	reader, err := decrypt(bytes.NewReader(data), "", testLookup("k1", key))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+// headerSize AES密钥方式的文件头长度
+func headerSize() int {
+	return len(magic) + 3 + len("k1") + saltSize
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	keys := map[string]*Key{
+		"aes":        testKey,
+		"passphrase": {Type: KeyTypePassphrase, Passphrase: "correct horse"},
+	}
+	sizes := []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3 * chunkSize}
+
+	for name, key := range keys {
+		for _, size := range sizes {
+			t.Run(fmt.Sprintf("%s/%d", name, size), func(t *testing.T) {
+				plain := bytes.Repeat([]byte{0xA5}, size)
+				data := encrypt(t, key, plain)
+
+				// 每块的明文加上认证标签，最后一块即使为空也会输出
+				chunks := size/chunkSize + 1
+				if size > 0 && size%chunkSize == 0 {
+					chunks--
+				}
+				if want := headerSize() + size + chunks*16; len(data) != want {
+					t.Errorf("encrypted size = %d, want %d", len(data), want)
+				}
+
+				got, err := decryptAll(data, key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, plain) {
+					t.Fatalf("decrypted %d bytes, want %d", len(got), size)
+				}
+			})
+		}
+	}
+}
+
+func TestDecryptDetectsTruncationAndTampering(t *testing.T) {
+	plain := bytes.Repeat([]byte("backup"), chunkSize/2)
+	data := encrypt(t, testKey, plain)
+	sealedChunk := chunkSize + 16
+
+	tests := []struct {
+		name   string
+		mutate func([]byte) []byte
+	}{
+		{"header only", func(d []byte) []byte { return d[:headerSize()] }},
+		{"cut at chunk boundary", func(d []byte) []byte { return d[:headerSize()+sealedChunk] }},
+		{"cut inside chunk", func(d []byte) []byte { return d[:headerSize()+sealedChunk+100] }},
+		{"last byte missing", func(d []byte) []byte { return d[:len(d)-1] }},
+		{"trailing data", func(d []byte) []byte { return append(d, 0) }},
+		{"flipped ciphertext bit", func(d []byte) []byte { d[headerSize()+10] ^= 1; return d }},
+		{"flipped salt bit", func(d []byte) []byte { d[headerSize()-1] ^= 1; return d }},
+		{"chunks swapped", func(d []byte) []byte {
+			first := append([]byte{}, d[headerSize():headerSize()+sealedChunk]...)
+			second := d[headerSize()+sealedChunk : headerSize()+2*sealedChunk]
+			copy(d[headerSize():], second)
+			copy(d[headerSize()+sealedChunk:], first)
+			return d
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mutated := tt.mutate(append([]byte{}, data...))
+			if _, err := decryptAll(mutated, testKey); err == nil {
+				t.Fatal("decryption succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestDecryptWrongKey(t *testing.T) {
+	data := encrypt(t, testKey, []byte("secret"))
+	wrong := &Key{Type: KeyTypeAES, Key: strings.Repeat("f0", 32)}
+	if _, err := decryptAll(data, wrong); err == nil {
+		t.Fatal("decryption with wrong key succeeded")
+	}
+}
+
+func TestDecryptPlaintextPassthrough(t *testing.T) {
+	for _, plain := range []string{"", "BGO", "plain backup data"} {
+		got, err := decryptAll([]byte(plain), testKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != plain {
+			t.Errorf("passthrough = %q, want %q", got, plain)
+		}
+	}
+}
+
+func TestDecryptExpectedKey(t *testing.T) {
+	encrypted := encrypt(t, testKey, []byte("secret"))
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+		wantErr  bool
+	}{
+		{"encrypted with expected key", encrypted, "k1", false},
+		{"encrypted without expectation", encrypted, "", false},
+		{"encrypted with other key", encrypted, "k2", true},
+		// 加密备份的文件被替换为明文
+		{"plaintext for encrypted record", []byte("secret"), "k1", true},
+		{"empty for encrypted record", nil, "k1", true},
+		{"plaintext for plain record", []byte("secret"), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup := func(id string) (*Key, error) {
+				return testKey, nil
+			}
+			reader, err := decrypt(bytes.NewReader(tt.data), tt.expected, lookup)
+			if err == nil {
+				var got []byte
+				got, err = io.ReadAll(reader)
+				if err == nil && string(got) != "secret" {
+					t.Errorf("got %q, want %q", got, "secret")
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
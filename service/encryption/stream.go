@@ -0,0 +1,152 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// 每个加密块的明文长度
+const chunkSize = 64 * 1024
+
+// chunkWriter 分块加密写入器
+// 每块使用独立的nonce：前11字节为块序号，最后1字节标记是否为最后一块，用于发现截断
+type chunkWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	aad     []byte
+	buf     []byte
+	counter uint64
+	closed  bool
+}
+
+// newChunkWriter 创建分块加密写入器，aad为文件头，用于防止文件头被篡改
+func newChunkWriter(w io.Writer, aead cipher.AEAD, aad []byte) *chunkWriter {
+	return &chunkWriter{
+		w:    w,
+		aead: aead,
+		aad:  aad,
+		buf:  make([]byte, 0, chunkSize),
+	}
+}
+
+// Write 写入明文，缓冲满一块后加密输出
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	if c.closed {
+		return 0, fmt.Errorf("write to closed encryption writer")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// 保留最后一块直到Close，以便标记为最后一块
+		if len(c.buf) == chunkSize {
+			if err := c.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(c.buf[len(c.buf):chunkSize], p)
+		c.buf = c.buf[:len(c.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close 加密并输出最后一块，不会关闭底层写入器
+func (c *chunkWriter) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.flush(true)
+}
+
+// flush 加密缓冲区中的明文
+func (c *chunkWriter) flush(final bool) error {
+	sealed := c.aead.Seal(nil, chunkNonce(c.counter, final), c.buf, c.aad)
+	c.counter++
+	c.buf = c.buf[:0]
+
+	_, err := c.w.Write(sealed)
+	return err
+}
+
+// chunkReader 分块解密读取器
+type chunkReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	aad     []byte
+	chunk   []byte
+	plain   []byte
+	counter uint64
+	done    bool
+}
+
+// newChunkReader 创建分块解密读取器
+func newChunkReader(r *bufio.Reader, aead cipher.AEAD, aad []byte) *chunkReader {
+	return &chunkReader{
+		r:     r,
+		aead:  aead,
+		aad:   aad,
+		chunk: make([]byte, chunkSize+aead.Overhead()),
+	}
+}
+
+// Read 读取解密后的明文
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.plain) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, c.plain)
+	c.plain = c.plain[n:]
+	return n, nil
+}
+
+// next 读取并解密下一块
+func (c *chunkReader) next() error {
+	n, err := io.ReadFull(c.r, c.chunk)
+	final := false
+	switch err {
+	case nil:
+		// 满块之后没有更多数据时，该块应为最后一块
+		if _, peekErr := c.r.Peek(1); peekErr == io.EOF {
+			final = true
+		}
+	case io.ErrUnexpectedEOF:
+		final = true
+	case io.EOF:
+		return fmt.Errorf("encrypted backup is truncated")
+	default:
+		return err
+	}
+	if n < c.aead.Overhead() {
+		return fmt.Errorf("encrypted backup is truncated")
+	}
+
+	plain, err := c.aead.Open(c.chunk[:0], chunkNonce(c.counter, final), c.chunk[:n], c.aad)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt backup: wrong key or corrupted data")
+	}
+	c.counter++
+	c.plain = plain
+	c.done = final
+	return nil
+}
+
+// chunkNonce 生成块的nonce
+func chunkNonce(counter uint64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
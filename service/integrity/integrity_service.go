@@ -0,0 +1,308 @@
+package integrity
+
+import (
+	"backup-go/entity"
+	"backup-go/repository"
+	"backup-go/service/chunkstore"
+	"backup-go/service/config"
+	"backup-go/service/storage"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// 校验结果
+const (
+	ResultPassed    = "passed"    // 校验通过
+	ResultCorrupted = "corrupted" // 文件缺失或与校验和不一致
+	ResultSkipped   = "skipped"   // 备份时未记录校验和，无法校验
+)
+
+// IntegrityService 备份文件完整性校验服务
+// 重新读取存储中的备份文件计算SHA-256，与备份时记录的校验和比较
+type IntegrityService struct {
+	cron           *cron.Cron
+	configService  *config.ConfigService
+	recordRepo     *repository.BackupRecordRepository
+	taskRepo       *repository.BackupTaskRepository
+	webhookService *config.WebhookService
+	cronEntryID    cron.EntryID
+	schedule       string // 当前生效的定时校验表达式
+	mutex          sync.Mutex
+	checkMutex     sync.Mutex // 同一时间只执行一次全量校验
+	running        bool
+}
+
+// CheckResult 单条备份记录的校验结果
+type CheckResult struct {
+	RecordID int64  `json:"recordId"` // 备份记录ID
+	Result   string `json:"result"`   // 校验结果：passed、corrupted、skipped
+	Message  string `json:"message"`  // 校验失败或跳过的原因
+}
+
+// SweepResult 全量校验结果
+type SweepResult struct {
+	Passed        int      `json:"passed"`        // 校验通过的记录数
+	Corrupted     int      `json:"corrupted"`     // 校验失败的记录数
+	Skipped       int      `json:"skipped"`       // 未记录校验和而跳过的记录数
+	ErrorMessages []string `json:"errorMessages"` // 读取存储失败等无法完成校验的错误
+}
+
+var (
+	instance *IntegrityService
+	once     sync.Once
+)
+
+// GetIntegrityService 获取完整性校验服务单例
+func GetIntegrityService() *IntegrityService {
+	once.Do(func() {
+		instance = &IntegrityService{
+			cron:           cron.New(cron.WithSeconds()),
+			configService:  config.NewConfigService(),
+			recordRepo:     repository.NewBackupRecordRepository(),
+			taskRepo:       repository.NewBackupTaskRepository(),
+			webhookService: config.NewWebhookService(),
+		}
+	})
+	return instance
+}
+
+// Start 启动完整性校验服务，按系统配置设置定时校验
+func (s *IntegrityService) Start() {
+	s.mutex.Lock()
+	if s.running {
+		s.mutex.Unlock()
+		log.Println("完整性校验服务已经在运行")
+		return
+	}
+	s.cron.Start()
+	s.running = true
+	s.mutex.Unlock()
+
+	if err := s.Reload(); err != nil {
+		log.Printf("设置定时完整性校验失败: %v", err)
+	}
+}
+
+// Stop 停止完整性校验服务
+func (s *IntegrityService) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.running {
+		return
+	}
+
+	ctx := s.cron.Stop()
+	<-ctx.Done()
+	s.running = false
+	log.Println("完整性校验服务已停止")
+}
+
+// Reload 重新读取定时校验配置，配置修改后调用
+func (s *IntegrityService) Reload() error {
+	schedule, _ := s.configService.GetConfigValue("system.integrityCheckSchedule")
+	schedule = strings.TrimSpace(schedule)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if schedule == s.schedule && (schedule == "" || s.cronEntryID != 0) {
+		return nil
+	}
+
+	// 移除原有的定时校验
+	if s.cronEntryID != 0 {
+		s.cron.Remove(s.cronEntryID)
+		s.cronEntryID = 0
+	}
+	s.schedule = schedule
+
+	if schedule == "" {
+		log.Println("未配置完整性校验计划，不执行定时校验")
+		return nil
+	}
+
+	entryID, err := s.cron.AddFunc(schedule, s.sweep)
+	if err != nil {
+		return fmt.Errorf("invalid integrity check schedule %q: %w", schedule, err)
+	}
+	s.cronEntryID = entryID
+	log.Printf("定时完整性校验已设置: %s", schedule)
+	return nil
+}
+
+// ValidateSchedule 检查定时校验表达式，为空表示不定时校验
+func ValidateSchedule(schedule string) error {
+	schedule = strings.TrimSpace(schedule)
+	if schedule == "" {
+		return nil
+	}
+	// 与cron.WithSeconds()使用相同的解析规则
+	parser := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	if _, err := parser.Parse(schedule); err != nil {
+		return fmt.Errorf("invalid integrity check schedule %q: %w", schedule, err)
+	}
+	return nil
+}
+
+// sweep 定时执行的全量校验
+func (s *IntegrityService) sweep() {
+	result, err := s.VerifyAll()
+	if err != nil {
+		log.Printf("完整性校验失败: %v", err)
+		return
+	}
+	for _, errMsg := range result.ErrorMessages {
+		log.Println("完整性校验错误: " + errMsg)
+	}
+}
+
+// VerifyAll 校验所有成功和此前校验失败的备份记录
+func (s *IntegrityService) VerifyAll() (*SweepResult, error) {
+	if !s.checkMutex.TryLock() {
+		return nil, fmt.Errorf("integrity check is already running")
+	}
+	defer s.checkMutex.Unlock()
+
+	log.Println("开始执行完整性校验...")
+	var records []*entity.BackupRecord
+	for _, status := range []entity.BackupStatus{entity.StatusSuccess, entity.StatusCorrupted} {
+		found, err := s.recordRepo.FindByStatus(status)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load backup records: %w", err)
+		}
+		records = append(records, found...)
+	}
+
+	result := &SweepResult{
+		ErrorMessages: []string{},
+	}
+	for _, record := range records {
+		if record.FilePath == "" {
+			continue
+		}
+		check, err := s.verify(record)
+		if err != nil {
+			result.ErrorMessages = append(result.ErrorMessages, fmt.Sprintf("备份记录 %d: %v", record.ID, err))
+			continue
+		}
+		switch check.Result {
+		case ResultPassed:
+			result.Passed++
+		case ResultCorrupted:
+			result.Corrupted++
+		default:
+			result.Skipped++
+		}
+	}
+
+	log.Printf("完整性校验完成。通过: %d, 失败: %d, 跳过: %d", result.Passed, result.Corrupted, result.Skipped)
+	return result, nil
+}
+
+// VerifyRecord 校验指定备份记录
+func (s *IntegrityService) VerifyRecord(id int64) (*CheckResult, error) {
+	record, err := s.recordRepo.FindByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find backup record: %w", err)
+	}
+	if record == nil {
+		return nil, fmt.Errorf("backup record not found")
+	}
+	if (record.Status != entity.StatusSuccess && record.Status != entity.StatusCorrupted) || record.FilePath == "" {
+		return nil, fmt.Errorf("backup record %d has no stored file", record.ID)
+	}
+	return s.verify(record)
+}
+
+// verify 校验备份记录并保存结果，校验失败时标记为已损坏并发送通知
+// 读取存储失败（文件缺失除外）时返回错误，不修改备份记录
+func (s *IntegrityService) verify(record *entity.BackupRecord) (*CheckResult, error) {
+	if record.Checksum == "" {
+		return &CheckResult{RecordID: record.ID, Result: ResultSkipped, Message: "backup has no checksum"}, nil
+	}
+
+	problem, err := s.check(record)
+	if err != nil {
+		return nil, err
+	}
+
+	wasCorrupted := record.Status == entity.StatusCorrupted
+	now := time.Now()
+	record.CheckedAt = &now
+	result := &CheckResult{RecordID: record.ID, Result: ResultPassed}
+	if problem != "" {
+		log.Printf("备份记录 %d 完整性校验失败: %s", record.ID, problem)
+		record.Status = entity.StatusCorrupted
+		record.ErrorMessage = problem
+		result.Result = ResultCorrupted
+		result.Message = problem
+	} else if wasCorrupted {
+		// 文件已修复，恢复为成功状态
+		log.Printf("备份记录 %d 重新通过完整性校验", record.ID)
+		record.Status = entity.StatusSuccess
+		record.ErrorMessage = ""
+	}
+
+	if err := s.recordRepo.UpdateIntegrity(record); err != nil {
+		return nil, fmt.Errorf("failed to update backup record: %w", err)
+	}
+
+	// 新发现的问题尝试发送通知，忽略错误；已标记为损坏的记录不重复通知
+	if problem != "" && !wasCorrupted {
+		taskName := "未知任务"
+		if task, err := s.taskRepo.FindByID(record.TaskID); err == nil && task != nil {
+			taskName = task.Name
+		}
+		_ = s.webhookService.SendIntegrityNotification(taskName, fmt.Sprintf("备份记录%d完整性校验失败: %s", record.ID, problem))
+	}
+	return result, nil
+}
+
+// check 重新读取备份文件并比较校验和，仓库格式的备份还会校验快照引用的数据块
+// 返回发现的问题，没有问题时返回空字符串
+func (s *IntegrityService) check(record *entity.BackupRecord) (string, error) {
+	storageService, err := storage.NewStorageServiceForRecord(record)
+	if err != nil {
+		return "", fmt.Errorf("failed to create storage service: %w", err)
+	}
+
+	checksum, err := storage.Checksum(storageService, record.FilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "backup file is missing: " + record.FilePath, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read backup file: %w", err)
+	}
+	if checksum != record.Checksum {
+		return fmt.Sprintf("checksum mismatch: expected %s, got %s", record.Checksum, checksum), nil
+	}
+
+	if !chunkstore.IsSnapshot(record.FilePath) {
+		return "", nil
+	}
+
+	snapshot, err := chunkstore.LoadSnapshot(record)
+	if err != nil {
+		return "", err
+	}
+	var hashes []string
+	for _, node := range snapshot.Nodes {
+		hashes = append(hashes, node.Chunks...)
+	}
+	bad, err := chunkstore.VerifyChunks(record.StorageType, record.EncryptionKeyID, hashes)
+	if err != nil {
+		return "", err
+	}
+	if len(bad) > 0 {
+		return fmt.Sprintf("%d chunks are missing or corrupted, first: %s", len(bad), bad[0]), nil
+	}
+	return "", nil
+}
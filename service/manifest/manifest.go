@@ -0,0 +1,94 @@
+package manifest
+
+import (
+	"backup-go/entity"
+	"backup-go/service/encryption"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// 清单格式版本
+const version = 1
+
+// Manifest 文件备份的清单，记录备份时所有文件的状态及其内容所在的备份记录
+// 增量和差异备份只归档变化的文件，未变化的文件沿用基准清单中的备份记录
+type Manifest struct {
+	Version  int              `json:"version"`  // 清单格式版本
+	RecordID int64            `json:"recordId"` // 清单所属的备份记录ID
+	Files    map[string]*File `json:"files"`    // 普通文件，按归档中的路径索引
+}
+
+// File 清单中的文件
+type File struct {
+	Size     int64  `json:"size"`     // 文件大小，单位字节
+	ModTime  int64  `json:"modTime"`  // 修改时间，Unix纳秒
+	Hash     string `json:"hash"`     // 内容的SHA-256，十六进制
+	RecordID int64  `json:"recordId"` // 文件内容所在的备份记录ID
+}
+
+// New 创建空清单
+func New(recordID int64) *Manifest {
+	return &Manifest{
+		Version:  version,
+		RecordID: recordID,
+		Files:    make(map[string]*File),
+	}
+}
+
+// Load 读取备份记录的清单
+func Load(record *entity.BackupRecord) (*Manifest, error) {
+	if record.ManifestPath == "" {
+		return nil, fmt.Errorf("backup record %d has no manifest", record.ID)
+	}
+
+	file, err := encryption.OpenFile(record, record.ManifestPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	defer reader.Close()
+
+	var m Manifest
+	if err := json.NewDecoder(reader).Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if m.Version != version {
+		return nil, fmt.Errorf("unsupported manifest version: %d", m.Version)
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]*File)
+	}
+	return &m, nil
+}
+
+// Write 将清单以gzip压缩的JSON写入w
+func (m *Manifest) Write(w io.Writer) error {
+	writer := gzip.NewWriter(w)
+	if err := json.NewEncoder(writer).Encode(m); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return writer.Close()
+}
+
+// RecordIDs 返回清单中文件所在的所有备份记录ID，按升序排列
+func (m *Manifest) RecordIDs() []int64 {
+	seen := make(map[int64]bool)
+	var ids []int64
+	for _, file := range m.Files {
+		if !seen[file.RecordID] {
+			seen[file.RecordID] = true
+			ids = append(ids, file.RecordID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
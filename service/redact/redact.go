@@ -0,0 +1,174 @@
+package redact
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask 替换敏感信息使用的字符串
+const Mask = "******"
+
+// 过短的值替换后容易误伤正常内容，不做替换
+const minSecretLength = 4
+
+// 字段名包含这些关键字时视为敏感信息
+var secretKeys = []string{"password", "secret", "token", "passphrase"}
+
+// 字段名包含这些关键字时，从连接字符串中提取密码
+var uriKeys = []string{"uri", "url", "dsn"}
+
+var (
+	mutex    sync.RWMutex
+	secrets  = make(map[string]struct{})
+	replacer *strings.Replacer
+)
+
+// Register 登记需要隐藏的敏感信息，空值和过短的值会被忽略
+func Register(values ...string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	changed := false
+	for _, value := range values {
+		for _, variant := range []string{value, url.QueryEscape(value), url.PathEscape(value)} {
+			if len(variant) < minSecretLength {
+				continue
+			}
+			if _, exists := secrets[variant]; exists {
+				continue
+			}
+			secrets[variant] = struct{}{}
+			changed = true
+		}
+	}
+	if changed {
+		replacer = newReplacer()
+	}
+}
+
+// RegisterSourceInfo 登记任务源配置（JSON）中的密码、密钥等敏感字段，以及连接字符串中的密码
+func RegisterSourceInfo(sourceInfo string) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(sourceInfo), &value); err != nil {
+		return
+	}
+	var found []string
+	collect(value, &found)
+	Register(found...)
+}
+
+// collect 递归查找敏感字段的值
+func collect(value interface{}, found *[]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if s, ok := item.(string); ok {
+				key = strings.ToLower(key)
+				if containsAny(key, secretKeys) {
+					*found = append(*found, s)
+				} else if containsAny(key, uriKeys) {
+					*found = append(*found, URIPassword(s))
+				}
+				continue
+			}
+			collect(item, found)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collect(item, found)
+		}
+	}
+}
+
+// containsAny 判断字符串是否包含任一关键字
+func containsAny(s string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(s, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// newReplacer 按长度从长到短替换，避免较短的值先替换掉较长值的一部分，调用方需持有锁
+func newReplacer() *strings.Replacer {
+	values := make([]string, 0, len(secrets))
+	for value := range secrets {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+
+	pairs := make([]string, 0, len(values)*2)
+	for _, value := range values {
+		pairs = append(pairs, value, Mask)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// String 隐藏字符串中已登记的敏感信息
+func String(s string) string {
+	mutex.RLock()
+	r := replacer
+	mutex.RUnlock()
+
+	if r == nil || s == "" {
+		return s
+	}
+	return r.Replace(s)
+}
+
+// URIPassword 返回连接字符串中的密码，没有密码时返回空字符串
+func URIPassword(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.User == nil {
+		return ""
+	}
+	password, _ := parsed.User.Password()
+	return password
+}
+
+// URI 隐藏连接字符串中的密码，用户名保留以便排查问题
+func URI(uri string) string {
+	schemeEnd := strings.Index(uri, "://")
+	if schemeEnd < 0 {
+		return uri
+	}
+	// 用户信息只可能出现在第一个"/"之前
+	authority := uri[schemeEnd+3:]
+	if slash := strings.Index(authority, "/"); slash >= 0 {
+		authority = authority[:slash]
+	}
+	at := strings.LastIndex(authority, "@")
+	if at < 0 {
+		return uri
+	}
+	userInfo := authority[:at]
+	if colon := strings.Index(userInfo, ":"); colon >= 0 {
+		userInfo = userInfo[:colon] + ":" + Mask
+	}
+	return uri[:schemeEnd+3] + userInfo + uri[schemeEnd+3+at:]
+}
+
+// writer 写入前隐藏敏感信息的io.Writer
+type writer struct {
+	w io.Writer
+}
+
+// NewWriter 创建写入前隐藏敏感信息的io.Writer，用于日志输出
+func NewWriter(w io.Writer) io.Writer {
+	return &writer{w: w}
+}
+
+// Write 隐藏敏感信息后写入，返回原始长度以免调用方认为写入不完整
+func (r *writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,327 @@
+package restore
+
+import (
+	"archive/tar"
+	"backup-go/entity"
+	"backup-go/service/compression"
+	"backup-go/service/dbclient"
+	"backup-go/service/encryption"
+	"backup-go/service/redact"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 时间点恢复请求中支持的时间格式，按本服务所在服务器的时区解析
+var pointInTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02T15:04"}
+
+// PointInTimeRestoreRequest 时间点恢复请求
+type PointInTimeRestoreRequest struct {
+	TaskID int64                      `json:"taskId"`           // MySQL数据库备份任务ID
+	Time   string                     `json:"time"`             // 恢复到的时间，如"2024-05-01 12:30:00"，不包含该时间之后的事务
+	Target *entity.DatabaseSourceInfo `json:"target,omitempty"` // 恢复目标，为空时恢复到原数据库，非空字段覆盖原任务的配置，不能修改数据库名
+}
+
+// archivedBinlog 已归档的binlog文件及其所在的备份记录
+type archivedBinlog struct {
+	name         string
+	coveredUntil time.Time
+	record       *entity.BackupRecord
+}
+
+// RestoreToTime 导入指定时间之前最近的完整备份，再重放之后归档的binlog直到指定时间，异步执行，返回新建的恢复记录
+func (s *RestoreService) RestoreToTime(req *PointInTimeRestoreRequest) (*entity.RestoreRecord, error) {
+	until, err := parsePointInTime(req.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := s.taskRepo.FindByID(req.TaskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find task: %w", err)
+	}
+	if task == nil {
+		return nil, fmt.Errorf("task not found")
+	}
+	if task.Type != entity.DatabaseBackup {
+		return nil, fmt.Errorf("task %d is not a database backup", task.ID)
+	}
+	source, err := s.taskRepo.ParseDatabaseSourceInfo(task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database source info: %w", err)
+	}
+	if source.Type != "mysql" {
+		return nil, fmt.Errorf("point-in-time restore is only supported for mysql")
+	}
+	redact.RegisterSourceInfo(task.SourceInfo)
+
+	target := mergeDatabaseTarget(source, req.Target)
+	if target.Type != source.Type {
+		return nil, fmt.Errorf("cannot restore a %s backup into %s", source.Type, target.Type)
+	}
+	// binlog中的语句带有原库名，无法改写
+	if target.Database != source.Database {
+		return nil, fmt.Errorf("cannot change database name in a point-in-time restore")
+	}
+
+	base, err := s.recordRepo.FindLatestWithBinlogPosition(task.ID, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find base backup: %w", err)
+	}
+	if base == nil {
+		return nil, fmt.Errorf("no successful backup with a binlog position before %s", until.Format("2006-01-02 15:04:05"))
+	}
+
+	binlogs, err := s.collectBinlogs(task.ID, base, until)
+	if err != nil {
+		return nil, err
+	}
+
+	restoreRecord, err := s.startRestoreRecord(base, describeDatabaseTarget(target)+" @ "+until.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		log.Printf("开始将任务 %d 恢复到 %s，基准备份记录 %d，binlog文件 %d 个", task.ID, until.Format("2006-01-02 15:04:05"), base.ID, len(binlogs))
+		output, err := s.replayDatabase(base, source, target, false)
+		if err == nil {
+			var binlogOutput string
+			binlogOutput, err = s.replayBinlogs(binlogs, base, source, target, until)
+			output = strings.TrimSpace(output + "\n" + binlogOutput)
+		}
+		s.finishRestoreRecord(restoreRecord, task.Name, output, err)
+	}()
+
+	return restoreRecord, nil
+}
+
+// parsePointInTime 解析恢复时间
+func parsePointInTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range pointInTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid restore time %q, expected format 2006-01-02 15:04:05", value)
+}
+
+// collectBinlogs 查找基准完整备份之后连续归档的binlog文件，并确认归档覆盖到恢复时间
+func (s *RestoreService) collectBinlogs(taskID int64, base *entity.BackupRecord, until time.Time) ([]*archivedBinlog, error) {
+	baseSequence, ok := dbclient.BinlogSequence(base.BinlogFile)
+	if !ok {
+		return nil, fmt.Errorf("invalid binlog file in base backup: %s", base.BinlogFile)
+	}
+
+	tasks, err := s.taskRepo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tasks: %w", err)
+	}
+
+	// 同一个数据库可能有多个归档任务，同一个文件只取一份
+	bySequence := make(map[int64]*archivedBinlog)
+	records := make(map[int64]*entity.BackupRecord)
+	for _, task := range tasks {
+		if task.Type != entity.BinlogBackup {
+			continue
+		}
+		sourceInfo, err := s.taskRepo.ParseBinlogSourceInfo(task)
+		if err != nil || sourceInfo.DatabaseTaskID != taskID {
+			continue
+		}
+
+		files, err := s.binlogRepo.FindByTaskID(task.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load archived binary logs: %w", err)
+		}
+		for _, file := range files {
+			sequence, ok := dbclient.BinlogSequence(file.Name)
+			if !ok || sequence < baseSequence || bySequence[sequence] != nil {
+				continue
+			}
+			record, checked := records[file.RecordID]
+			if !checked {
+				record, err = s.recordRepo.FindByID(file.RecordID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to find backup record: %w", err)
+				}
+				records[file.RecordID] = record
+			}
+			if record == nil || record.Status != entity.StatusSuccess {
+				continue
+			}
+			bySequence[sequence] = &archivedBinlog{name: file.Name, coveredUntil: file.CoveredUntil, record: record}
+		}
+	}
+
+	// 从基准备份所在的文件开始，binlog必须连续
+	var binlogs []*archivedBinlog
+	var archivedUntil time.Time
+	sequence := baseSequence
+	for ; bySequence[sequence] != nil; sequence++ {
+		binlog := bySequence[sequence]
+		binlogs = append(binlogs, binlog)
+		// 正在写入的文件不会归档，只能恢复到最后一个已归档文件切换的时间；早期归档的文件没有记录该时间，按未覆盖处理
+		if binlog.coveredUntil.Unix() > 0 && binlog.coveredUntil.After(archivedUntil) {
+			archivedUntil = binlog.coveredUntil
+		}
+	}
+	if len(binlogs) == 0 {
+		return nil, fmt.Errorf("no archived binary logs after the base backup (%s)", base.BinlogFile)
+	}
+	for other := range bySequence {
+		if other > sequence {
+			return nil, fmt.Errorf("binary log #%d is missing from the archive, cannot replay past it", sequence)
+		}
+	}
+
+	if archivedUntil.IsZero() {
+		return nil, fmt.Errorf("archived binary logs have no recorded end time, archive new binary logs first")
+	}
+	if until.After(archivedUntil) {
+		return nil, fmt.Errorf("binary logs are only archived up to %s", archivedUntil.Format("2006-01-02 15:04:05"))
+	}
+	return binlogs, nil
+}
+
+// replayBinlogs 从归档中取出binlog文件，使用mysqlbinlog从基准备份的位置重放到指定时间，返回命令输出
+func (s *RestoreService) replayBinlogs(binlogs []*archivedBinlog, base *entity.BackupRecord, source, target *entity.DatabaseSourceInfo, until time.Time) (string, error) {
+	// mysqlbinlog需要读取本地文件
+	tempDir, err := ioutil.TempDir("", "binlog_restore")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := s.extractBinlogs(binlogs, tempDir); err != nil {
+		return "", err
+	}
+
+	// --start-position只作用于第一个文件
+	args := []string{
+		fmt.Sprintf("--start-position=%d", base.BinlogPosition),
+		"--stop-datetime=" + until.Format("2006-01-02 15:04:05"),
+	}
+	if !isAllDatabases(source.Database) {
+		args = append(args, "--database="+source.Database)
+	}
+	// 恢复到原服务器时，binlog中事务的GTID已经执行过，不跳过GTID会被忽略；MariaDB的工具不输出GTID，没有该参数
+	tool := dbclient.DetectMySQLBinlog(dbclient.MySQLFlavor(source))
+	log.Printf("重放binlog使用的工具: %s", tool)
+	if tool.Flavor == dbclient.FlavorMySQL {
+		args = append(args, "--skip-gtids")
+	}
+	for _, binlog := range binlogs {
+		args = append(args, filepath.Join(tempDir, binlog.name))
+	}
+
+	output := newTailBuffer(maxOutputSize)
+	binlogCmd := exec.Command(tool.Program, args...)
+	binlogCmd.Stderr = output
+	pipe, err := binlogCmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+
+	mysqlCmd := buildMySQLRestoreCommand(target)
+	mysqlCmd.Stdin = pipe
+	mysqlCmd.Stdout = output
+	mysqlCmd.Stderr = output
+
+	if err := binlogCmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start %s: %w", tool.Program, err)
+	}
+	mysqlErr := mysqlCmd.Run()
+	// mysql提前退出时关闭读取端，使mysqlbinlog不再阻塞在写入上
+	pipe.Close()
+	binlogErr := binlogCmd.Wait()
+
+	if mysqlErr != nil {
+		return output.String(), fmt.Errorf("replaying binary logs failed: %w", mysqlErr)
+	}
+	if binlogErr != nil {
+		return output.String(), fmt.Errorf("%s failed: %w", tool.Program, binlogErr)
+	}
+	return output.String(), nil
+}
+
+// extractBinlogs 从各个归档备份中取出需要的binlog文件到目录中
+func (s *RestoreService) extractBinlogs(binlogs []*archivedBinlog, dir string) error {
+	wanted := make(map[int64]map[string]bool)
+	var records []*entity.BackupRecord
+	for _, binlog := range binlogs {
+		if wanted[binlog.record.ID] == nil {
+			wanted[binlog.record.ID] = make(map[string]bool)
+			records = append(records, binlog.record)
+		}
+		wanted[binlog.record.ID][binlog.name] = true
+	}
+
+	for _, record := range records {
+		if err := s.extractBinlogArchive(record, wanted[record.ID], dir); err != nil {
+			return fmt.Errorf("failed to extract binary logs from backup record %d: %w", record.ID, err)
+		}
+	}
+	return nil
+}
+
+// extractBinlogArchive 从一个归档备份中取出指定的binlog文件
+func (s *RestoreService) extractBinlogArchive(record *entity.BackupRecord, names map[string]bool, dir string) error {
+	file, err := s.openBackupFile(record)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader, err := compression.NewReader(file, compression.Detect(encryption.PlainName(record.FilePath)))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	found := 0
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// 归档中只有文件名，不接受带路径的条目
+		name := header.Name
+		if !names[name] || filepath.Base(name) != name {
+			continue
+		}
+		if err := writeFile(filepath.Join(dir, name), tarReader); err != nil {
+			return err
+		}
+		found++
+	}
+
+	if found != len(names) {
+		return fmt.Errorf("archive contains %d of %d expected binary logs", found, len(names))
+	}
+	return nil
+}
+
+// writeFile 将内容写入新文件
+func writeFile(path string, content io.Reader) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,201 @@
+package restore
+
+import (
+	"backup-go/config"
+	"backup-go/entity"
+	"backup-go/repository"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupDB 在临时目录中创建SQLite数据库
+func setupDB(t *testing.T) {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+
+	if err := config.LoadConfig("config.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.InitDB(); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.MigrateDB(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testArchive 一次binlog归档：归档的文件及其覆盖到的时间（分钟），0表示早期未记录时间的归档
+type testArchive struct {
+	task   int // 第几个归档任务
+	status entity.BackupStatus
+	files  map[string]int
+}
+
+func TestCollectBinlogs(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	minute := func(n int) time.Time {
+		return start.Add(time.Duration(n) * time.Minute)
+	}
+
+	tests := []struct {
+		name     string
+		archives []testArchive
+		until    int
+		want     []string
+		wantErr  string
+	}{
+		{
+			name: "within coverage",
+			archives: []testArchive{
+				{0, entity.StatusSuccess, map[string]int{"binlog.000001": 10, "binlog.000002": 20}},
+			},
+			until: 15,
+			want:  []string{"binlog.000001", "binlog.000002"},
+		},
+		{
+			// 正在写入的文件没有归档，归档执行时间之前、最后一次切换之后的事件不可恢复
+			name: "after last rotation",
+			archives: []testArchive{
+				{0, entity.StatusSuccess, map[string]int{"binlog.000001": 10, "binlog.000002": 20}},
+			},
+			until:   25,
+			wantErr: "only archived up to",
+		},
+		{
+			// 两个归档任务都归档了binlog.000002，只取一份
+			name: "duplicates across archive tasks",
+			archives: []testArchive{
+				{0, entity.StatusSuccess, map[string]int{"binlog.000001": 10, "binlog.000002": 20}},
+				{1, entity.StatusSuccess, map[string]int{"binlog.000002": 20, "binlog.000003": 30}},
+			},
+			until: 30,
+			want:  []string{"binlog.000001", "binlog.000002", "binlog.000003"},
+		},
+		{
+			name: "gap in sequence",
+			archives: []testArchive{
+				{0, entity.StatusSuccess, map[string]int{"binlog.000001": 10}},
+				{0, entity.StatusSuccess, map[string]int{"binlog.000003": 30}},
+			},
+			until:   5,
+			wantErr: "binary log #2 is missing",
+		},
+		{
+			// 失败或已清理的归档中的文件不可用
+			name: "failed archive",
+			archives: []testArchive{
+				{0, entity.StatusSuccess, map[string]int{"binlog.000001": 10}},
+				{0, entity.StatusFailed, map[string]int{"binlog.000002": 20}},
+				{0, entity.StatusSuccess, map[string]int{"binlog.000003": 30}},
+			},
+			until:   5,
+			wantErr: "binary log #2 is missing",
+		},
+		{
+			name: "files before base ignored",
+			archives: []testArchive{
+				{0, entity.StatusSuccess, map[string]int{"binlog.000000": 5, "binlog.000001": 10}},
+			},
+			until: 10,
+			want:  []string{"binlog.000001"},
+		},
+		{
+			name: "no coverage recorded",
+			archives: []testArchive{
+				{0, entity.StatusSuccess, map[string]int{"binlog.000001": 0, "binlog.000002": 0}},
+			},
+			until:   5,
+			wantErr: "no recorded end time",
+		},
+		{
+			// 早期归档的文件按未覆盖处理，只能恢复到之后记录了时间的文件
+			name: "mixed coverage",
+			archives: []testArchive{
+				{0, entity.StatusSuccess, map[string]int{"binlog.000001": 0}},
+				{0, entity.StatusSuccess, map[string]int{"binlog.000002": 20}},
+				{0, entity.StatusSuccess, map[string]int{"binlog.000003": 0}},
+			},
+			until: 20,
+			want:  []string{"binlog.000001", "binlog.000002", "binlog.000003"},
+		},
+		{
+			name:    "nothing archived",
+			until:   5,
+			wantErr: "no archived binary logs",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDB(t)
+			taskRepo := repository.NewBackupTaskRepository()
+			recordRepo := repository.NewBackupRecordRepository()
+			binlogRepo := repository.NewBinlogFileRepository()
+
+			dbTask := &entity.BackupTask{Name: "mysql", Type: entity.DatabaseBackup, SourceInfo: `{"type":"mysql"}`, Schedule: "0 0 2 * * *"}
+			if err := taskRepo.Create(dbTask); err != nil {
+				t.Fatal(err)
+			}
+			var archiveTasks []*entity.BackupTask
+			for i := 0; i < 2; i++ {
+				task := &entity.BackupTask{
+					Name:       fmt.Sprintf("binlog-%d", i),
+					Type:       entity.BinlogBackup,
+					SourceInfo: fmt.Sprintf(`{"databaseTaskId":%d}`, dbTask.ID),
+					Schedule:   "0 */15 * * * *",
+				}
+				if err := taskRepo.Create(task); err != nil {
+					t.Fatal(err)
+				}
+				archiveTasks = append(archiveTasks, task)
+			}
+
+			for _, archive := range tt.archives {
+				task := archiveTasks[archive.task]
+				record := &entity.BackupRecord{TaskID: task.ID, Status: archive.status, StartTime: minute(60), FilePath: "binlog.tar"}
+				if err := recordRepo.Create(record); err != nil {
+					t.Fatal(err)
+				}
+				var files []*entity.BinlogFile
+				for name, coveredUntil := range archive.files {
+					file := &entity.BinlogFile{TaskID: task.ID, RecordID: record.ID, Name: name}
+					if coveredUntil > 0 {
+						file.CoveredUntil = minute(coveredUntil)
+					}
+					files = append(files, file)
+				}
+				if err := binlogRepo.CreateBatch(files); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			base := &entity.BackupRecord{TaskID: dbTask.ID, BinlogFile: "binlog.000001", BinlogPosition: 4}
+			binlogs, err := NewRestoreService().collectBinlogs(dbTask.ID, base, minute(tt.until))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, binlog := range binlogs {
+				got = append(got, binlog.name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package restore
+
+import (
+	"backup-go/entity"
+	"backup-go/service/compression"
+	"backup-go/service/dbclient"
+	"backup-go/service/encryption"
+	"backup-go/service/redact"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DatabaseRestoreRequest 数据库恢复请求
+type DatabaseRestoreRequest struct {
+	BackupRecordID int64                      `json:"id"`               // 备份记录ID
+	Target         *entity.DatabaseSourceInfo `json:"target,omitempty"` // 恢复目标，为空时恢复到原数据库，非空字段覆盖原任务的配置
+	Clean          bool                       `json:"clean"`            // 恢复前删除目标中已存在的对象（pg_restore --clean、mongorestore --drop），PostgreSQL的plain格式备份不支持
+}
+
+// RestoreDatabase 将数据库备份异步恢复到目标数据库，返回新建的恢复记录
+func (s *RestoreService) RestoreDatabase(req *DatabaseRestoreRequest) (*entity.RestoreRecord, error) {
+	record, task, err := s.loadBackup(req.BackupRecordID)
+	if err != nil {
+		return nil, err
+	}
+	if task.Type != entity.DatabaseBackup {
+		return nil, fmt.Errorf("backup record %d is not a database backup", record.ID)
+	}
+
+	source, err := s.taskRepo.ParseDatabaseSourceInfo(task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database source info: %w", err)
+	}
+
+	target := mergeDatabaseTarget(source, req.Target)
+	if target.Type != source.Type {
+		return nil, fmt.Errorf("cannot restore a %s backup into %s", source.Type, target.Type)
+	}
+	// plain格式的SQL不包含DROP语句，psql无法先删除已存在的对象
+	if req.Clean && source.Type == "postgres" && !isPostgresCustomDump(record.FilePath) {
+		return nil, fmt.Errorf("clean restore is not supported for plain-format postgres backups, drop and recreate the target database first or restore into an empty database")
+	}
+
+	restoreRecord, err := s.startRestoreRecord(record, describeDatabaseTarget(target))
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		log.Printf("开始恢复备份记录 %d 到 %s", record.ID, restoreRecord.Target)
+		output, err := s.replayDatabase(record, source, target, req.Clean)
+		s.finishRestoreRecord(restoreRecord, task.Name, output, err)
+	}()
+
+	return restoreRecord, nil
+}
+
+// replayDatabase 从存储读取备份文件并导入目标数据库，返回命令输出
+func (s *RestoreService) replayDatabase(record *entity.BackupRecord, source, target *entity.DatabaseSourceInfo, clean bool) (string, error) {
+	file, err := s.openBackupFile(record)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	// mongodump的归档自带gzip压缩，其他类型按扩展名解压
+	var input io.Reader = file
+	plainName := encryption.PlainName(record.FilePath)
+	if source.Type != "mongodb" {
+		reader, err := compression.NewReader(file, compression.Detect(plainName))
+		if err != nil {
+			return "", err
+		}
+		defer reader.Close()
+		input = reader
+	}
+
+	var cmd *exec.Cmd
+
+	switch source.Type {
+	case "mysql":
+		if target.Database != source.Database {
+			if isAllDatabases(source.Database) || isAllDatabases(target.Database) {
+				return "", fmt.Errorf("cannot change database name when restoring an all-databases dump")
+			}
+			// mysqldump --databases 会在文件中写入CREATE DATABASE和USE语句，需要改写为目标库
+			input = newMySQLDatabaseRewriter(input, source.Database, target.Database)
+		}
+		cmd = buildMySQLRestoreCommand(target)
+	case "postgres":
+		cmd = buildPostgresRestoreCommand(target, isPostgresCustomDump(record.FilePath), clean)
+	case "mongodb":
+		var cleanup func()
+		cmd, cleanup, err = buildMongoRestoreCommand(source, target, clean)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+	default:
+		return "", fmt.Errorf("unsupported database type: %s", source.Type)
+	}
+
+	output := newTailBuffer(maxOutputSize)
+	cmd.Stdin = input
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	if err := cmd.Run(); err != nil {
+		return output.String(), fmt.Errorf("restore command failed: %w", err)
+	}
+	return output.String(), nil
+}
+
+// buildMySQLRestoreCommand 构造mysql导入命令，从标准输入读取SQL
+func buildMySQLRestoreCommand(target *entity.DatabaseSourceInfo) *exec.Cmd {
+	client := dbclient.DetectMySQLClient(dbclient.MySQLFlavor(target))
+	args := mysqlClientArgs(target, client)
+	if !isAllDatabases(target.Database) {
+		args = append(args, target.Database)
+	}
+
+	cmd := exec.Command(client.Program, args...)
+	// 通过环境变量传递密码，避免出现在命令行中
+	cmd.Env = append(os.Environ(), "MYSQL_PWD="+target.Password)
+	return cmd
+}
+
+// mysqlClientArgs 生成mysql客户端的连接参数，TLS参数与备份时使用相同的任务配置，按客户端类型和版本选择
+func mysqlClientArgs(target *entity.DatabaseSourceInfo, client *dbclient.MySQLTool) []string {
+	args := []string{
+		"-h" + target.Host,
+		"-P" + fmt.Sprintf("%d", target.Port),
+		"-u" + target.User,
+	}
+	return append(args, dbclient.MySQLSSLArgs(target.MySQL, client)...)
+}
+
+// isPostgresCustomDump 判断PostgreSQL备份是否为pg_dump的custom格式（.dump），否则为plain格式的SQL
+func isPostgresCustomDump(filePath string) bool {
+	return strings.HasSuffix(compression.TrimExtension(encryption.PlainName(filePath)), ".dump")
+}
+
+// buildPostgresRestoreCommand 构造PostgreSQL导入命令：custom格式使用pg_restore，plain格式使用psql
+func buildPostgresRestoreCommand(target *entity.DatabaseSourceInfo, custom bool, clean bool) *exec.Cmd {
+	port := target.Port
+	if port == 0 {
+		port = 5432
+	}
+
+	// 全库备份由pg_dumpall生成，需要先连接到默认数据库
+	database := target.Database
+	if isAllDatabases(database) {
+		database = "postgres"
+	}
+
+	args := []string{
+		"-h", target.Host,
+		"-p", fmt.Sprintf("%d", port),
+		"-U", target.User,
+		"-w",
+		"-d", database,
+	}
+
+	program := "psql"
+	if custom {
+		program = "pg_restore"
+		if clean {
+			args = append(args, "--clean", "--if-exists")
+		}
+	} else {
+		// 出错时立即停止，避免部分导入后仍返回成功
+		args = append(args, "-v", "ON_ERROR_STOP=1", "-f", "-")
+	}
+
+	cmd := exec.Command(program, args...)
+	// 通过环境变量传递密码，避免出现在命令行中
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+target.Password)
+	return cmd
+}
+
+// buildMongoRestoreCommand 构造mongorestore命令，从标准输入读取归档
+// 返回的cleanup在命令结束后调用，删除保存密码的临时配置文件
+func buildMongoRestoreCommand(source, target *entity.DatabaseSourceInfo, clean bool) (*exec.Cmd, func(), error) {
+	args, cleanup, err := dbclient.MongoToolArgs(target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	args = append(args, "--archive", "--gzip")
+	if clean {
+		args = append(args, "--drop")
+	}
+
+	// 单库备份恢复到不同名称的数据库时重命名命名空间
+	if !isAllDatabases(source.Database) {
+		if !isAllDatabases(target.Database) && target.Database != source.Database {
+			args = append(args, "--nsFrom="+source.Database+".*", "--nsTo="+target.Database+".*")
+		} else {
+			args = append(args, "--nsInclude="+source.Database+".*")
+		}
+	}
+
+	return exec.Command("mongorestore", args...), cleanup, nil
+}
+
+// mergeDatabaseTarget 以原任务配置为基础，用覆盖配置中的非空字段生成恢复目标
+func mergeDatabaseTarget(source, override *entity.DatabaseSourceInfo) *entity.DatabaseSourceInfo {
+	target := *source
+	if override == nil {
+		return &target
+	}
+
+	if override.Type != "" {
+		target.Type = override.Type
+	}
+	if override.Host != "" {
+		target.Host = override.Host
+	}
+	if override.Port != 0 {
+		target.Port = override.Port
+	}
+	if override.User != "" {
+		target.User = override.User
+	}
+	if override.Password != "" {
+		target.Password = override.Password
+	}
+	if override.Database != "" {
+		target.Database = override.Database
+	}
+	if override.URI != "" {
+		target.URI = override.URI
+	}
+	if override.AuthSource != "" {
+		target.AuthSource = override.AuthSource
+	}
+	// 覆盖配置中的密码不在任务配置中，同样需要在日志和错误信息中隐藏
+	redact.Register(target.Password, redact.URIPassword(target.URI))
+	return &target
+}
+
+// describeDatabaseTarget 生成不含密码的恢复目标描述
+func describeDatabaseTarget(target *entity.DatabaseSourceInfo) string {
+	database := target.Database
+	if isAllDatabases(database) {
+		database = "all"
+	}
+	if target.URI != "" {
+		return fmt.Sprintf("%s (connection string)/%s", target.Type, database)
+	}
+	return fmt.Sprintf("%s://%s@%s:%d/%s", target.Type, target.User, target.Host, target.Port, database)
+}
+
+// isAllDatabases 判断是否表示全部数据库
+func isAllDatabases(database string) bool {
+	return database == "" || database == "all"
+}
+
+// mysqlDatabaseRewriter 将mysqldump输出中的CREATE DATABASE和USE语句改写为目标数据库
+type mysqlDatabaseRewriter struct {
+	reader  *bufio.Reader
+	from    []byte
+	to      []byte
+	pending []byte
+	midLine bool
+	err     error
+}
+
+// newMySQLDatabaseRewriter 创建数据库名改写器
+func newMySQLDatabaseRewriter(r io.Reader, from, to string) *mysqlDatabaseRewriter {
+	return &mysqlDatabaseRewriter{
+		reader: bufio.NewReaderSize(r, 64*1024),
+		from:   []byte("`" + strings.ReplaceAll(from, "`", "``") + "`"),
+		to:     []byte("`" + strings.ReplaceAll(to, "`", "``") + "`"),
+	}
+}
+
+// Read 逐行读取，仅改写行首为CREATE DATABASE或USE的语句，其余内容原样输出
+func (r *mysqlDatabaseRewriter) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		line, err := r.reader.ReadSlice('\n')
+		switch err {
+		case nil, io.EOF:
+			if !r.midLine && (bytes.HasPrefix(line, []byte("CREATE DATABASE")) || bytes.HasPrefix(line, []byte("USE "))) {
+				line = bytes.ReplaceAll(line, r.from, r.to)
+			}
+			r.midLine = false
+			r.err = err
+		case bufio.ErrBufferFull:
+			// 超长的行（通常是INSERT语句）直接透传
+			r.midLine = true
+		default:
+			r.err = err
+		}
+		r.pending = line
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,143 @@
+package restore
+
+import (
+	"backup-go/entity"
+	"backup-go/repository"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestMySQLDatabaseRewriter(t *testing.T) {
+	longValues := strings.Repeat("x", 64*1024)
+
+	tests := []struct {
+		name  string
+		from  string
+		to    string
+		input string
+		want  string
+	}{
+		{
+			name:  "create and use",
+			from:  "shop",
+			to:    "shop_restore",
+			input: "CREATE DATABASE /*!32312 IF NOT EXISTS*/ `shop` /*!40100 DEFAULT CHARACTER SET utf8mb4 */;\nUSE `shop`;\n",
+			want:  "CREATE DATABASE /*!32312 IF NOT EXISTS*/ `shop_restore` /*!40100 DEFAULT CHARACTER SET utf8mb4 */;\nUSE `shop_restore`;\n",
+		},
+		{
+			// 数据和其他语句中出现的库名不改写
+			name:  "other statements unchanged",
+			from:  "shop",
+			to:    "copy",
+			input: "-- Current Database: `shop`\nINSERT INTO `t` VALUES ('USE `shop`');\n  USE `shop`;\n",
+			want:  "-- Current Database: `shop`\nINSERT INTO `t` VALUES ('USE `shop`');\n  USE `shop`;\n",
+		},
+		{
+			name:  "other database unchanged",
+			from:  "shop",
+			to:    "copy",
+			input: "USE `shop_archive`;\nUSE `shop`;\n",
+			want:  "USE `shop_archive`;\nUSE `copy`;\n",
+		},
+		{
+			name:  "backtick in name",
+			from:  "we`ird",
+			to:    "new`name",
+			input: "USE `we``ird`;\n",
+			want:  "USE `new``name`;\n",
+		},
+		{
+			name:  "last line without newline",
+			from:  "shop",
+			to:    "copy",
+			input: "SELECT 1;\nUSE `shop`;",
+			want:  "SELECT 1;\nUSE `copy`;",
+		},
+		{
+			// 超长行在缓冲区边界处的续行不是行首，不能改写
+			name:  "long line continuation",
+			from:  "shop",
+			to:    "copy",
+			input: longValues + "USE `shop`;\nUSE `shop`;\n",
+			want:  longValues + "USE `shop`;\nUSE `copy`;\n",
+		},
+		{
+			name:  "empty",
+			from:  "shop",
+			to:    "copy",
+			input: "",
+			want:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, small := range []bool{false, true} {
+				var r io.Reader = newMySQLDatabaseRewriter(strings.NewReader(tt.input), tt.from, tt.to)
+				if small {
+					// 调用方每次只读取少量数据时，剩余内容应保留到下次读取
+					r = iotest.OneByteReader(r)
+				}
+				got, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != tt.want {
+					t.Errorf("small reads %v: got %q, want %q", small, truncate(string(got)), truncate(tt.want))
+				}
+			}
+		})
+	}
+}
+
+// truncate 截断过长的输出，便于阅读失败信息
+func truncate(s string) string {
+	if len(s) > 200 {
+		return s[:100] + "..." + s[len(s)-100:]
+	}
+	return s
+}
+
+func TestIsPostgresCustomDump(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"20240101/task_1_db_20240101020000.dump", true},
+		{"20240101/task_1_db_20240101020000.dump.enc", true},
+		{"20240101/task_1_db_20240101020000.dump.zst", true},
+		{"20240101/task_1_db_20240101020000.sql", false},
+		{"20240101/task_1_db_20240101020000.sql.gz", false},
+		{"20240101/task_1_db_20240101020000.sql.gz.enc", false},
+	}
+	for _, tt := range tests {
+		if got := isPostgresCustomDump(tt.path); got != tt.want {
+			t.Errorf("isPostgresCustomDump(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRestoreDatabaseRejectsCleanForPlainPostgres(t *testing.T) {
+	setupDB(t)
+	task := &entity.BackupTask{Name: "pg", Type: entity.DatabaseBackup, SourceInfo: `{"type":"postgres","host":"localhost","database":"app"}`, Schedule: "0 0 2 * * *"}
+	if err := repository.NewBackupTaskRepository().Create(task); err != nil {
+		t.Fatal(err)
+	}
+	record := &entity.BackupRecord{TaskID: task.ID, Status: entity.StatusSuccess, FilePath: "20240101/task_1_pg_20240101020000.sql.gz"}
+	if err := repository.NewBackupRecordRepository().Create(record); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewRestoreService().RestoreDatabase(&DatabaseRestoreRequest{BackupRecordID: record.ID, Clean: true})
+	if err == nil || !strings.Contains(err.Error(), "clean restore is not supported") {
+		t.Fatalf("err = %v, want clean restore rejected", err)
+	}
+	count, err := repository.NewRestoreRecordRepository().CountAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("%d restore records created for a rejected request", count)
+	}
+}
@@ -0,0 +1,457 @@
+package restore
+
+import (
+	"backup-go/entity"
+	"backup-go/service/archive"
+	"backup-go/service/manifest"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 文件冲突处理方式
+const (
+	ConflictOverwrite = "overwrite" // 覆盖已存在的文件
+	ConflictSkip      = "skip"      // 跳过已存在的文件
+	ConflictKeepBoth  = "keepBoth"  // 保留已存在的文件，恢复的文件重命名
+)
+
+// 单个文件的恢复结果
+const (
+	ActionRestored    = "restored"    // 已恢复
+	ActionOverwritten = "overwritten" // 已覆盖
+	ActionSkipped     = "skipped"     // 已跳过
+	ActionRenamed     = "renamed"     // 已重命名后恢复
+	ActionFailed      = "failed"      // 失败
+)
+
+// FileRestoreRequest 文件恢复请求
+type FileRestoreRequest struct {
+	BackupRecordID int64    `json:"id"`           // 备份记录ID
+	Original       bool     `json:"original"`     // 是否恢复到备份时的原始路径
+	TargetDir      string   `json:"targetDir"`    // 沙箱目录，不恢复到原始路径时必填
+	ConflictMode   string   `json:"conflictMode"` // 冲突处理方式：overwrite、skip、keepBoth，默认skip
+	Paths          []string `json:"paths"`        // 只恢复指定的条目（文件或目录），为空时恢复全部
+}
+
+// FileRestoreResult 单个文件的恢复结果
+type FileRestoreResult struct {
+	Path   string `json:"path"`            // 归档中的路径
+	Target string `json:"target"`          // 实际写入的路径
+	Action string `json:"action"`          // 处理结果
+	Error  string `json:"error,omitempty"` // 错误信息
+}
+
+// FileRestoreResponse 文件恢复结果
+type FileRestoreResponse struct {
+	Record   *entity.RestoreRecord `json:"record"`   // 恢复记录
+	Results  []*FileRestoreResult  `json:"results"`  // 每个文件的结果
+	Restored int                   `json:"restored"` // 成功恢复的文件数（含覆盖和重命名）
+	Skipped  int                   `json:"skipped"`  // 跳过的文件数
+	Failed   int                   `json:"failed"`   // 失败的文件数
+}
+
+// RestoreFiles 将文件备份解压到原始路径或沙箱目录，同步返回每个文件的结果
+func (s *RestoreService) RestoreFiles(req *FileRestoreRequest) (*FileRestoreResponse, error) {
+	record, task, err := s.loadBackup(req.BackupRecordID)
+	if err != nil {
+		return nil, err
+	}
+	if task.Type != entity.FileBackup {
+		return nil, fmt.Errorf("backup record %d is not a file backup", record.ID)
+	}
+
+	conflictMode := req.ConflictMode
+	if conflictMode == "" {
+		conflictMode = ConflictSkip
+	}
+	if conflictMode != ConflictOverwrite && conflictMode != ConflictSkip && conflictMode != ConflictKeepBoth {
+		return nil, fmt.Errorf("unsupported conflict mode: %s", req.ConflictMode)
+	}
+
+	// 确定条目到目标路径的映射方式
+	var resolve func(entryPath string) (string, error)
+	var target string
+	if req.Original {
+		sourceInfo, err := s.taskRepo.ParseFileSourceInfo(task)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file source info: %w", err)
+		}
+		resolve, err = originalPathResolver(sourceInfo.Paths)
+		if err != nil {
+			return nil, err
+		}
+		target = "original paths"
+	} else {
+		if req.TargetDir == "" {
+			return nil, fmt.Errorf("targetDir is required when not restoring to original paths")
+		}
+		root, err := filepath.Abs(req.TargetDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target directory: %w", err)
+		}
+		resolve = func(entryPath string) (string, error) {
+			return archive.SafeJoin(root, entryPath)
+		}
+		target = root
+	}
+
+	// 规范化需要恢复的条目
+	var filters []string
+	for _, p := range req.Paths {
+		cleaned, ok := archive.CleanPath(strings.TrimSuffix(p, "/"))
+		if !ok {
+			return nil, fmt.Errorf("invalid path: %s", p)
+		}
+		filters = append(filters, cleaned)
+	}
+
+	restoreRecord, err := s.startRestoreRecord(record, target)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &FileRestoreResponse{Record: restoreRecord, Results: []*FileRestoreResult{}}
+	restoreErr := s.extractArchive(record, filters, resolve, conflictMode, response)
+	if restoreErr == nil && response.Failed > 0 {
+		restoreErr = fmt.Errorf("%d files failed to restore", response.Failed)
+	}
+
+	output := fmt.Sprintf("restored: %d, skipped: %d, failed: %d", response.Restored, response.Skipped, response.Failed)
+	for _, result := range response.Results {
+		if result.Action == ActionFailed {
+			output += fmt.Sprintf("\n%s: %s", result.Path, result.Error)
+		}
+	}
+	if len(output) > maxOutputSize {
+		output = output[:maxOutputSize] + "\n..."
+	}
+	s.finishRestoreRecord(restoreRecord, task.Name, output, restoreErr)
+
+	return response, nil
+}
+
+// extractArchive 遍历归档并逐个恢复符合条件的条目
+// 增量和差异备份只包含变化的文件，未变化的文件按清单从基准链中的备份恢复
+func (s *RestoreService) extractArchive(record *entity.BackupRecord, filters []string, resolve func(string) (string, error), conflictMode string, response *FileRestoreResponse) error {
+	// 读取清单并确认基准链中的备份都可用
+	var m *manifest.Manifest
+	var chain []*entity.BackupRecord
+	if record.ManifestPath != "" {
+		var err error
+		m, err = manifest.Load(record)
+		if err != nil {
+			return err
+		}
+		for _, id := range m.RecordIDs() {
+			if id == record.ID {
+				continue
+			}
+			base, err := s.recordRepo.FindByID(id)
+			if err != nil {
+				return fmt.Errorf("failed to find backup record %d: %w", id, err)
+			}
+			if base == nil || base.Status != entity.StatusSuccess || base.FilePath == "" {
+				return fmt.Errorf("backup record %d required by the backup chain is not available", id)
+			}
+			chain = append(chain, base)
+		}
+	}
+
+	// 符号链接在所有文件恢复后再创建，避免后续条目经由链接写到目标目录之外
+	// 新建目录的权限最后设置，避免只读目录导致其中的文件无法写入
+	var links, dirs []archive.Entry
+	var dirTargets []string
+
+	// 目录和符号链接只从当前备份恢复，基准链中的备份只提供清单指向它的文件
+	walk := func(current *entity.BackupRecord) error {
+		arc, err := archive.Open(current)
+		if err != nil {
+			return err
+		}
+		defer arc.Close()
+
+		return arc.Walk(func(entry archive.Entry, open func() (io.ReadCloser, error)) error {
+			if !matchesFilters(entry.Path, filters) {
+				return nil
+			}
+			if current != record {
+				if entry.IsDir || entry.Linkname != "" {
+					return nil
+				}
+				if file, ok := m.Files[entry.Path]; !ok || file.RecordID != current.ID {
+					return nil
+				}
+			}
+
+			targetPath, err := resolve(entry.Path)
+			if err != nil {
+				if !entry.IsDir {
+					response.Results = append(response.Results, &FileRestoreResult{Path: entry.Path, Action: ActionFailed, Error: err.Error()})
+					response.Failed++
+				}
+				return nil
+			}
+
+			// 目录只需创建，不计入结果
+			if entry.IsDir {
+				if _, err := os.Lstat(targetPath); os.IsNotExist(err) {
+					dirs = append(dirs, entry)
+					dirTargets = append(dirTargets, targetPath)
+				}
+				if err := os.MkdirAll(targetPath, 0755); err != nil {
+					log.Printf("创建目录失败: %s, 错误: %v", targetPath, err)
+				}
+				return nil
+			}
+
+			if entry.Linkname != "" {
+				links = append(links, entry)
+				return nil
+			}
+
+			addFileResult(response, restoreFile(entry, open, targetPath, conflictMode))
+			return nil
+		})
+	}
+
+	if err := walk(record); err != nil {
+		return err
+	}
+	for _, base := range chain {
+		if err := walk(base); err != nil {
+			return fmt.Errorf("failed to restore from backup record %d: %w", base.ID, err)
+		}
+	}
+
+	// 先创建的符号链接可能是后面链接的上级目录，创建前重新解析目标路径
+	for _, entry := range links {
+		targetPath, err := resolve(entry.Path)
+		if err != nil {
+			addFileResult(response, &FileRestoreResult{Path: entry.Path, Action: ActionFailed, Error: err.Error()})
+			continue
+		}
+		addFileResult(response, restoreSymlink(entry, targetPath, conflictMode))
+	}
+
+	// 由内向外设置新建目录的权限和属主
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirTargets[i], dirs[i].Mode); err != nil {
+			log.Printf("设置目录权限失败: %s, 错误: %v", dirTargets[i], err)
+		}
+		restoreOwner(dirTargets[i], dirs[i].Owner)
+	}
+	return nil
+}
+
+// addFileResult 记录单个文件的恢复结果并更新计数
+func addFileResult(response *FileRestoreResponse, result *FileRestoreResult) {
+	response.Results = append(response.Results, result)
+	switch result.Action {
+	case ActionSkipped:
+		response.Skipped++
+	case ActionFailed:
+		response.Failed++
+	default:
+		response.Restored++
+	}
+}
+
+// restoreFile 恢复单个文件，写入临时文件后再重命名，避免覆盖时留下不完整的文件
+func restoreFile(entry archive.Entry, open func() (io.ReadCloser, error), targetPath string, conflictMode string) *FileRestoreResult {
+	result := &FileRestoreResult{Path: entry.Path, Target: targetPath, Action: ActionRestored}
+	fail := func(err error) *FileRestoreResult {
+		result.Action = ActionFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	// 处理冲突
+	if err := resolveConflict(result, conflictMode); err != nil {
+		return fail(err)
+	}
+	if result.Action == ActionSkipped {
+		return result
+	}
+
+	dir := filepath.Dir(result.Target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fail(err)
+	}
+
+	src, err := open()
+	if err != nil {
+		return fail(err)
+	}
+	defer src.Close()
+
+	tempFile, err := ioutil.TempFile(dir, "."+filepath.Base(result.Target)+".restore-")
+	if err != nil {
+		return fail(err)
+	}
+	tempPath := tempFile.Name()
+
+	_, err = io.Copy(tempFile, src)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempPath, entry.Mode)
+	}
+	if err == nil {
+		err = os.Rename(tempPath, result.Target)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return fail(err)
+	}
+
+	// 恢复属主和修改时间
+	restoreOwner(result.Target, entry.Owner)
+	if !entry.ModTime.IsZero() {
+		_ = os.Chtimes(result.Target, entry.ModTime, entry.ModTime)
+	}
+
+	return result
+}
+
+// restoreSymlink 恢复符号链接，先在临时名称创建再重命名
+func restoreSymlink(entry archive.Entry, targetPath string, conflictMode string) *FileRestoreResult {
+	result := &FileRestoreResult{Path: entry.Path, Target: targetPath, Action: ActionRestored}
+	fail := func(err error) *FileRestoreResult {
+		result.Action = ActionFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	if err := resolveConflict(result, conflictMode); err != nil {
+		return fail(err)
+	}
+	if result.Action == ActionSkipped {
+		return result
+	}
+
+	dir := filepath.Dir(result.Target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fail(err)
+	}
+
+	tempPath := filepath.Join(dir, fmt.Sprintf(".%s.restore-%d", filepath.Base(result.Target), time.Now().UnixNano()))
+	if err := os.Symlink(entry.Linkname, tempPath); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tempPath, result.Target); err != nil {
+		os.Remove(tempPath)
+		return fail(err)
+	}
+
+	restoreOwner(result.Target, entry.Owner)
+	return result
+}
+
+// resolveConflict 根据冲突处理方式更新结果中的目标路径和处理结果
+func resolveConflict(result *FileRestoreResult, conflictMode string) error {
+	info, err := os.Lstat(result.Target)
+	if err != nil {
+		return nil
+	}
+	if info.IsDir() {
+		return fmt.Errorf("target is a directory")
+	}
+
+	switch conflictMode {
+	case ConflictSkip:
+		result.Action = ActionSkipped
+	case ConflictOverwrite:
+		result.Action = ActionOverwritten
+	case ConflictKeepBoth:
+		result.Target = nextFreeName(result.Target)
+		result.Action = ActionRenamed
+	}
+	return nil
+}
+
+// restoreOwner 以root身份运行时恢复属主，优先按用户名和组名查找，找不到时使用归档中的ID
+func restoreOwner(targetPath string, owner *archive.Owner) {
+	if owner == nil || os.Geteuid() != 0 {
+		return
+	}
+
+	uid, gid := owner.Uid, owner.Gid
+	if owner.Uname != "" {
+		if u, err := user.Lookup(owner.Uname); err == nil {
+			if id, err := strconv.Atoi(u.Uid); err == nil {
+				uid = id
+			}
+		}
+	}
+	if owner.Gname != "" {
+		if g, err := user.LookupGroup(owner.Gname); err == nil {
+			if id, err := strconv.Atoi(g.Gid); err == nil {
+				gid = id
+			}
+		}
+	}
+
+	if err := os.Lchown(targetPath, uid, gid); err != nil {
+		log.Printf("恢复属主失败: %s, 错误: %v", targetPath, err)
+	}
+}
+
+// originalPathResolver 根据任务的源路径生成条目到原始路径的映射
+// 备份时每个源路径以其最后一级名称作为归档中的顶层目录
+func originalPathResolver(sourcePaths []string) (func(string) (string, error), error) {
+	sources := make(map[string]string)
+	for _, p := range sourcePaths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source path %s: %w", p, err)
+		}
+		sources[filepath.Base(abs)] = abs
+	}
+
+	return func(entryPath string) (string, error) {
+		parts := strings.SplitN(entryPath, "/", 2)
+		source, ok := sources[parts[0]]
+		if !ok {
+			return "", fmt.Errorf("no source path matches %s", parts[0])
+		}
+		if len(parts) == 1 {
+			return source, nil
+		}
+		// 源路径本身可以是符号链接（备份时跟随了链接），确保其下的条目没有经由其他链接跳出源路径
+		return archive.SafeJoin(source, parts[1])
+	}, nil
+}
+
+// matchesFilters 判断条目是否为指定路径或位于指定目录之下
+func matchesFilters(entryPath string, filters []string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, filter := range filters {
+		if entryPath == filter || strings.HasPrefix(entryPath, filter+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// nextFreeName 生成不与已有文件冲突的文件名，如"a (1).txt"
+func nextFreeName(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
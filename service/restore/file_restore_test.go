@@ -0,0 +1,59 @@
+package restore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOriginalPathResolver(t *testing.T) {
+	base := t.TempDir()
+	outside := t.TempDir()
+
+	// 源路径本身是指向其他位置的符号链接
+	data := filepath.Join(base, "data")
+	if err := os.Mkdir(data, 0755); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(base, "app")
+	if err := os.Symlink(data, source); err != nil {
+		t.Fatal(err)
+	}
+	// 源路径下指向外部的符号链接
+	if err := os.Symlink(outside, filepath.Join(data, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	resolve, err := originalPathResolver([]string{source})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"app", source, false},
+		{"app/conf/app.yaml", filepath.Join(source, "conf", "app.yaml"), false},
+		{"app/escape", filepath.Join(source, "escape"), false},
+		{"app/escape/file.txt", "", true},
+		{"other/file.txt", "", true},
+	}
+	for _, tt := range tests {
+		got, err := resolve(tt.path)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("resolve(%q) = %q, want error", tt.path, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolve(%q): %v", tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolve(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package restore
+
+import (
+	"backup-go/entity"
+	"backup-go/repository"
+	"backup-go/service/config"
+	"backup-go/service/encryption"
+	"backup-go/service/redact"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// 恢复记录中保存的命令输出最大长度
+const maxOutputSize = 32 * 1024
+
+// RestoreService 恢复服务
+type RestoreService struct {
+	taskRepo       *repository.BackupTaskRepository
+	recordRepo     *repository.BackupRecordRepository
+	restoreRepo    *repository.RestoreRecordRepository
+	binlogRepo     *repository.BinlogFileRepository
+	webhookService *config.WebhookService
+}
+
+// NewRestoreService 创建恢复服务
+func NewRestoreService() *RestoreService {
+	return &RestoreService{
+		taskRepo:       repository.NewBackupTaskRepository(),
+		recordRepo:     repository.NewBackupRecordRepository(),
+		restoreRepo:    repository.NewRestoreRecordRepository(),
+		binlogRepo:     repository.NewBinlogFileRepository(),
+		webhookService: config.NewWebhookService(),
+	}
+}
+
+// loadBackup 查找可用于恢复的备份记录及其任务
+func (s *RestoreService) loadBackup(backupRecordID int64) (*entity.BackupRecord, *entity.BackupTask, error) {
+	record, err := s.recordRepo.FindByID(backupRecordID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find backup record: %w", err)
+	}
+	if record == nil {
+		return nil, nil, fmt.Errorf("backup record not found")
+	}
+	if record.Status != entity.StatusSuccess || record.FilePath == "" {
+		return nil, nil, fmt.Errorf("backup record %d has no restorable file", record.ID)
+	}
+
+	task, err := s.taskRepo.FindByID(record.TaskID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find task: %w", err)
+	}
+	if task == nil {
+		return nil, nil, fmt.Errorf("task not found")
+	}
+	redact.RegisterSourceInfo(task.SourceInfo)
+
+	return record, task, nil
+}
+
+// openBackupFile 从备份记录对应的存储中读取备份文件，加密的文件会被解密
+func (s *RestoreService) openBackupFile(record *entity.BackupRecord) (io.ReadCloser, error) {
+	return encryption.OpenRecordFile(record)
+}
+
+// startRestoreRecord 创建运行中的恢复记录
+func (s *RestoreService) startRestoreRecord(record *entity.BackupRecord, target string) (*entity.RestoreRecord, error) {
+	restoreRecord := &entity.RestoreRecord{
+		BackupRecordID: record.ID,
+		TaskID:         record.TaskID,
+		Target:         target,
+		Status:         entity.StatusRunning,
+		StartTime:      time.Now(),
+	}
+	if err := s.restoreRepo.Create(restoreRecord); err != nil {
+		return nil, fmt.Errorf("failed to create restore record: %w", err)
+	}
+	return restoreRecord, nil
+}
+
+// finishRestoreRecord 更新恢复记录的最终状态并发送通知
+func (s *RestoreService) finishRestoreRecord(restoreRecord *entity.RestoreRecord, taskName string, output string, restoreErr error) {
+	restoreRecord.EndTime = time.Now()
+	restoreRecord.Duration = restoreRecord.EndTime.Sub(restoreRecord.StartTime).Milliseconds()
+	// 命令输出和错误信息可能包含连接信息，保存和通知前隐藏其中的敏感信息
+	restoreRecord.Output = redact.String(output)
+
+	message := fmt.Sprintf("备份记录%d已恢复到%s", restoreRecord.BackupRecordID, restoreRecord.Target)
+	if restoreErr != nil {
+		restoreRecord.Status = entity.StatusFailed
+		restoreRecord.ErrorMessage = redact.String(restoreErr.Error())
+		message = fmt.Sprintf("备份记录%d恢复到%s失败: %s", restoreRecord.BackupRecordID, restoreRecord.Target, restoreRecord.ErrorMessage)
+		log.Printf("恢复记录 ID=%d 执行失败: %v", restoreRecord.ID, restoreErr)
+	} else {
+		restoreRecord.Status = entity.StatusSuccess
+		log.Printf("恢复记录 ID=%d 执行成功", restoreRecord.ID)
+	}
+
+	if err := s.restoreRepo.Update(restoreRecord); err != nil {
+		log.Printf("更新恢复记录 ID=%d 失败: %v", restoreRecord.ID, err)
+	}
+
+	// 尝试发送通知，忽略错误
+	_ = s.webhookService.SendRestoreNotification(
+		taskName,
+		restoreErr == nil,
+		message,
+		restoreRecord.EndTime.Sub(restoreRecord.StartTime),
+	)
+}
+
+// InitRestoreRecords 将系统启动时仍处于运行中的恢复记录标记为失败
+func InitRestoreRecords() error {
+	restoreRepo := repository.NewRestoreRecordRepository()
+
+	runningRecords, err := restoreRepo.FindByStatus(entity.StatusRunning)
+	if err != nil {
+		return fmt.Errorf("查询运行中的恢复记录失败: %w", err)
+	}
+
+	for _, record := range runningRecords {
+		record.Status = entity.StatusFailed
+		record.EndTime = time.Now()
+		record.ErrorMessage = "系统重启时，该恢复任务处于运行中状态，已被自动标记为失败"
+		if err := restoreRepo.Update(record); err != nil {
+			log.Printf("更新恢复记录 ID=%d 失败: %v", record.ID, err)
+		}
+	}
+
+	if len(runningRecords) > 0 {
+		log.Printf("已将 %d 条运行中的恢复记录标记为失败", len(runningRecords))
+	}
+	return nil
+}
+
+// tailBuffer 只保留最后若干字节的输出缓冲区，用于记录命令输出
+type tailBuffer struct {
+	mutex     sync.Mutex
+	data      []byte
+	limit     int
+	truncated bool
+}
+
+// newTailBuffer 创建输出缓冲区
+func newTailBuffer(limit int) *tailBuffer {
+	return &tailBuffer{limit: limit}
+}
+
+// Write 写入数据，超出长度限制时丢弃最早的内容
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.data = append(b.data, p...)
+	if len(b.data) > b.limit {
+		b.data = b.data[len(b.data)-b.limit:]
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+// String 返回缓冲区内容
+func (b *tailBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.truncated {
+		return "...\n" + string(b.data)
+	}
+	return string(b.data)
+}
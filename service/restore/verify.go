@@ -0,0 +1,326 @@
+package restore
+
+import (
+	"backup-go/entity"
+	"backup-go/service/dbclient"
+	"backup-go/service/redact"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 同一时间只执行一个校验，避免多个校验同时使用同一个临时数据库
+var verifyMutex sync.Mutex
+
+// StartVerify 校验备份能否恢复，校验在后台执行，返回标记为校验中的备份记录
+func (s *RestoreService) StartVerify(backupRecordID int64) (*entity.BackupRecord, error) {
+	record, task, source, target, err := s.prepareVerify(backupRecordID)
+	if err != nil {
+		return nil, err
+	}
+
+	record.VerifyStatus = entity.VerifyRunning
+	if err := s.recordRepo.UpdateVerify(record); err != nil {
+		return nil, fmt.Errorf("failed to update backup record: %w", err)
+	}
+
+	go s.runVerify(record, task, source, target)
+	return record, nil
+}
+
+// VerifyBackup 同步校验备份能否恢复，结果写入备份记录
+func (s *RestoreService) VerifyBackup(backupRecordID int64) error {
+	record, task, source, target, err := s.prepareVerify(backupRecordID)
+	if err != nil {
+		return err
+	}
+
+	record.VerifyStatus = entity.VerifyRunning
+	if err := s.recordRepo.UpdateVerify(record); err != nil {
+		return fmt.Errorf("failed to update backup record: %w", err)
+	}
+
+	s.runVerify(record, task, source, target)
+	return nil
+}
+
+// prepareVerify 检查备份记录和校验配置，生成临时数据库的连接信息
+func (s *RestoreService) prepareVerify(backupRecordID int64) (*entity.BackupRecord, *entity.BackupTask, *entity.DatabaseSourceInfo, *entity.DatabaseSourceInfo, error) {
+	record, task, err := s.loadBackup(backupRecordID)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if task.Type != entity.DatabaseBackup {
+		return nil, nil, nil, nil, fmt.Errorf("backup record %d is not a database backup", record.ID)
+	}
+
+	source, err := s.taskRepo.ParseDatabaseSourceInfo(task)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to parse database source info: %w", err)
+	}
+	if isAllDatabases(source.Database) {
+		return nil, nil, nil, nil, fmt.Errorf("verification requires a single-database backup")
+	}
+
+	options := source.Verify
+	if options == nil {
+		options = &entity.VerifyOptions{}
+	}
+
+	target := mergeDatabaseTarget(source, options.Target)
+	if options.Target == nil || options.Target.Database == "" {
+		target.Database = source.Database + "_verify"
+	}
+	if target.Type != source.Type {
+		return nil, nil, nil, nil, fmt.Errorf("cannot verify a %s backup with %s", source.Type, target.Type)
+	}
+	if isAllDatabases(target.Database) {
+		return nil, nil, nil, nil, fmt.Errorf("verify target database is required")
+	}
+	// 临时数据库会被删除，不能与源数据库相同
+	if target.Database == source.Database && target.Host == source.Host && target.Port == source.Port && target.URI == source.URI {
+		return nil, nil, nil, nil, fmt.Errorf("verify target must not be the source database")
+	}
+
+	return record, task, source, target, nil
+}
+
+// runVerify 将备份导入临时数据库并执行检查，更新备份记录并发送通知
+func (s *RestoreService) runVerify(record *entity.BackupRecord, task *entity.BackupTask, source, target *entity.DatabaseSourceInfo) {
+	verifyMutex.Lock()
+	defer verifyMutex.Unlock()
+
+	log.Printf("开始校验备份记录 %d，临时数据库: %s", record.ID, describeDatabaseTarget(target))
+
+	message, err := s.verifyDatabase(record, source, target)
+	if err != nil {
+		message = strings.TrimSpace(message + "\n" + err.Error())
+	}
+	if len(message) > maxOutputSize {
+		message = "...\n" + message[len(message)-maxOutputSize:]
+	}
+
+	now := time.Now()
+	record.VerifiedAt = &now
+	record.VerifyMessage = redact.String(message)
+	if err != nil {
+		record.VerifyStatus = entity.VerifyFailed
+		log.Printf("备份记录 %d 校验失败: %v", record.ID, err)
+	} else {
+		record.VerifyStatus = entity.VerifyPassed
+		log.Printf("备份记录 %d 校验通过", record.ID)
+	}
+
+	if updateErr := s.recordRepo.UpdateVerify(record); updateErr != nil {
+		log.Printf("更新备份记录 ID=%d 失败: %v", record.ID, updateErr)
+	}
+
+	// 尝试发送通知，忽略错误
+	notification := fmt.Sprintf("备份记录%d恢复校验通过", record.ID)
+	if err != nil {
+		notification = fmt.Sprintf("备份记录%d恢复校验失败: %s", record.ID, err.Error())
+	}
+	_ = s.webhookService.SendVerifyNotification(task.Name, err == nil, notification)
+}
+
+// verifyDatabase 重建临时数据库、导入备份并执行检查，返回检查结果
+func (s *RestoreService) verifyDatabase(record *entity.BackupRecord, source, target *entity.DatabaseSourceInfo) (string, error) {
+	if err := resetScratchDatabase(target); err != nil {
+		return "", fmt.Errorf("failed to prepare verify database: %w", err)
+	}
+	// 校验结束后删除临时数据库
+	defer func() {
+		if err := dropScratchDatabase(target); err != nil {
+			log.Printf("删除临时数据库 %s 失败: %v", target.Database, err)
+		}
+	}()
+
+	output, err := s.replayDatabase(record, source, target, true)
+	if err != nil {
+		return output, err
+	}
+
+	var result strings.Builder
+
+	// 检查表（集合）数量
+	minTables := 1
+	if source.Verify != nil && source.Verify.MinTables > 0 {
+		minTables = source.Verify.MinTables
+	}
+	tableCount, err := runVerifyQuery(target, tableCountQuery(target.Type))
+	if err != nil {
+		return result.String(), fmt.Errorf("failed to count tables: %w", err)
+	}
+	fmt.Fprintf(&result, "tables: %s\n", tableCount)
+	var tables int
+	if _, err := fmt.Sscanf(tableCount, "%d", &tables); err != nil || tables < minTables {
+		return result.String(), fmt.Errorf("expected at least %d tables, got %s", minTables, tableCount)
+	}
+
+	// 执行自定义检查语句
+	if source.Verify != nil {
+		for _, query := range source.Verify.Queries {
+			query = strings.TrimSpace(query)
+			if query == "" {
+				continue
+			}
+			value, err := runVerifyQuery(target, query)
+			if err != nil {
+				return result.String(), fmt.Errorf("query %q failed: %w", query, err)
+			}
+			if value == "" {
+				return result.String(), fmt.Errorf("query %q returned no result", query)
+			}
+			fmt.Fprintf(&result, "%s: %s\n", query, value)
+		}
+	}
+
+	return result.String(), nil
+}
+
+// resetScratchDatabase 删除并重新创建临时数据库，MongoDB恢复时会自动创建
+func resetScratchDatabase(target *entity.DatabaseSourceInfo) error {
+	switch target.Type {
+	case "mysql":
+		name := "`" + strings.ReplaceAll(target.Database, "`", "``") + "`"
+		return runAdminCommand(buildMySQLQueryCommand(target, "", "DROP DATABASE IF EXISTS "+name+"; CREATE DATABASE "+name))
+	case "postgres":
+		name := `"` + strings.ReplaceAll(target.Database, `"`, `""`) + `"`
+		// DROP DATABASE不能在事务中执行，分两条命令
+		return runAdminCommand(buildPostgresQueryCommand(target, "postgres", "DROP DATABASE IF EXISTS "+name, "CREATE DATABASE "+name))
+	case "mongodb":
+		return dropScratchDatabase(target)
+	default:
+		return fmt.Errorf("unsupported database type: %s", target.Type)
+	}
+}
+
+// dropScratchDatabase 删除临时数据库
+func dropScratchDatabase(target *entity.DatabaseSourceInfo) error {
+	switch target.Type {
+	case "mysql":
+		name := "`" + strings.ReplaceAll(target.Database, "`", "``") + "`"
+		return runAdminCommand(buildMySQLQueryCommand(target, "", "DROP DATABASE IF EXISTS "+name))
+	case "postgres":
+		name := `"` + strings.ReplaceAll(target.Database, `"`, `""`) + `"`
+		return runAdminCommand(buildPostgresQueryCommand(target, "postgres", "DROP DATABASE IF EXISTS "+name))
+	case "mongodb":
+		cmd, cleanup, err := buildMongoQueryCommand(target, "db.dropDatabase()")
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		return runAdminCommand(cmd)
+	default:
+		return fmt.Errorf("unsupported database type: %s", target.Type)
+	}
+}
+
+// tableCountQuery 返回统计临时数据库中表（集合）数量的语句
+func tableCountQuery(databaseType string) string {
+	switch databaseType {
+	case "mysql":
+		return "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE()"
+	case "postgres":
+		return "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema NOT IN ('pg_catalog', 'information_schema')"
+	default:
+		return "db.getCollectionNames().length"
+	}
+}
+
+// runVerifyQuery 在临时数据库中执行检查语句，返回去除首尾空白的输出
+func runVerifyQuery(target *entity.DatabaseSourceInfo, query string) (string, error) {
+	var cmd *exec.Cmd
+	switch target.Type {
+	case "mysql":
+		cmd = buildMySQLQueryCommand(target, target.Database, query)
+	case "postgres":
+		cmd = buildPostgresQueryCommand(target, target.Database, query)
+	case "mongodb":
+		var cleanup func()
+		var err error
+		cmd, cleanup, err = buildMongoQueryCommand(target, query)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+	default:
+		return "", fmt.Errorf("unsupported database type: %s", target.Type)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// runAdminCommand 执行管理命令，失败时返回命令输出
+func runAdminCommand(cmd *exec.Cmd) error {
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// buildMySQLQueryCommand 构造执行单条语句的mysql命令，输出不带表头
+func buildMySQLQueryCommand(target *entity.DatabaseSourceInfo, database string, query string) *exec.Cmd {
+	client := dbclient.DetectMySQLClient(dbclient.MySQLFlavor(target))
+	args := mysqlClientArgs(target, client)
+	args = append(args, "-N", "-B", "-e", query)
+	if database != "" {
+		args = append(args, database)
+	}
+
+	cmd := exec.Command(client.Program, args...)
+	// 通过环境变量传递密码，避免出现在命令行中
+	cmd.Env = append(os.Environ(), "MYSQL_PWD="+target.Password)
+	return cmd
+}
+
+// buildPostgresQueryCommand 构造依次执行语句的psql命令，输出不带表头和对齐
+func buildPostgresQueryCommand(target *entity.DatabaseSourceInfo, database string, queries ...string) *exec.Cmd {
+	port := target.Port
+	if port == 0 {
+		port = 5432
+	}
+
+	args := []string{
+		"-h", target.Host,
+		"-p", fmt.Sprintf("%d", port),
+		"-U", target.User,
+		"-w",
+		"-d", database,
+		"-t", "-A",
+		"-v", "ON_ERROR_STOP=1",
+	}
+	for _, query := range queries {
+		args = append(args, "-c", query)
+	}
+
+	cmd := exec.Command("psql", args...)
+	// 通过环境变量传递密码，避免出现在命令行中
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+target.Password)
+	return cmd
+}
+
+// buildMongoQueryCommand 构造在临时数据库中执行表达式的mongosh命令
+// 返回的cleanup在命令结束后调用，删除保存连接字符串的临时文件
+func buildMongoQueryCommand(target *entity.DatabaseSourceInfo, expression string) (*exec.Cmd, func(), error) {
+	// 连接字符串中可能指定了其他数据库，执行前切换到临时数据库
+	script := fmt.Sprintf("db = db.getSiblingDB(%q); %s", target.Database, expression)
+	args, cleanup, err := dbclient.MongoShellArgs(target, script)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return exec.Command("mongosh", args...), cleanup, nil
+}
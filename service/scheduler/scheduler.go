@@ -4,6 +4,8 @@ import (
 	"backup-go/entity"
 	"backup-go/repository"
 	"backup-go/service/backup"
+	"backup-go/service/redact"
+	"backup-go/service/restore"
 	"fmt"
 	"log"
 	"sync"
@@ -18,6 +20,7 @@ type BackupScheduler struct {
 	taskRepo   *repository.BackupTaskRepository
 	recordRepo *repository.BackupRecordRepository
 	jobs       map[int64]cron.EntryID
+	verifyJobs map[int64]cron.EntryID // 定时恢复校验任务
 	mutex      sync.Mutex
 	running    bool
 }
@@ -35,6 +38,7 @@ func GetScheduler() *BackupScheduler {
 			taskRepo:   repository.NewBackupTaskRepository(),
 			recordRepo: repository.NewBackupRecordRepository(),
 			jobs:       make(map[int64]cron.EntryID),
+			verifyJobs: make(map[int64]cron.EntryID),
 		}
 	})
 	return scheduler
@@ -91,6 +95,10 @@ func (s *BackupScheduler) Reload() error {
 		s.cron.Remove(entryID)
 		delete(s.jobs, taskID)
 	}
+	for taskID, entryID := range s.verifyJobs {
+		s.cron.Remove(entryID)
+		delete(s.verifyJobs, taskID)
+	}
 
 	// 加载任务
 	return s.loadTasks()
@@ -106,6 +114,7 @@ func (s *BackupScheduler) AddTask(task *entity.BackupTask) error {
 		s.cron.Remove(entryID)
 		delete(s.jobs, task.ID)
 	}
+	s.scheduleVerify(task)
 
 	// 如果任务未启用，则不添加
 	if !task.Enabled {
@@ -134,6 +143,10 @@ func (s *BackupScheduler) RemoveTask(taskID int64) {
 		s.cron.Remove(entryID)
 		delete(s.jobs, taskID)
 	}
+	if entryID, exists := s.verifyJobs[taskID]; exists {
+		s.cron.Remove(entryID)
+		delete(s.verifyJobs, taskID)
+	}
 }
 
 // IsTaskScheduled 检查任务是否已调度
@@ -199,6 +212,7 @@ func (s *BackupScheduler) loadTasks() error {
 		}
 
 		s.jobs[task.ID] = entryID
+		s.scheduleVerify(task)
 		s.mutex.Unlock()
 	}
 
@@ -221,6 +235,9 @@ func (s *BackupScheduler) executeTask(taskID int64) {
 		return
 	}
 
+	// 登记任务配置中的密码等信息，执行过程中输出的日志和错误信息会隐藏它们
+	redact.RegisterSourceInfo(task.SourceInfo)
+
 	// 创建备份服务
 	backupService, err := backup.NewBackupService(task.Type)
 	if err != nil {
@@ -237,6 +254,68 @@ func (s *BackupScheduler) executeTask(taskID int64) {
 	}
 
 	log.Printf("任务 %d 执行成功，备份记录ID: %d", taskID, record.ID)
+
+	// 未设置校验计划时，备份完成后立即校验
+	if options := s.verifyOptions(task); options != nil && options.Schedule == "" {
+		if err := restore.NewRestoreService().VerifyBackup(record.ID); err != nil {
+			log.Printf("校验备份记录 %d 失败: %v", record.ID, err)
+		}
+	}
+}
+
+// scheduleVerify 为设置了校验计划的数据库任务添加定时校验，调用方需持有锁
+func (s *BackupScheduler) scheduleVerify(task *entity.BackupTask) {
+	if entryID, exists := s.verifyJobs[task.ID]; exists {
+		s.cron.Remove(entryID)
+		delete(s.verifyJobs, task.ID)
+	}
+
+	if !task.Enabled {
+		return
+	}
+	options := s.verifyOptions(task)
+	if options == nil || options.Schedule == "" {
+		return
+	}
+
+	taskID := task.ID
+	entryID, err := s.cron.AddFunc(options.Schedule, func() {
+		s.verifyLatest(taskID)
+	})
+	if err != nil {
+		log.Printf("添加任务 %d 的校验计划失败: %v", task.ID, err)
+		return
+	}
+	s.verifyJobs[task.ID] = entryID
+}
+
+// verifyOptions 返回任务已启用的恢复校验配置，未启用时返回nil
+func (s *BackupScheduler) verifyOptions(task *entity.BackupTask) *entity.VerifyOptions {
+	if task.Type != entity.DatabaseBackup {
+		return nil
+	}
+	sourceInfo, err := s.taskRepo.ParseDatabaseSourceInfo(task)
+	if err != nil || sourceInfo.Verify == nil || !sourceInfo.Verify.Enabled {
+		return nil
+	}
+	return sourceInfo.Verify
+}
+
+// verifyLatest 校验任务最近一次成功的备份
+func (s *BackupScheduler) verifyLatest(taskID int64) {
+	record, err := s.recordRepo.FindLatestSuccessByTaskID(taskID)
+	if err != nil {
+		log.Printf("获取任务 %d 的备份记录失败: %v", taskID, err)
+		return
+	}
+	if record == nil {
+		log.Printf("任务 %d 没有可校验的备份", taskID)
+		return
+	}
+
+	if err := restore.NewRestoreService().VerifyBackup(record.ID); err != nil {
+		log.Printf("校验备份记录 %d 失败: %v", record.ID, err)
+	}
 }
 
 // GetNextExecutionTime 获取任务的下一次执行时间
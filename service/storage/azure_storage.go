@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"backup-go/entity"
+	configService "backup-go/service/config"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+)
+
+// 块上传参数
+const (
+	azureDefaultBlockSize  = 8 // 默认块大小（MB），块数上限为50000个
+	azureUploadConcurrency = 3 // 同时上传的块数
+)
+
+// 已确认存在的容器，每个进程只尝试创建一次
+var azureContainers sync.Map
+
+// AzureStorageService Azure Blob存储服务
+type AzureStorageService struct {
+	client     *azblob.Client
+	container  string
+	blockSize  int64
+	accessTier *blob.AccessTier
+	err        error // 创建客户端失败的原因
+}
+
+// NewAzureStorageService 创建Azure Blob存储服务
+func NewAzureStorageService() *AzureStorageService {
+	// 从系统配置表获取配置
+	cs := configService.NewConfigService()
+
+	account, _ := cs.GetConfigValue("storage.azureAccount")
+	accountKey, _ := cs.GetConfigValue("storage.azureAccountKey")
+	sasToken, _ := cs.GetConfigValue("storage.azureSasToken")
+	container, _ := cs.GetConfigValue("storage.azureContainer")
+	endpoint, _ := cs.GetConfigValue("storage.azureEndpoint")
+	blockSizeStr, _ := cs.GetConfigValue("storage.azureBlockSize")
+	accessTier, _ := cs.GetConfigValue("storage.azureAccessTier")
+
+	// 如果配置为空，则使用默认值
+	if container == "" {
+		container = "backup-go"
+	}
+	blockSize, err := strconv.ParseInt(blockSizeStr, 10, 64)
+	if err != nil || blockSize <= 0 {
+		blockSize = azureDefaultBlockSize
+	}
+
+	service := &AzureStorageService{
+		container: container,
+		blockSize: blockSize * 1024 * 1024,
+	}
+	if accessTier != "" {
+		tier := blob.AccessTier(accessTier)
+		service.accessTier = &tier
+	}
+
+	// Azurite等模拟器需要指定端点，如http://127.0.0.1:10000/devstoreaccount1
+	if endpoint == "" {
+		if account == "" {
+			service.err = fmt.Errorf("Azure storage account is not configured")
+			return service
+		}
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net/", account)
+	}
+	endpoint = strings.TrimRight(endpoint, "/") + "/"
+
+	// 优先使用共享密钥，否则使用SAS令牌
+	if accountKey != "" {
+		credential, err := azblob.NewSharedKeyCredential(account, accountKey)
+		if err != nil {
+			service.err = fmt.Errorf("invalid Azure shared key: %w", err)
+			return service
+		}
+		service.client, err = azblob.NewClientWithSharedKeyCredential(endpoint, credential, nil)
+		if err != nil {
+			service.err = fmt.Errorf("failed to create Azure client: %w", err)
+		}
+	} else if sasToken != "" {
+		service.client, err = azblob.NewClientWithNoCredential(endpoint+"?"+strings.TrimPrefix(sasToken, "?"), nil)
+		if err != nil {
+			service.err = fmt.Errorf("failed to create Azure client: %w", err)
+		}
+	} else {
+		service.err = fmt.Errorf("Azure account key or SAS token is required")
+	}
+
+	return service
+}
+
+// ensureContainer 容器不存在时创建，SAS令牌没有创建权限时忽略错误，由上传结果反映
+func (s *AzureStorageService) ensureContainer(ctx context.Context) {
+	key := s.client.URL() + s.container
+	if _, ok := azureContainers.Load(key); ok {
+		return
+	}
+
+	_, err := s.client.CreateContainer(ctx, s.container, nil)
+	if err != nil && !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+		log.Printf("创建Azure容器失败: %s, 错误: %v", s.container, err)
+		return
+	}
+	azureContainers.Store(key, true)
+}
+
+// Save 保存文件
+func (s *AzureStorageService) Save(filename string, content io.Reader) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+
+	ctx := context.Background()
+	s.ensureContainer(ctx)
+
+	// 创建目录格式，与S3存储一致
+	today := time.Now().Format("20060102")
+	blobName := path.Join("backups", today, filename)
+
+	// 内容按块上传后提交块列表，上传失败时未提交的块会被服务端自动清理
+	_, err := s.client.UploadStream(ctx, s.container, blobName, content, &azblob.UploadStreamOptions{
+		BlockSize:   s.blockSize,
+		Concurrency: azureUploadConcurrency,
+		AccessTier:  s.accessTier,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload to Azure: %w", err)
+	}
+
+	return blobName, nil
+}
+
+// Get 获取文件
+func (s *AzureStorageService) Get(filePath string) (io.ReadCloser, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	resp, err := s.client.DownloadStream(context.Background(), s.container, filePath, nil)
+	if err != nil {
+		// 文件不存在时与本地存储一样返回os.ErrNotExist
+		if bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound) {
+			return nil, fmt.Errorf("failed to get file from Azure: %s: %w", filePath, os.ErrNotExist)
+		}
+		// 归档层的文件需要先解除归档才能读取
+		if bloberror.HasCode(err, bloberror.BlobArchived) {
+			return nil, fmt.Errorf("failed to get file from Azure: %s is in the archive tier and must be rehydrated first: %w", filePath, err)
+		}
+		return nil, fmt.Errorf("failed to get file from Azure: %w", err)
+	}
+
+	return resp.Body, nil
+}
+
+// Delete 删除文件
+func (s *AzureStorageService) Delete(filePath string) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	_, err := s.client.DeleteBlob(context.Background(), s.container, filePath, nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound) {
+			return fmt.Errorf("failed to delete file from Azure: %s: %w", filePath, os.ErrNotExist)
+		}
+		return fmt.Errorf("failed to delete file from Azure: %w", err)
+	}
+
+	return nil
+}
+
+// GetStorageType 获取存储类型
+func (s *AzureStorageService) GetStorageType() entity.StorageType {
+	return entity.AzureStorage
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+// fakeBlobServer 模拟Blob服务的容器创建、分块上传、下载和删除
+type fakeBlobServer struct {
+	mutex  sync.Mutex
+	blocks map[string][]byte // 已上传未提交的块，键为blob路径和块ID
+	blobs  map[string][]byte // 已提交的blob，键为"/账户/容器/blob"
+}
+
+func (f *fakeBlobServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	query := r.URL.Query()
+	name := r.URL.Path
+	notFound := func() {
+		w.Header().Set("x-ms-error-code", "BlobNotFound")
+		w.WriteHeader(http.StatusNotFound)
+	}
+
+	switch {
+	case r.Method == http.MethodPut && query.Get("restype") == "container":
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		data, _ := io.ReadAll(r.Body)
+		f.blocks[name+"#"+query.Get("blockid")] = data
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		var list struct {
+			IDs []string `xml:",any"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&list); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var blob []byte
+		for _, id := range list.IDs {
+			blob = append(blob, f.blocks[name+"#"+id]...)
+		}
+		f.blobs[name] = blob
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.blobs[name] = data
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet:
+		blob, ok := f.blobs[name]
+		if !ok {
+			notFound()
+			return
+		}
+		w.Header().Set("ETag", `"0x1"`)
+		w.Header().Set("Last-Modified", "Mon, 01 Jan 2024 00:00:00 GMT")
+		w.Header().Set("x-ms-blob-type", "BlockBlob")
+		w.Write(blob)
+	case r.Method == http.MethodDelete:
+		if _, ok := f.blobs[name]; !ok {
+			notFound()
+			return
+		}
+		delete(f.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestAzureStorage(t *testing.T) {
+	fake := &fakeBlobServer{blocks: make(map[string][]byte), blobs: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := azblob.NewClientWithNoCredential(server.URL+"/devstoreaccount1/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 块大小较小，使内容分为多个块上传
+	s := &AzureStorageService{client: client, container: "backup-go", blockSize: 1024}
+	data := bytes.Repeat([]byte("azure"), 1000)
+
+	blobName, err := s.Save("db.sql.gz", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(blobName, "backups/") {
+		t.Errorf("blob name = %s, want backups/...", blobName)
+	}
+	if _, ok := fake.blobs["/devstoreaccount1/backup-go/"+blobName]; !ok {
+		t.Fatalf("blob %s not committed", blobName)
+	}
+
+	file, err := s.Get(blobName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(data))
+	}
+
+	if err := s.Delete(blobName); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(blobName); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Get after delete: error = %v, want os.ErrNotExist", err)
+	}
+	if err := s.Delete(blobName); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Delete missing blob: error = %v, want os.ErrNotExist", err)
+	}
+}
@@ -0,0 +1,376 @@
+package storage
+
+import (
+	"backup-go/entity"
+	configService "backup-go/service/config"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/textproto"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// FTP上传参数
+const (
+	ftpDialTimeout = 30 * time.Second
+	ftpSegmentSize = 16 * 1024 * 1024 // 上传分段大小，连接中断时从服务器上已有的大小继续上传当前分段
+	ftpMaxRetries  = 3                // 每个分段最多重试的次数
+	ftpMaxIdle     = 2                // 保留的空闲连接数
+)
+
+// FTP的TLS模式
+const (
+	ftpTLSNone     = "none"     // 不加密
+	ftpTLSExplicit = "explicit" // 显式TLS（AUTH TLS），通常使用21端口
+	ftpTLSImplicit = "implicit" // 隐式TLS，通常使用990端口
+)
+
+// FTPStorageService FTP/FTPS存储服务，只使用被动模式
+type FTPStorageService struct {
+	host     string
+	port     int
+	user     string
+	password string
+	tlsMode  string
+	insecure bool
+	basePath string
+}
+
+// ftpIdleConn 空闲的FTP连接，数据块等小文件逐个保存时不必每次重新登录
+type ftpIdleConn struct {
+	key  string
+	conn *ftp.ServerConn
+}
+
+var (
+	ftpMutex sync.Mutex
+	ftpIdle  []*ftpIdleConn
+	// TLS会话缓存，部分服务器要求数据连接复用控制连接的TLS会话
+	ftpSessionCache = tls.NewLRUClientSessionCache(16)
+)
+
+// NewFTPStorageService 创建FTP存储服务
+func NewFTPStorageService() *FTPStorageService {
+	// 从系统配置表获取配置
+	cs := configService.NewConfigService()
+
+	host, _ := cs.GetConfigValue("storage.ftpHost")
+	portStr, _ := cs.GetConfigValue("storage.ftpPort")
+	user, _ := cs.GetConfigValue("storage.ftpUser")
+	password, _ := cs.GetConfigValue("storage.ftpPassword")
+	tlsMode, _ := cs.GetConfigValue("storage.ftpTLS")
+	insecure, _ := cs.GetConfigValue("storage.ftpInsecureSkipVerify")
+	basePath, _ := cs.GetConfigValue("storage.ftpBasePath")
+
+	// 如果配置为空，则使用默认值
+	if tlsMode == "" {
+		tlsMode = ftpTLSNone
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 {
+		port = 21
+		if tlsMode == ftpTLSImplicit {
+			port = 990
+		}
+	}
+	if user == "" {
+		user = "anonymous"
+		if password == "" {
+			password = "anonymous"
+		}
+	}
+	if basePath == "" {
+		basePath = "backups"
+	}
+
+	return &FTPStorageService{
+		host:     host,
+		port:     port,
+		user:     user,
+		password: password,
+		tlsMode:  tlsMode,
+		insecure: insecure == "true",
+		basePath: basePath,
+	}
+}
+
+// connKey 区分不同配置的连接
+func (s *FTPStorageService) connKey() string {
+	return fmt.Sprintf("%s@%s:%d|%s|%s|%t", s.user, s.host, s.port, s.password, s.tlsMode, s.insecure)
+}
+
+// acquire 取出一个可用的空闲连接，没有时新建连接
+func (s *FTPStorageService) acquire() (*ftp.ServerConn, error) {
+	if s.host == "" {
+		return nil, fmt.Errorf("FTP not configured properly")
+	}
+
+	key := s.connKey()
+	ftpMutex.Lock()
+	var conn *ftp.ServerConn
+	for i, idle := range ftpIdle {
+		if idle.key == key {
+			conn = idle.conn
+			ftpIdle = append(ftpIdle[:i], ftpIdle[i+1:]...)
+			break
+		}
+	}
+	ftpMutex.Unlock()
+
+	// 空闲连接可能已被服务器断开
+	if conn != nil {
+		if err := conn.NoOp(); err == nil {
+			return conn, nil
+		}
+		conn.Quit()
+	}
+	return s.dial()
+}
+
+// release 归还连接，连接出错时直接关闭
+func (s *FTPStorageService) release(conn *ftp.ServerConn, healthy bool) {
+	if !healthy {
+		conn.Quit()
+		return
+	}
+
+	ftpMutex.Lock()
+	defer ftpMutex.Unlock()
+	if len(ftpIdle) >= ftpMaxIdle {
+		ftpIdle[0].conn.Quit()
+		ftpIdle = ftpIdle[1:]
+	}
+	ftpIdle = append(ftpIdle, &ftpIdleConn{key: s.connKey(), conn: conn})
+}
+
+// dial 连接并登录FTP服务器
+func (s *FTPStorageService) dial() (*ftp.ServerConn, error) {
+	options := []ftp.DialOption{ftp.DialWithTimeout(ftpDialTimeout)}
+	tlsConfig := &tls.Config{
+		ServerName:         s.host,
+		InsecureSkipVerify: s.insecure,
+		ClientSessionCache: ftpSessionCache,
+	}
+	switch s.tlsMode {
+	case ftpTLSNone:
+	case ftpTLSExplicit:
+		options = append(options, ftp.DialWithExplicitTLS(tlsConfig))
+	case ftpTLSImplicit:
+		options = append(options, ftp.DialWithTLS(tlsConfig))
+	default:
+		return nil, fmt.Errorf("unsupported FTP TLS mode: %s", s.tlsMode)
+	}
+
+	address := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	conn, err := ftp.Dial(address, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to FTP server %s: %w", address, err)
+	}
+	if err := conn.Login(s.user, s.password); err != nil {
+		conn.Quit()
+		return nil, fmt.Errorf("failed to login to FTP server: %w", err)
+	}
+	return conn, nil
+}
+
+// remotePath 返回文件在服务器上的完整路径
+func (s *FTPStorageService) remotePath(filePath string) string {
+	return path.Join(s.basePath, filepath.ToSlash(filePath))
+}
+
+// makeDirs 逐级创建目录，目录已存在时服务器返回错误，忽略即可，目录确实无法创建时上传会失败
+func makeDirs(conn *ftp.ServerConn, dir string) {
+	current := ""
+	if path.IsAbs(dir) {
+		current = "/"
+	}
+	for _, segment := range splitPath(dir) {
+		current = path.Join(current, segment)
+		conn.MakeDir(current)
+	}
+}
+
+// splitPath 按/拆分路径，去掉空段
+func splitPath(p string) []string {
+	var segments []string
+	for _, segment := range strings.Split(p, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// Save 保存文件
+func (s *FTPStorageService) Save(filename string, content io.Reader) (string, error) {
+	conn, err := s.acquire()
+	if err != nil {
+		return "", err
+	}
+
+	// 创建目录
+	today := time.Now().Format("20060102")
+	relativePath := path.Join(today, filename)
+	fullPath := s.remotePath(relativePath)
+	makeDirs(conn, path.Dir(fullPath))
+
+	// 分段读取内容并上传，每段上传失败时重新连接并从服务器上已有的位置继续
+	buffer := make([]byte, ftpSegmentSize)
+	var offset int64
+	for {
+		n, readErr := io.ReadFull(content, buffer)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			s.abortUpload(conn, fullPath)
+			return "", fmt.Errorf("failed to upload to FTP server: %w", readErr)
+		}
+		// 空文件也需要创建
+		if n > 0 || offset == 0 {
+			conn, err = s.uploadSegment(conn, fullPath, buffer[:n], offset)
+			if err != nil {
+				s.abortUpload(conn, fullPath)
+				return "", fmt.Errorf("failed to upload to FTP server: %w", err)
+			}
+			offset += int64(n)
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	s.release(conn, true)
+	return relativePath, nil
+}
+
+// uploadSegment 上传从offset开始的一段内容，失败时重新连接并续传，返回之后使用的连接
+func (s *FTPStorageService) uploadSegment(conn *ftp.ServerConn, fullPath string, data []byte, offset int64) (*ftp.ServerConn, error) {
+	skip := int64(0)
+	var err, uploadErr error
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			// 重新连接，查询服务器上已写入的大小，从该位置继续上传
+			if conn != nil {
+				conn.Quit()
+				conn = nil
+			}
+			time.Sleep(time.Duration(attempt) * time.Second)
+			if conn, err = s.dial(); err != nil {
+				log.Printf("重新连接FTP服务器失败: %v", err)
+				if attempt >= ftpMaxRetries {
+					return nil, err
+				}
+				continue
+			}
+			size, sizeErr := conn.FileSize(fullPath)
+			if sizeErr != nil {
+				size = 0
+			}
+			if size < offset || size > offset+int64(len(data)) {
+				return conn, fmt.Errorf("cannot resume upload of %s: server has %d bytes, expected %d to %d: %w", fullPath, size, offset, offset+int64(len(data)), uploadErr)
+			}
+			skip = size - offset
+			log.Printf("FTP上传中断，从第%d字节继续上传: %s", size, fullPath)
+		}
+
+		reader := bytes.NewReader(data[skip:])
+		if offset+skip == 0 {
+			uploadErr = conn.Stor(fullPath, reader)
+		} else {
+			uploadErr = conn.StorFrom(fullPath, reader, uint64(offset+skip))
+		}
+		if uploadErr == nil {
+			return conn, nil
+		}
+		log.Printf("FTP上传失败（第%d次）: %s, 错误: %v", attempt+1, fullPath, uploadErr)
+		if attempt >= ftpMaxRetries {
+			return conn, uploadErr
+		}
+	}
+}
+
+// abortUpload 删除不完整的文件并关闭连接
+func (s *FTPStorageService) abortUpload(conn *ftp.ServerConn, fullPath string) {
+	if conn == nil {
+		var err error
+		if conn, err = s.dial(); err != nil {
+			return
+		}
+	}
+	conn.Delete(fullPath)
+	conn.Quit()
+}
+
+// ftpFile 下载中的文件，关闭时归还连接
+type ftpFile struct {
+	*ftp.Response
+	service *FTPStorageService
+	conn    *ftp.ServerConn
+}
+
+// Close 结束下载并归还连接
+func (f *ftpFile) Close() error {
+	err := f.Response.Close()
+	f.service.release(f.conn, err == nil)
+	return err
+}
+
+// Get 获取文件
+func (s *FTPStorageService) Get(filePath string) (io.ReadCloser, error) {
+	conn, err := s.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := conn.Retr(s.remotePath(filePath))
+	if err != nil {
+		s.release(conn, isFTPStatus(err))
+		return nil, fmt.Errorf("failed to get file from FTP server: %w", ftpError(err))
+	}
+	return &ftpFile{Response: response, service: s, conn: conn}, nil
+}
+
+// Delete 删除文件
+func (s *FTPStorageService) Delete(filePath string) error {
+	conn, err := s.acquire()
+	if err != nil {
+		return err
+	}
+
+	err = conn.Delete(s.remotePath(filePath))
+	s.release(conn, err == nil || isFTPStatus(err))
+	if err != nil {
+		return fmt.Errorf("failed to delete file from FTP server: %w", ftpError(err))
+	}
+	return nil
+}
+
+// isFTPStatus 错误是否为服务器返回的状态码，此时连接仍然可用
+func isFTPStatus(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr)
+}
+
+// ftpError 文件不存在时（550）在错误中包含os.ErrNotExist，与本地存储一致
+func ftpError(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code == ftp.StatusFileUnavailable {
+		return fmt.Errorf("%v: %w", err, os.ErrNotExist)
+	}
+	return err
+}
+
+// GetStorageType 获取存储类型
+func (s *FTPStorageService) GetStorageType() entity.StorageType {
+	return entity.FTPStorage
+}
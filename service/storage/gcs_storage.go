@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"backup-go/entity"
+	configService "backup-go/service/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2/jwt"
+)
+
+// 可续传上传参数
+const (
+	gcsDefaultEndpoint = "https://storage.googleapis.com"
+	gcsDefaultTokenURL = "https://oauth2.googleapis.com/token"
+	gcsScope           = "https://www.googleapis.com/auth/devstorage.read_write"
+	gcsChunkSize       = 16 * 1024 * 1024 // 每次上传的分块大小，必须是256KB的整数倍
+	gcsMaxRetries      = 3                // 每个分块最多重试的次数
+)
+
+// GCSStorageService Google Cloud Storage存储服务，使用JSON API
+type GCSStorageService struct {
+	endpoint     string
+	bucket       string
+	storageClass string
+	client       *http.Client
+	err          error // 创建客户端失败的原因
+}
+
+// gcsServiceAccount 服务账号密钥文件中使用的字段
+type gcsServiceAccount struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// NewGCSStorageService 创建Google Cloud Storage存储服务
+func NewGCSStorageService() *GCSStorageService {
+	// 从系统配置表获取配置
+	cs := configService.NewConfigService()
+
+	bucket, _ := cs.GetConfigValue("storage.gcsBucket")
+	credentials, _ := cs.GetConfigValue("storage.gcsCredentials")
+	endpoint, _ := cs.GetConfigValue("storage.gcsEndpoint")
+	storageClass, _ := cs.GetConfigValue("storage.gcsStorageClass")
+
+	// 如果配置为空，则使用默认值
+	if bucket == "" {
+		bucket = "backup-go"
+	}
+	if endpoint == "" {
+		endpoint = gcsDefaultEndpoint
+	}
+
+	service := &GCSStorageService{
+		endpoint:     strings.TrimRight(endpoint, "/"),
+		bucket:       bucket,
+		storageClass: storageClass,
+	}
+
+	// fake-gcs-server等模拟器不需要认证
+	if credentials == "" {
+		if service.endpoint == gcsDefaultEndpoint {
+			service.err = fmt.Errorf("GCS service account credentials are not configured")
+			return service
+		}
+		service.client = &http.Client{}
+	} else {
+		config, err := parseGCSCredentials(credentials)
+		if err != nil {
+			service.err = err
+			return service
+		}
+		service.client = config.Client(context.Background())
+	}
+
+	// 可续传上传的分块响应为308，不是重定向
+	service.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return service
+}
+
+// parseGCSCredentials 解析服务账号密钥，配置值可以是JSON内容或密钥文件路径
+func parseGCSCredentials(value string) (*jwt.Config, error) {
+	data := []byte(value)
+	if !strings.HasPrefix(strings.TrimSpace(value), "{") {
+		var err error
+		if data, err = os.ReadFile(value); err != nil {
+			return nil, fmt.Errorf("failed to read GCS credentials file: %w", err)
+		}
+	}
+
+	var account gcsServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("failed to parse GCS credentials: %w", err)
+	}
+	if account.Type != "service_account" || account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, fmt.Errorf("GCS credentials must be a service account key")
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = gcsDefaultTokenURL
+	}
+
+	return &jwt.Config{
+		Email:        account.ClientEmail,
+		PrivateKey:   []byte(account.PrivateKey),
+		PrivateKeyID: account.PrivateKeyID,
+		Scopes:       []string{gcsScope},
+		TokenURL:     account.TokenURI,
+	}, nil
+}
+
+// objectURL 返回对象的JSON API地址，对象名中的/也需要转义
+func (s *GCSStorageService) objectURL(name string) string {
+	return fmt.Sprintf("%s/storage/v1/b/%s/o/%s", s.endpoint, url.PathEscape(s.bucket), url.PathEscape(name))
+}
+
+// responseError 读取错误响应的内容，404时错误中包含os.ErrNotExist，与本地存储一致
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	message := strings.TrimSpace(string(body))
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s %s: %w", resp.Status, message, os.ErrNotExist)
+	}
+	return fmt.Errorf("%s %s", resp.Status, message)
+}
+
+// Save 保存文件
+func (s *GCSStorageService) Save(filename string, content io.Reader) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+
+	// 创建目录格式，与S3存储一致
+	today := time.Now().Format("20060102")
+	objectName := path.Join("backups", today, filename)
+
+	session, err := s.startUpload(objectName)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload to GCS: %w", err)
+	}
+
+	// 按分块读取内容并上传，读到末尾时的分块带上总大小，完成上传
+	buffer := make([]byte, gcsChunkSize)
+	var offset int64
+	for {
+		n, readErr := io.ReadFull(content, buffer)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			s.cancelUpload(session)
+			return "", fmt.Errorf("failed to upload to GCS: %w", readErr)
+		}
+		final := readErr != nil
+		if err := s.uploadChunk(session, buffer[:n], offset, final); err != nil {
+			s.cancelUpload(session)
+			return "", fmt.Errorf("failed to upload to GCS: %w", err)
+		}
+		offset += int64(n)
+		if final {
+			break
+		}
+	}
+
+	return objectName, nil
+}
+
+// startUpload 创建可续传上传会话，返回会话地址
+func (s *GCSStorageService) startUpload(objectName string) (string, error) {
+	metadata := map[string]string{"name": objectName}
+	if s.storageClass != "" {
+		metadata["storageClass"] = s.storageClass
+	}
+	body, _ := json.Marshal(metadata)
+
+	target := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=resumable&name=%s", s.endpoint, url.PathEscape(s.bucket), url.QueryEscape(objectName))
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to start resumable upload: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("failed to start resumable upload: %w", responseError(resp))
+	}
+
+	session := resp.Header.Get("Location")
+	if session == "" {
+		return "", fmt.Errorf("failed to start resumable upload: no session URL in response")
+	}
+	return session, nil
+}
+
+// uploadChunk 上传从offset开始的一个分块，失败时查询服务端已保存的位置并续传
+func (s *GCSStorageService) uploadChunk(session string, data []byte, offset int64, final bool) error {
+	total := int64(-1)
+	if final {
+		total = offset + int64(len(data))
+	}
+	end := offset + int64(len(data))
+
+	position := offset
+	retries := 0
+	for {
+		committed, done, err := s.putRange(session, data[position-offset:], position, total)
+		if err == nil && !done && committed <= position && position < end {
+			// 服务端没有保存任何新内容，按失败处理，避免无限重试
+			err = fmt.Errorf("server did not accept any data at offset %d", position)
+		}
+		if err != nil {
+			retries++
+			if retries > gcsMaxRetries {
+				return err
+			}
+			log.Printf("GCS分块上传失败（第%d次），稍后续传: %v", retries, err)
+			time.Sleep(time.Duration(retries) * time.Second)
+
+			// 查询服务端已保存的位置
+			if committed, done, err = s.putRange(session, nil, 0, total); err != nil {
+				continue
+			}
+		}
+
+		if done {
+			if final {
+				return nil
+			}
+			return fmt.Errorf("upload finished before all data was sent")
+		}
+		if committed < offset || committed > end {
+			return fmt.Errorf("cannot resume upload: server has %d bytes, expected %d to %d", committed, offset, end)
+		}
+		if committed == end && !final {
+			return nil
+		}
+		// 继续上传服务端还没有保存的部分，最后一个分块全部保存后再发送一次总大小完成上传
+		position = committed
+	}
+}
+
+// putRange 上传从position开始的内容，没有内容时只查询上传状态
+// 返回服务端已保存的字节数，以及上传是否已经完成
+func (s *GCSStorageService) putRange(session string, data []byte, position, total int64) (int64, bool, error) {
+	size := "*"
+	if total >= 0 {
+		size = strconv.FormatInt(total, 10)
+	}
+	contentRange := "bytes */" + size
+	if len(data) > 0 {
+		contentRange = fmt.Sprintf("bytes %d-%d/%s", position, position+int64(len(data))-1, size)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, session, bytes.NewReader(data))
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Content-Range", contentRange)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return total, true, nil
+	case http.StatusPermanentRedirect:
+		// Range为已保存的范围，如bytes=0-1048575，没有该头表示还未保存任何内容
+		committed := int64(0)
+		if value := resp.Header.Get("Range"); value != "" {
+			if i := strings.LastIndex(value, "-"); i >= 0 {
+				last, err := strconv.ParseInt(value[i+1:], 10, 64)
+				if err != nil {
+					return 0, false, fmt.Errorf("invalid Range header: %s", value)
+				}
+				committed = last + 1
+			}
+		}
+		return committed, false, nil
+	default:
+		return 0, false, responseError(resp)
+	}
+}
+
+// cancelUpload 取消未完成的上传会话
+func (s *GCSStorageService) cancelUpload(session string) {
+	req, err := http.NewRequest(http.MethodDelete, session, nil)
+	if err != nil {
+		return
+	}
+	if resp, err := s.client.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// Get 获取文件
+func (s *GCSStorageService) Get(filePath string) (io.ReadCloser, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	resp, err := s.client.Get(s.objectURL(filePath) + "?alt=media")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file from GCS: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("failed to get file from GCS: %s: %w", filePath, responseError(resp))
+	}
+
+	return resp.Body, nil
+}
+
+// Delete 删除文件
+func (s *GCSStorageService) Delete(filePath string) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, s.objectURL(filePath), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete file from GCS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to delete file from GCS: %s: %w", filePath, responseError(resp))
+	}
+
+	return nil
+}
+
+// GetStorageType 获取存储类型
+func (s *GCSStorageService) GetStorageType() entity.StorageType {
+	return entity.GCSStorage
+}
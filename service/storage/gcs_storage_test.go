@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGCSServer 模拟JSON API的可续传上传、下载和删除，以及服务账号的令牌接口
+type fakeGCSServer struct {
+	t       *testing.T
+	mutex   sync.Mutex
+	url     string
+	token   string            // 非空时要求请求带上该访问令牌
+	failAt  int               // 第几次分块上传只保存一半内容后返回503，0表示不失败
+	puts    int               // 收到的分块上传次数
+	session map[string][]byte // 未完成的上传会话
+	names   map[string]string // 上传会话对应的对象名
+	objects map[string][]byte // 已完成的对象
+}
+
+func newFakeGCSServer(t *testing.T) *fakeGCSServer {
+	f := &fakeGCSServer{
+		t:       t,
+		session: make(map[string][]byte),
+		names:   make(map[string]string),
+		objects: make(map[string][]byte),
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	f.url = server.URL
+	return f
+}
+
+func (f *fakeGCSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if r.URL.Path == "/token" {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer","expires_in":3600}`, f.token)
+		return
+	}
+	if f.token != "" && r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := r.URL.EscapedPath()
+	switch {
+	case r.Method == http.MethodPost && strings.HasPrefix(path, "/upload/storage/v1/b/backup-go/o"):
+		id := strconv.Itoa(len(f.names) + 1)
+		f.names[id] = r.URL.Query().Get("name")
+		f.session[id] = nil
+		w.Header().Set("Location", f.url+"/session/"+id)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/session/"):
+		f.putRange(w, r, strings.TrimPrefix(path, "/session/"))
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, "/session/"):
+		delete(f.session, strings.TrimPrefix(path, "/session/"))
+		w.WriteHeader(499)
+	case strings.HasPrefix(path, "/storage/v1/b/backup-go/o/"):
+		name, _ := url.PathUnescape(strings.TrimPrefix(path, "/storage/v1/b/backup-go/o/"))
+		object, ok := f.objects[name]
+		if !ok {
+			http.Error(w, `{"error":{"code":404,"message":"No such object"}}`, http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			w.Write(object)
+		case http.MethodDelete:
+			delete(f.objects, name)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// putRange 处理分块上传和状态查询，Content-Range为"bytes 起-止/总大小"或"bytes */总大小"
+func (f *fakeGCSServer) putRange(w http.ResponseWriter, r *http.Request, id string) {
+	stored, ok := f.session[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	spec := strings.TrimPrefix(r.Header.Get("Content-Range"), "bytes ")
+	rangePart, totalPart, _ := strings.Cut(spec, "/")
+	data, _ := io.ReadAll(r.Body)
+
+	if rangePart != "*" {
+		f.puts++
+		start, _ := strconv.Atoi(strings.SplitN(rangePart, "-", 2)[0])
+		if start != len(stored) {
+			f.t.Errorf("chunk starts at %d, server has %d bytes", start, len(stored))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if f.puts == f.failAt {
+			// 连接中断：只保存了一部分内容
+			f.session[id] = append(stored, data[:len(data)/2]...)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		stored = append(stored, data...)
+		f.session[id] = stored
+	}
+
+	if total, err := strconv.Atoi(totalPart); err == nil && total == len(stored) {
+		f.objects[f.names[id]] = stored
+		delete(f.session, id)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if len(stored) > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(stored)-1))
+	}
+	w.WriteHeader(http.StatusPermanentRedirect)
+}
+
+// newTestGCS 创建连接到模拟服务端、不需要认证的存储服务
+func newTestGCS(f *fakeGCSServer) *GCSStorageService {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &GCSStorageService{endpoint: f.url, bucket: "backup-go", client: client}
+}
+
+// roundTrip 保存、读取并删除文件，确认内容一致且删除后返回os.ErrNotExist
+func roundTrip(t *testing.T, s StorageService, data []byte) {
+	t.Helper()
+	name, err := s.Save("db.sql.gz", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := s.Get(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(data))
+	}
+
+	if err := s.Delete(name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(name); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Get after delete: error = %v, want os.ErrNotExist", err)
+	}
+	if err := s.Delete(name); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Delete missing object: error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestGCSStorage(t *testing.T) {
+	f := newFakeGCSServer(t)
+	roundTrip(t, newTestGCS(f), bytes.Repeat([]byte("gcs"), 1000))
+}
+
+func TestGCSStorageEmptyFile(t *testing.T) {
+	f := newFakeGCSServer(t)
+	roundTrip(t, newTestGCS(f), []byte{})
+}
+
+func TestGCSStorageResumesInterruptedChunk(t *testing.T) {
+	f := newFakeGCSServer(t)
+	f.failAt = 1
+	data := bytes.Repeat([]byte("resume"), 10000)
+
+	s := newTestGCS(f)
+	name, err := s.Save("db.sql.gz", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 第一次上传中断后只续传剩余的部分
+	if f.puts != 2 {
+		t.Errorf("chunk uploads = %d, want 2", f.puts)
+	}
+	if !bytes.Equal(f.objects[name], data) {
+		t.Fatalf("object has %d bytes, want %d", len(f.objects[name]), len(data))
+	}
+}
+
+func TestGCSStorageServiceAccount(t *testing.T) {
+	f := newFakeGCSServer(t)
+	f.token = "test-token"
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "backup@example.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    f.url + "/token",
+	})
+
+	config, err := parseGCSCredentials(string(credentials))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestGCS(f)
+	s.client = config.Client(context.Background())
+	s.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	roundTrip(t, s, []byte("authorized"))
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -56,9 +57,11 @@ func (s *LocalStorageService) Save(filename string, content io.Reader) (string,
 	}
 	defer file.Close()
 
-	// 写入文件
+	// 写入文件，内容是边生成边写入的，生成失败时删除不完整的文件
 	_, err = io.Copy(file, content)
 	if err != nil {
+		file.Close()
+		os.Remove(filePath)
 		return "", fmt.Errorf("failed to write file: %w", err)
 	}
 
@@ -70,9 +73,22 @@ func (s *LocalStorageService) Save(filename string, content io.Reader) (string,
 	return relativePath, nil
 }
 
+// fullPath 返回文件的完整路径
+// 旧版本的记录中可能保存的是绝对路径，只有位于存储目录下时才直接使用，避免访问任意文件
+func (s *LocalStorageService) fullPath(path string) string {
+	if filepath.IsAbs(path) {
+		if base, err := filepath.Abs(s.basePath); err == nil {
+			if rel, err := filepath.Rel(base, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return path
+			}
+		}
+	}
+	return filepath.Join(s.basePath, path)
+}
+
 // Get 获取文件
 func (s *LocalStorageService) Get(path string) (io.ReadCloser, error) {
-	fullPath := filepath.Join(s.basePath, path)
+	fullPath := s.fullPath(path)
 	file, err := os.Open(fullPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
@@ -80,9 +96,14 @@ func (s *LocalStorageService) Get(path string) (io.ReadCloser, error) {
 	return file, nil
 }
 
+// LocalPath 返回文件在本机的完整路径，用于需要随机读取文件的场景
+func (s *LocalStorageService) LocalPath(path string) string {
+	return s.fullPath(path)
+}
+
 // Delete 删除文件
 func (s *LocalStorageService) Delete(path string) error {
-	fullPath := filepath.Join(s.basePath, path)
+	fullPath := s.fullPath(path)
 	err := os.Remove(fullPath)
 	if err != nil {
 		return fmt.Errorf("failed to delete file: %w", err)
@@ -5,16 +5,24 @@ import (
 	configService "backup-go/service/config"
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
+// 分片上传参数
+const (
+	s3PartSize          = 64 * 1024 * 1024 // 分片大小
+	s3UploadConcurrency = 3                // 同时上传的分片数
+)
+
 // S3StorageService S3协议存储服务
 type S3StorageService struct {
 	session    *session.Session
@@ -72,7 +80,12 @@ func NewS3StorageService() *S3StorageService {
 	service.session = sess
 
 	// 创建上传器、下载器和客户端
-	service.uploader = s3manager.NewUploader(sess)
+	service.uploader = s3manager.NewUploader(sess, func(u *s3manager.Uploader) {
+		// 备份内容以流的方式上传，无法预先知道大小，分片上限为10000个
+		// 64MB的分片可以上传约640GB的备份，同时缓存的分片数控制内存占用
+		u.PartSize = s3PartSize
+		u.Concurrency = s3UploadConcurrency
+	})
 	service.downloader = s3manager.NewDownloader(sess)
 	service.s3Client = s3.New(sess)
 
@@ -114,6 +127,10 @@ func (s *S3StorageService) Get(path string) (io.ReadCloser, error) {
 		Key:    aws.String(path),
 	})
 	if err != nil {
+		// 对象不存在时与本地存储一样返回os.ErrNotExist，便于调用方区分文件缺失和其他错误
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, fmt.Errorf("failed to get file from S3: %s: %w", path, os.ErrNotExist)
+		}
 		return nil, fmt.Errorf("failed to get file from S3: %w", err)
 	}
 
@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"backup-go/entity"
+	configService "backup-go/service/config"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SFTP连接超时时间
+const sftpDialTimeout = 30 * time.Second
+
+// SFTPStorageService SFTP存储服务
+type SFTPStorageService struct {
+	host           string
+	port           int
+	user           string
+	password       string
+	keyPath        string
+	knownHostsPath string
+	basePath       string
+}
+
+// sftpConnection 复用的SFTP连接，存储服务按次创建，数据块等小文件逐个保存时不必每次重新握手
+type sftpConnection struct {
+	key    string
+	ssh    *ssh.Client
+	client *sftp.Client
+}
+
+var (
+	sftpMutex  sync.Mutex
+	sftpShared *sftpConnection
+)
+
+// NewSFTPStorageService 创建SFTP存储服务
+func NewSFTPStorageService() *SFTPStorageService {
+	// 从系统配置表获取配置
+	cs := configService.NewConfigService()
+
+	host, _ := cs.GetConfigValue("storage.sftpHost")
+	portStr, _ := cs.GetConfigValue("storage.sftpPort")
+	user, _ := cs.GetConfigValue("storage.sftpUser")
+	password, _ := cs.GetConfigValue("storage.sftpPassword")
+	keyPath, _ := cs.GetConfigValue("storage.sftpKeyPath")
+	knownHostsPath, _ := cs.GetConfigValue("storage.sftpKnownHosts")
+	basePath, _ := cs.GetConfigValue("storage.sftpBasePath")
+
+	// 如果配置为空，则使用默认值
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 {
+		port = 22
+	}
+	if knownHostsPath == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			knownHostsPath = filepath.Join(home, ".ssh", "known_hosts")
+		}
+	}
+	if basePath == "" {
+		basePath = "backups"
+	}
+
+	return &SFTPStorageService{
+		host:           host,
+		port:           port,
+		user:           user,
+		password:       password,
+		keyPath:        keyPath,
+		knownHostsPath: knownHostsPath,
+		basePath:       basePath,
+	}
+}
+
+// connect 返回可用的SFTP连接，配置未变化且连接未断开时复用已有连接
+func (s *SFTPStorageService) connect() (*sftp.Client, error) {
+	if s.host == "" || s.user == "" {
+		return nil, fmt.Errorf("SFTP not configured properly")
+	}
+
+	key := fmt.Sprintf("%s@%s:%d|%s|%s|%s", s.user, s.host, s.port, s.password, s.keyPath, s.knownHostsPath)
+
+	sftpMutex.Lock()
+	defer sftpMutex.Unlock()
+
+	if sftpShared != nil {
+		if sftpShared.key == key {
+			return sftpShared.client, nil
+		}
+		// 配置已修改，关闭旧连接
+		sftpShared.client.Close()
+		sftpShared.ssh.Close()
+		sftpShared = nil
+	}
+
+	config, err := s.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	address := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	sshClient, err := ssh.Dial("tcp", address, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SFTP server %s: %w", address, err)
+	}
+	client, err := sftp.NewClient(sshClient, sftp.UseConcurrentWrites(true))
+	if err != nil {
+		sshClient.Close()
+		return nil, fmt.Errorf("failed to start SFTP session: %w", err)
+	}
+
+	conn := &sftpConnection{key: key, ssh: sshClient, client: client}
+	sftpShared = conn
+
+	// 连接断开后丢弃，下次使用时重新连接
+	go func() {
+		sshClient.Wait()
+		sftpMutex.Lock()
+		if sftpShared == conn {
+			sftpShared = nil
+		}
+		sftpMutex.Unlock()
+	}()
+
+	return client, nil
+}
+
+// clientConfig 构建SSH认证和主机密钥校验配置
+func (s *SFTPStorageService) clientConfig() (*ssh.ClientConfig, error) {
+	if s.knownHostsPath == "" {
+		return nil, fmt.Errorf("SFTP known_hosts file is not configured")
+	}
+	hostKeyCallback, err := knownhosts.New(s.knownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts %s: %w", s.knownHostsPath, err)
+	}
+
+	var auth []ssh.AuthMethod
+	if s.keyPath != "" {
+		keyData, err := os.ReadFile(s.keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SFTP private key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(keyData)
+		// 私钥有口令时使用配置的密码解密
+		var passphraseErr *ssh.PassphraseMissingError
+		if errors.As(err, &passphraseErr) && s.password != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(keyData, []byte(s.password))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SFTP private key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	} else if s.password != "" {
+		auth = append(auth, ssh.Password(s.password))
+	} else {
+		return nil, fmt.Errorf("SFTP password or private key is required")
+	}
+
+	return &ssh.ClientConfig{
+		User:            s.user,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sftpDialTimeout,
+	}, nil
+}
+
+// remotePath 返回文件在服务器上的完整路径
+func (s *SFTPStorageService) remotePath(filePath string) string {
+	return path.Join(s.basePath, filepath.ToSlash(filePath))
+}
+
+// Save 保存文件
+func (s *SFTPStorageService) Save(filename string, content io.Reader) (string, error) {
+	client, err := s.connect()
+	if err != nil {
+		return "", err
+	}
+
+	// 创建目录
+	today := time.Now().Format("20060102")
+	relativePath := path.Join(today, filename)
+	fullPath := s.remotePath(relativePath)
+	if err := client.MkdirAll(path.Dir(fullPath)); err != nil {
+		return "", fmt.Errorf("failed to create directory on SFTP server: %w", err)
+	}
+
+	file, err := client.Create(fullPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file on SFTP server: %w", err)
+	}
+
+	// 写入文件，内容是边生成边写入的，生成失败时删除不完整的文件
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		client.Remove(fullPath)
+		return "", fmt.Errorf("failed to upload to SFTP server: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		client.Remove(fullPath)
+		return "", fmt.Errorf("failed to upload to SFTP server: %w", err)
+	}
+
+	return relativePath, nil
+}
+
+// Get 获取文件
+func (s *SFTPStorageService) Get(filePath string) (io.ReadCloser, error) {
+	client, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	// 文件不存在时错误中包含os.ErrNotExist，与本地存储一致
+	file, err := client.Open(s.remotePath(filePath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file from SFTP server: %w", err)
+	}
+	return file, nil
+}
+
+// Delete 删除文件
+func (s *SFTPStorageService) Delete(filePath string) error {
+	client, err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	if err := client.Remove(s.remotePath(filePath)); err != nil {
+		return fmt.Errorf("failed to delete file from SFTP server: %w", err)
+	}
+	return nil
+}
+
+// GetStorageType 获取存储类型
+func (s *SFTPStorageService) GetStorageType() entity.StorageType {
+	return entity.SFTPStorage
+}
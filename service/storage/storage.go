@@ -3,7 +3,11 @@ package storage
 import (
 	"backup-go/entity"
 	configService "backup-go/service/config"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
+	"path/filepath"
+	"strings"
 )
 
 // StorageService 存储服务接口
@@ -41,7 +45,60 @@ func NewStorageService(storageType entity.StorageType) (StorageService, error) {
 		return NewLocalStorageService(), nil
 	case entity.S3Storage:
 		return NewS3StorageService(), nil
+	case entity.SFTPStorage:
+		return NewSFTPStorageService(), nil
+	case entity.WebDAVStorage:
+		return NewWebDAVStorageService(), nil
+	case entity.FTPStorage:
+		return NewFTPStorageService(), nil
+	case entity.AzureStorage:
+		return NewAzureStorageService(), nil
+	case entity.GCSStorage:
+		return NewGCSStorageService(), nil
 	default:
 		return NewLocalStorageService(), nil
 	}
 }
+
+// NewStorageServiceForRecord 根据备份记录创建对应的存储服务
+func NewStorageServiceForRecord(record *entity.BackupRecord) (StorageService, error) {
+	// 优先使用记录中存储的存储类型
+	storageType := record.StorageType
+
+	// 如果记录中没有存储类型（旧数据兼容处理），使用系统配置
+	if storageType == "" {
+		// 从系统配置表读取存储类型
+		cs := configService.NewConfigService()
+		storageTypeStr, err := cs.GetConfigValue("storage.type")
+		if err == nil && storageTypeStr != "" {
+			storageType = entity.StorageType(storageTypeStr)
+		} else {
+			// 配置表中也没有，尝试从文件路径判断
+			filePath := record.FilePath
+			if strings.HasPrefix(filePath, "s3://") || strings.HasPrefix(filePath, "backups/") {
+				// S3存储或特定格式
+				storageType = entity.S3Storage
+			} else if filepath.IsAbs(filePath) || strings.HasPrefix(filePath, "./") || strings.HasPrefix(filePath, "../") {
+				// 绝对路径或相对路径，视为本地存储
+				storageType = entity.LocalStorage
+			}
+		}
+	}
+
+	return NewStorageService(storageType)
+}
+
+// Checksum 读取存储中的文件并计算SHA-256，文件不存在时返回的错误包含os.ErrNotExist
+func Checksum(storageService StorageService, path string) (string, error) {
+	file, err := storageService.Get(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
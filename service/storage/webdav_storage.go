@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"backup-go/entity"
+	configService "backup-go/service/config"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebDAVStorageService WebDAV存储服务，兼容Nextcloud和ownCloud
+type WebDAVStorageService struct {
+	baseURL   string
+	user      string
+	password  string
+	token     string
+	chunkSize int64
+	client    *http.Client
+
+	mutex   sync.Mutex
+	created map[string]bool // 已创建的目录，避免重复发送MKCOL
+}
+
+// NewWebDAVStorageService 创建WebDAV存储服务
+func NewWebDAVStorageService() *WebDAVStorageService {
+	// 从系统配置表获取配置
+	cs := configService.NewConfigService()
+
+	baseURL, _ := cs.GetConfigValue("storage.webdavUrl")
+	user, _ := cs.GetConfigValue("storage.webdavUser")
+	password, _ := cs.GetConfigValue("storage.webdavPassword")
+	token, _ := cs.GetConfigValue("storage.webdavToken")
+	chunkSizeStr, _ := cs.GetConfigValue("storage.webdavChunkSize")
+
+	// 分块大小以MB为单位，0表示不分块上传
+	chunkSizeMB, _ := strconv.ParseInt(chunkSizeStr, 10, 64)
+
+	return &WebDAVStorageService{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		user:      user,
+		password:  password,
+		token:     token,
+		chunkSize: chunkSizeMB * 1024 * 1024,
+		// 上传大文件耗时较长，不设置整体超时
+		client:  &http.Client{},
+		created: make(map[string]bool),
+	}
+}
+
+// fileURL 返回存储路径对应的URL，逐段转义
+func (s *WebDAVStorageService) fileURL(filePath string) string {
+	return joinURL(s.baseURL, filePath)
+}
+
+// joinURL 将路径逐段转义后拼接到URL之后
+func joinURL(base, filePath string) string {
+	segments := strings.Split(strings.Trim(path.Clean("/"+filePath), "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return base + "/" + strings.Join(segments, "/")
+}
+
+// newRequest 创建带认证信息的请求
+func (s *WebDAVStorageService) newRequest(method, target string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	} else if s.user != "" {
+		req.SetBasicAuth(s.user, s.password)
+	}
+	return req, nil
+}
+
+// do 发送请求，返回的响应状态码不在expected中时返回错误
+func (s *WebDAVStorageService) do(req *http.Request, expected ...int) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, os.ErrNotExist)
+	}
+	return nil, fmt.Errorf("%s %s: unexpected status %s", req.Method, req.URL.Path, resp.Status)
+}
+
+// mkcol 逐级创建目录，目录已存在时服务器返回405
+func (s *WebDAVStorageService) mkcol(base, dir string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current := ""
+	for _, segment := range strings.Split(strings.Trim(dir, "/"), "/") {
+		current = path.Join(current, segment)
+		target := joinURL(base, current)
+		if s.created[target] {
+			continue
+		}
+
+		req, err := s.newRequest("MKCOL", target, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := s.do(req, http.StatusCreated, http.StatusMethodNotAllowed)
+		if err != nil {
+			return fmt.Errorf("failed to create directory on WebDAV server: %w", err)
+		}
+		resp.Body.Close()
+		s.created[target] = true
+	}
+	return nil
+}
+
+// Save 保存文件
+func (s *WebDAVStorageService) Save(filename string, content io.Reader) (string, error) {
+	if s.baseURL == "" {
+		return "", fmt.Errorf("WebDAV not configured properly")
+	}
+
+	// 创建目录格式，与S3存储一致
+	today := time.Now().Format("20060102")
+	dir := path.Join("backups", today)
+	if err := s.mkcol(s.baseURL, dir); err != nil {
+		return "", err
+	}
+	filePath := path.Join(dir, filename)
+
+	var err error
+	if s.chunkSize > 0 {
+		err = s.uploadChunked(filePath, content)
+	} else {
+		err = s.upload(filePath, content)
+	}
+	if err != nil {
+		// 删除可能残留的不完整文件
+		s.Delete(filePath)
+		return "", fmt.Errorf("failed to upload to WebDAV server: %w", err)
+	}
+
+	return filePath, nil
+}
+
+// upload 以chunked传输编码流式上传整个文件
+func (s *WebDAVStorageService) upload(filePath string, content io.Reader) error {
+	req, err := s.newRequest(http.MethodPut, s.fileURL(filePath), io.NopCloser(content))
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, http.StatusCreated, http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// uploadChunked 使用Nextcloud的分块上传协议：先把各个分块上传到临时目录，再通过MOVE合并为目标文件，
+// 避免单个请求超过反向代理或PHP的大小限制
+func (s *WebDAVStorageService) uploadChunked(filePath string, content io.Reader) error {
+	uploadsURL, err := s.uploadsURL()
+	if err != nil {
+		return err
+	}
+	destination := s.fileURL(filePath)
+	uploadDir := joinURL(uploadsURL, uuid.New().String())
+
+	req, err := s.newRequest("MKCOL", uploadDir, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Destination", destination)
+	resp, err := s.do(req, http.StatusCreated)
+	if err != nil {
+		return fmt.Errorf("failed to start chunked upload: %w", err)
+	}
+	resp.Body.Close()
+
+	if err := s.uploadChunks(uploadDir, destination, content); err != nil {
+		// 放弃上传，删除临时目录
+		if req, reqErr := s.newRequest(http.MethodDelete, uploadDir, nil); reqErr == nil {
+			if resp, delErr := s.do(req, http.StatusNoContent, http.StatusOK); delErr == nil {
+				resp.Body.Close()
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// uploadChunks 按分块大小依次上传内容，全部上传后合并
+func (s *WebDAVStorageService) uploadChunks(uploadDir, destination string, content io.Reader) error {
+	buffer := make([]byte, s.chunkSize)
+	for number := 1; ; number++ {
+		n, err := io.ReadFull(content, buffer)
+		if err == io.EOF && number > 1 {
+			break
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		// 分块名按序号补零，保证按名称排序即为上传顺序
+		req, reqErr := s.newRequest(http.MethodPut, fmt.Sprintf("%s/%05d", uploadDir, number), bytes.NewReader(buffer[:n]))
+		if reqErr != nil {
+			return reqErr
+		}
+		req.Header.Set("Destination", destination)
+		resp, doErr := s.do(req, http.StatusCreated, http.StatusNoContent, http.StatusOK)
+		if doErr != nil {
+			return fmt.Errorf("failed to upload chunk %d: %w", number, doErr)
+		}
+		resp.Body.Close()
+
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			break
+		}
+	}
+
+	req, err := s.newRequest("MOVE", uploadDir+"/.file", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Destination", destination)
+	resp, err := s.do(req, http.StatusCreated, http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return fmt.Errorf("failed to assemble chunks: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// uploadsURL 由文件URL（.../remote.php/dav/files/用户名/...）得到分块上传目录（.../remote.php/dav/uploads/用户名）
+func (s *WebDAVStorageService) uploadsURL() (string, error) {
+	const filesPath = "/remote.php/dav/files/"
+	i := strings.Index(s.baseURL, filesPath)
+	if i < 0 {
+		return "", fmt.Errorf("chunked upload requires a Nextcloud/ownCloud URL like https://host/remote.php/dav/files/<user>")
+	}
+	user := strings.SplitN(s.baseURL[i+len(filesPath):], "/", 2)[0]
+	if user == "" {
+		return "", fmt.Errorf("chunked upload requires a Nextcloud/ownCloud URL like https://host/remote.php/dav/files/<user>")
+	}
+	return s.baseURL[:i] + "/remote.php/dav/uploads/" + user, nil
+}
+
+// Get 获取文件
+func (s *WebDAVStorageService) Get(filePath string) (io.ReadCloser, error) {
+	if s.baseURL == "" {
+		return nil, fmt.Errorf("WebDAV not configured properly")
+	}
+
+	req, err := s.newRequest(http.MethodGet, s.fileURL(filePath), nil)
+	if err != nil {
+		return nil, err
+	}
+	// 文件不存在时错误中包含os.ErrNotExist，与本地存储一致
+	resp, err := s.do(req, http.StatusOK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file from WebDAV server: %w", err)
+	}
+	return resp.Body, nil
+}
+
+// Delete 删除文件
+func (s *WebDAVStorageService) Delete(filePath string) error {
+	if s.baseURL == "" {
+		return fmt.Errorf("WebDAV not configured properly")
+	}
+
+	req, err := s.newRequest(http.MethodDelete, s.fileURL(filePath), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return fmt.Errorf("failed to delete file from WebDAV server: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetStorageType 获取存储类型
+func (s *WebDAVStorageService) GetStorageType() entity.StorageType {
+	return entity.WebDAVStorage
+}
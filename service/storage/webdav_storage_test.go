@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+// newTestWebDAV 启动基于内存文件系统的WebDAV服务端，要求Basic认证
+func newTestWebDAV(t *testing.T) *WebDAVStorageService {
+	t.Helper()
+	handler := &webdav.Handler{
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "backup" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return &WebDAVStorageService{
+		baseURL:  server.URL,
+		user:     "backup",
+		password: "secret",
+		client:   server.Client(),
+		created:  make(map[string]bool),
+	}
+}
+
+func TestWebDAVStorage(t *testing.T) {
+	s := newTestWebDAV(t)
+	data := bytes.Repeat([]byte("webdav"), 1000)
+
+	filePath, err := s.Save("db 1.sql.gz", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := s.Get(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(data))
+	}
+
+	if err := s.Delete(filePath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(filePath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Get after delete: error = %v, want os.ErrNotExist", err)
+	}
+	if err := s.Delete(filePath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Delete missing file: error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestWebDAVStorageUnauthorized(t *testing.T) {
+	s := newTestWebDAV(t)
+	s.password = "wrong"
+
+	if _, err := s.Save("db.sql.gz", bytes.NewReader([]byte("data"))); err == nil {
+		t.Fatal("Save with wrong password succeeded")
+	}
+}